@@ -1,25 +1,47 @@
 package alerts
 
 import (
-	"database/sql"
-	"errors"
+	"context"
 	"fmt"
-	"net/smtp"
+	"html"
+	"log"
 	"status/app/internal/database"
 	"status/app/internal/models"
+	"status/app/internal/notify"
+	"status/app/internal/state"
+	"sync"
 	"time"
 )
 
-// Manager handles email alert functionality
+// sendTimeout bounds the delivery of one notification to one channel
+const sendTimeout = 30 * time.Second
+
+// Manager sends alerts by email and to the configured notification
+// channels, routed and escalated by the alert rules
 type Manager struct {
+	mu            sync.RWMutex
 	config        *models.AlertConfig
+	channels      []*models.NotificationChannel
+	rules         []*models.AlertRule
 	statusPageURL string
+	ackSecret     []byte // signs the acknowledge links in alert emails
+
+	checkMu sync.Mutex    // serializes status history updates
+	wake    chan struct{} // signals the outbox worker that notifications were queued
 }
 
-// NewManager creates a new alerts manager
-func NewManager(statusPageURL string) *Manager {
+// NewManager creates a new alerts manager. The secret signs the links that
+// acknowledge incidents from alert emails.
+func NewManager(statusPageURL string, ackSecret []byte) *Manager {
 	config, _ := database.LoadAlertConfig()
-	return &Manager{config: config, statusPageURL: statusPageURL}
+	m := &Manager{config: config, statusPageURL: statusPageURL, ackSecret: ackSecret, wake: make(chan struct{}, 1)}
+	if err := m.ReloadChannels(); err != nil {
+		log.Printf("alerts: load notification channels: %v", err)
+	}
+	if err := m.ReloadRules(); err != nil {
+		log.Printf("alerts: load alert rules: %v", err)
+	}
+	return m
 }
 
 // ReloadConfig reloads the alert configuration from database
@@ -28,110 +50,225 @@ func (m *Manager) ReloadConfig() error {
 	if err != nil {
 		return err
 	}
-	m.config = config
+	m.SetConfig(config)
+	return nil
+}
+
+// ReloadChannels reloads the notification channels from database
+func (m *Manager) ReloadChannels() error {
+	channels, err := database.ListNotificationChannels()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.channels = channels
+	return nil
+}
+
+// ReloadRules reloads the alert routing rules from database
+func (m *Manager) ReloadRules() error {
+	rules, err := database.ListAlertRules()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = rules
 	return nil
 }
 
 // GetConfig returns the current alert configuration
 func (m *Manager) GetConfig() *models.AlertConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.config
 }
 
 // GetStatusPageURL returns the configured status page URL
 func (m *Manager) GetStatusPageURL() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.statusPageURL
 }
 
 // SetConfig updates the alert configuration
 func (m *Manager) SetConfig(config *models.AlertConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.config = config
 }
 
-// SendEmail sends an email alert
+// SetStatusPageURL updates the status page URL linked from alert emails
+func (m *Manager) SetStatusPageURL(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statusPageURL = url
+}
+
+// SendEmail sends an email alert right away, bypassing the outbox. The
+// attempt is still logged.
 func (m *Manager) SendEmail(subject, body string) error {
-	if m.config == nil || !m.config.Enabled {
+	config := m.GetConfig()
+	if config == nil || !config.Enabled {
 		return nil
 	}
+	n := &notify.Notification{Event: notify.EventTest, Title: subject, HTML: body, Time: time.Now()}
+	email := &notify.Email{Config: config}
+	err := email.Notify(context.Background(), n)
+	m.logAttempt("email", n, err)
+	return err
+}
 
-	if m.config.SMTPHost == "" || m.config.AlertEmail == "" {
-		return errors.New("SMTP configuration incomplete")
+// TestChannel sends a test notification to a channel, enabled or not, right
+// away. The attempt is logged.
+func (m *Manager) TestChannel(ch *models.NotificationChannel) error {
+	notifier, err := notify.New(ch)
+	if err != nil {
+		return err
 	}
-
-	from := m.config.FromEmail
-	if from == "" {
-		from = m.config.SMTPUser
+	statusPageURL := m.GetStatusPageURL()
+	subject := "Test Alert from Servicarr"
+	message := "This is a test notification from your Servicarr monitoring system. If you received this, your " + ch.Name + " channel is working correctly!"
+	n := &notify.Notification{
+		Event:       notify.EventTest,
+		ServiceKey:  "test",
+		ServiceName: "Test Service",
+		Title:       subject,
+		Message:     message,
+		HTML:        CreateHTMLEmail(subject, "up", "Test Service", "test", html.EscapeString(message), statusPageURL, ""),
+		URL:         statusPageURL,
+		Time:        time.Now(),
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	err = notifier.Notify(ctx, n)
+	m.logAttempt(ch.Name, n, err)
+	return err
+}
 
-	// Create MIME message with HTML
-	headers := make(map[string]string)
-	headers["From"] = from
-	headers["To"] = m.config.AlertEmail
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=UTF-8"
+// CheckAndSendAlerts records the status of a service, opens or resolves its
+// incident when it goes down or recovers, and sends alerts on changes
+func (m *Manager) CheckAndSendAlerts(serviceKey, serviceName string, st state.ServiceState) {
+	ok, degraded := st.OK, st.Degraded
 
-	var msg string
-	for k, v := range headers {
-		msg += fmt.Sprintf("%s: %s\r\n", k, v)
-	}
-	msg += "\r\n" + body
+	// The scheduler and manual checks can report the same service at once;
+	// the history read and update must not interleave
+	m.checkMu.Lock()
+	defer m.checkMu.Unlock()
 
-	auth := smtp.PlainAuth("", m.config.SMTPUser, m.config.SMTPPassword, m.config.SMTPHost)
-	addr := fmt.Sprintf("%s:%d", m.config.SMTPHost, m.config.SMTPPort)
+	// Get previous status
+	prev, err := database.LoadStatusHistory(serviceKey)
+	if err != nil {
+		log.Printf("alerts: load status of %s: %v", serviceKey, err)
+		return
+	}
+	first := prev == nil
+	var prevOKBool, prevDegradedBool bool
+	if prev != nil {
+		prevOKBool, prevDegradedBool = prev.OK, prev.Degraded
+	}
 
-	return smtp.SendMail(addr, auth, from, []string{m.config.AlertEmail}, []byte(msg))
-}
+	// Update status history, keeping when the current status began
+	since := st.CheckedAt
+	if !first && ok == prevOKBool && degraded == prevDegradedBool && !prev.Since.IsZero() {
+		since = prev.Since
+	}
+	if err := database.SaveStatusHistory(serviceKey, models.StatusHistory{OK: ok, Degraded: degraded, Since: since}); err != nil {
+		log.Printf("alerts: save status of %s: %v", serviceKey, err)
+	}
 
-// CheckAndSendAlerts checks for service status changes and sends alerts
-func (m *Manager) CheckAndSendAlerts(serviceKey, serviceName string, ok, degraded bool) {
-	if m.config == nil || !m.config.Enabled {
-		return
+	// Incidents follow the status whether or not alerts are enabled
+	var incidentID int64
+	if !ok && (first || prevOKBool) {
+		if incidentID, err = database.OpenIncident(serviceKey, serviceName+" is down", st.Result.Message, st.CheckedAt); err != nil {
+			log.Printf("alerts: open incident for %s: %v", serviceKey, err)
+		}
+	} else if ok && !first && !prevOKBool {
+		if incidentID, err = database.ResolveIncident(serviceKey, st.CheckedAt); err != nil {
+			log.Printf("alerts: resolve incident for %s: %v", serviceKey, err)
+		}
 	}
 
-	// Get previous status
-	var prevOK, prevDegraded int
-	err := database.DB.QueryRow(`SELECT ok, degraded FROM service_status_history WHERE service_key = ?`, serviceKey).
-		Scan(&prevOK, &prevDegraded)
-
-	if err == sql.ErrNoRows {
-		// First time - just save current status
-		_, _ = database.DB.Exec(`INSERT INTO service_status_history (service_key, ok, degraded, updated_at) VALUES (?, ?, ?, datetime('now'))`,
-			serviceKey, boolToInt(ok), boolToInt(degraded))
+	if first {
+		// The first status seen for a service is not a change
 		return
 	}
-
-	prevOKBool := prevOK == 1
-	prevDegradedBool := prevDegraded == 1
+	if ok && !degraded && (!prevOKBool || prevDegradedBool) {
+		if err := database.ClearEscalations(serviceKey); err != nil {
+			log.Printf("alerts: clear escalations of %s: %v", serviceKey, err)
+		}
+	}
+	// The alert conditions apply to changes no routing rule matches
+	config := m.GetConfig()
+	if config == nil {
+		config = models.DefaultAlertConfig()
+	}
 
 	// Check for status changes
-	if !ok && prevOKBool && m.config.AlertOnDown {
+	var event, subject, text, message string
+	if !ok && prevOKBool && m.wants(serviceKey, notify.EventDown, config.AlertOnDown) {
 		// Service went down
-		subject := fmt.Sprintf("🔴 Service Down: %s", serviceName)
-		message := fmt.Sprintf("The service <strong>%s</strong> is currently unreachable and not responding to health checks. Please investigate immediately.", serviceName)
-		body := CreateHTMLEmail(subject, "down", serviceName, serviceKey, message, m.statusPageURL)
-		go m.SendEmail(subject, body)
-	} else if ok && !prevOKBool && m.config.AlertOnUp {
+		event = notify.EventDown
+		subject = fmt.Sprintf("🔴 Service Down: %s", serviceName)
+		text = fmt.Sprintf("The service %s is currently unreachable and not responding to health checks. Please investigate immediately.", serviceName)
+		message = fmt.Sprintf("The service <strong>%s</strong> is currently unreachable and not responding to health checks. Please investigate immediately.", serviceName)
+	} else if ok && !prevOKBool && m.wants(serviceKey, notify.EventUp, config.AlertOnUp) {
 		// Service came back up
-		subject := fmt.Sprintf("✅ Service Recovered: %s", serviceName)
-		message := fmt.Sprintf("Great news! The service <strong>%s</strong> has recovered and is now responding normally to health checks.", serviceName)
-		body := CreateHTMLEmail(subject, "up", serviceName, serviceKey, message, m.statusPageURL)
-		go m.SendEmail(subject, body)
-	} else if ok && degraded && !prevDegradedBool && m.config.AlertOnDegraded {
+		event = notify.EventUp
+		subject = fmt.Sprintf("✅ Service Recovered: %s", serviceName)
+		text = fmt.Sprintf("Great news! The service %s has recovered and is now responding normally to health checks.", serviceName)
+		message = fmt.Sprintf("Great news! The service <strong>%s</strong> has recovered and is now responding normally to health checks.", serviceName)
+	} else if ok && degraded && !prevDegradedBool && m.wants(serviceKey, notify.EventDegraded, config.AlertOnDegraded) {
 		// Service became degraded
-		subject := fmt.Sprintf("⚠️ Service Degraded: %s", serviceName)
-		message := fmt.Sprintf("The service <strong>%s</strong> is responding but experiencing high latency (over 200ms). Performance may be impacted.", serviceName)
-		body := CreateHTMLEmail(subject, "degraded", serviceName, serviceKey, message, m.statusPageURL)
-		go m.SendEmail(subject, body)
+		event = notify.EventDegraded
+		subject = fmt.Sprintf("⚠️ Service Degraded: %s", serviceName)
+		text = fmt.Sprintf("The service %s is responding but degraded: %s. Performance may be impacted.", serviceName, st.Reason)
+		message = fmt.Sprintf("The service <strong>%s</strong> is responding but degraded: %s. Performance may be impacted.", serviceName, html.EscapeString(st.Reason))
 	}
 
-	// Update status history
-	_, _ = database.DB.Exec(`INSERT INTO service_status_history (service_key, ok, degraded, updated_at) VALUES (?, ?, ?, datetime('now'))
-		ON CONFLICT(service_key) DO UPDATE SET ok=?, degraded=?, updated_at=datetime('now')`,
-		serviceKey, boolToInt(ok), boolToInt(degraded), boolToInt(ok), boolToInt(degraded))
+	statusPageURL := m.GetStatusPageURL()
+	if event != "" {
+		var ackURL string
+		if event == notify.EventDown {
+			ackURL = m.AckURL(incidentID)
+		}
+		m.route(&notify.Notification{
+			Event:       event,
+			ServiceKey:  serviceKey,
+			ServiceName: serviceName,
+			Title:       subject,
+			Message:     text,
+			HTML:        CreateHTMLEmail(subject, event, serviceName, serviceKey, message, statusPageURL, ackURL),
+			URL:         statusPageURL,
+			Time:        st.CheckedAt,
+			OldState:    stateName(prevOKBool, prevDegradedBool),
+			NewState:    stateName(ok, degraded),
+			LatencyMS:   st.Result.MS,
+			IncidentID:  incidentID,
+		})
+	}
+
+	// Escalate if the service has been down or degraded long enough, and
+	// remind until the outage is acknowledged
+	m.escalate(serviceKey, serviceName, st, since)
+	m.remind(serviceKey, serviceName, st, since, config)
 }
 
-// CreateHTMLEmail generates a styled HTML email
-func CreateHTMLEmail(subject, statusType, serviceName, serviceKey, message, statusPageURL string) string {
+// stateName names a service status for notifications
+func stateName(ok, degraded bool) string {
+	switch {
+	case !ok:
+		return notify.StateDown
+	case degraded:
+		return notify.StateDegraded
+	}
+	return notify.StateUp
+}
+
+// CreateHTMLEmail generates a styled HTML email. A non-empty ackURL adds a
+// button that acknowledges the incident.
+func CreateHTMLEmail(subject, statusType, serviceName, serviceKey, message, statusPageURL, ackURL string) string {
 	// Status colors and text
 	statusColors := map[string]string{
 		"down":     "#ef4444",
@@ -152,6 +289,20 @@ func CreateHTMLEmail(subject, statusType, serviceName, serviceKey, message, stat
 		statusPageURL = "#"
 	}
 
+	ackButton := ""
+	if ackURL != "" {
+		ackButton = fmt.Sprintf(`
+                            <table width="100%%" cellpadding="0" cellspacing="0" style="margin-top: 12px;">
+                                <tr>
+                                    <td align="center">
+                                        <a href="%s" style="display: inline-block; background-color: #ffffff; color: #4b5563; text-decoration: none; padding: 12px 28px; border: 1px solid #d1d5db; border-radius: 6px; font-weight: 600; font-size: 14px;">
+                                            Acknowledge &amp; Stop Reminders
+                                        </a>
+                                    </td>
+                                </tr>
+                            </table>`, html.EscapeString(ackURL))
+	}
+
 	html := fmt.Sprintf(`<!DOCTYPE html>
 <html>
 <head>
@@ -217,7 +368,7 @@ func CreateHTMLEmail(subject, statusType, serviceName, serviceKey, message, stat
                                         </a>
                                     </td>
                                 </tr>
-                            </table>
+                            </table>%s
                         </td>
                     </tr>
                     
@@ -238,14 +389,7 @@ func CreateHTMLEmail(subject, statusType, serviceName, serviceKey, message, stat
         </tr>
     </table>
 </body>
-</html>`, subject, color, statusText, serviceName, message, serviceName, color, statusText, time.Now().Format("Monday, January 2, 2006 at 3:04 PM MST"), statusPageURL)
+</html>`, subject, color, statusText, serviceName, message, serviceName, color, statusText, time.Now().Format("Monday, January 2, 2006 at 3:04 PM MST"), statusPageURL, ackButton)
 
 	return html
 }
-
-func boolToInt(b bool) int {
-	if b {
-		return 1
-	}
-	return 0
-}
@@ -0,0 +1,129 @@
+package alerts
+
+import (
+	"path/filepath"
+	"status/app/internal/checker"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/state"
+	"testing"
+	"time"
+)
+
+// newTestManager opens an empty database for the test and returns a manager
+// without notification channels
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	if err := database.Init(database.DriverSQLite, filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	conn := database.DB
+	t.Cleanup(func() { conn.Close() })
+	return NewManager("", nil)
+}
+
+func TestIncidentsFollowStatusChanges(t *testing.T) {
+	m := newTestManager(t)
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	report := func(min int, ok bool, msg string) {
+		m.CheckAndSendAlerts("plex", "Plex", state.ServiceState{
+			OK:        ok,
+			CheckedAt: start.Add(time.Duration(min) * time.Minute),
+			Result:    checker.Result{Message: msg},
+		})
+	}
+	openID := func() int64 {
+		t.Helper()
+		id, err := database.OpenIncidentID("plex")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	incidents := func() []models.Incident {
+		t.Helper()
+		list, err := database.ListIncidents(start.Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+
+	// A service first seen up has nothing to report
+	report(0, true, "")
+	if list := incidents(); len(list) != 0 {
+		t.Fatalf("incidents while up = %+v", list)
+	}
+
+	// Going down opens an incident; staying down keeps it
+	report(1, false, "connection refused")
+	first := openID()
+	if first == 0 {
+		t.Fatal("no incident opened when the service went down")
+	}
+	report(2, false, "timeout")
+	if id := openID(); id != first {
+		t.Errorf("open incident = %d while still down, want %d", id, first)
+	}
+	if list := incidents(); len(list) != 1 {
+		t.Fatalf("incidents while down = %+v, want 1", list)
+	}
+
+	// Recovering resolves it, keeping the error that started it
+	report(5, true, "")
+	if id := openID(); id != 0 {
+		t.Errorf("incident %d still open after recovery", id)
+	}
+	list := incidents()
+	if len(list) != 1 {
+		t.Fatalf("incidents after recovery = %+v, want 1", list)
+	}
+	inc := list[0]
+	if inc.ID != first || !inc.Automatic || inc.Status != models.IncidentResolved || inc.Title != "Plex is down" ||
+		inc.RootError != "connection refused" || inc.Impact != models.ImpactMajor {
+		t.Errorf("resolved incident = %+v", inc)
+	}
+	if !inc.StartedAt.Equal(start.Add(time.Minute)) || inc.ResolvedAt == nil || !inc.ResolvedAt.Equal(start.Add(5*time.Minute)) {
+		t.Errorf("incident ran from %v to %v, want %v to %v", inc.StartedAt, inc.ResolvedAt, start.Add(time.Minute), start.Add(5*time.Minute))
+	}
+	if inc.DurationSeconds != 240 {
+		t.Errorf("DurationSeconds = %d, want 240", inc.DurationSeconds)
+	}
+	if len(inc.Services) != 1 || inc.Services[0].Key != "plex" {
+		t.Errorf("affected services = %+v, want plex", inc.Services)
+	}
+	// Updates are newest first
+	if len(inc.Updates) != 2 || inc.Updates[0].Status != models.IncidentResolved || inc.Updates[1].Status != models.IncidentInvestigating {
+		t.Errorf("updates = %+v, want investigating then resolved", inc.Updates)
+	}
+	report(6, true, "")
+	if list := incidents(); len(list) != 1 {
+		t.Errorf("incidents while up again = %+v, want 1", list)
+	}
+
+	// The next outage is a new incident
+	report(10, false, "no route to host")
+	second := openID()
+	if second == 0 || second == first {
+		t.Fatalf("second outage incident = %d, want a new one after %d", second, first)
+	}
+	if list := incidents(); len(list) != 2 || list[0].ID != second || list[0].RootError != "no route to host" || list[0].ResolvedAt != nil {
+		t.Errorf("incidents after the second outage = %+v", list)
+	}
+}
+
+func TestIncidentOpenedForServiceFirstSeenDown(t *testing.T) {
+	m := newTestManager(t)
+	at := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	m.CheckAndSendAlerts("nas", "NAS", state.ServiceState{CheckedAt: at, Result: checker.Result{Message: "timeout"}})
+
+	id, err := database.OpenIncidentID("nas")
+	if err != nil || id == 0 {
+		t.Fatalf("OpenIncidentID = %d, %v, want an open incident", id, err)
+	}
+	// Recovery is reported against the first status seen
+	m.CheckAndSendAlerts("nas", "NAS", state.ServiceState{OK: true, CheckedAt: at.Add(time.Minute)})
+	if id, err := database.OpenIncidentID("nas"); err != nil || id != 0 {
+		t.Errorf("OpenIncidentID after recovery = %d, %v, want none", id, err)
+	}
+}
@@ -0,0 +1,238 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/notify"
+	"sync"
+	"time"
+)
+
+// Delivery retries: a failed notification is retried after retryBase, then
+// after twice the previous delay up to retryMax, for maxAttempts attempts in
+// all (spread over about an hour)
+const (
+	maxAttempts = 8
+	retryBase   = 30 * time.Second
+	retryMax    = time.Hour
+
+	outboxPoll  = 15 * time.Second
+	outboxBatch = 50
+	outboxKeep  = 30 * 24 * time.Hour // delivered and abandoned notifications
+)
+
+// errUndeliverable marks failures that retrying cannot fix
+var errUndeliverable = errors.New("undeliverable")
+
+// backoff returns the delay before the retry following the given attempt
+func backoff(attempt int) time.Duration {
+	d := retryBase
+	for i := 1; i < attempt && d < retryMax; i++ {
+		d *= 2
+	}
+	return min(d, retryMax)
+}
+
+// target is a channel a notification is queued for
+type target struct {
+	id         int64 // 0 for email
+	name       string
+	recipients []string // email only; the alert email if empty
+}
+
+// targets returns email, if enabled, and every enabled notification channel
+func (m *Manager) targets() []target {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []target
+	if m.config != nil && m.config.Enabled {
+		out = append(out, target{id: 0, name: "email"})
+	}
+	for _, ch := range m.channels {
+		if ch.Enabled {
+			out = append(out, target{id: ch.ID, name: ch.Name})
+		}
+	}
+	return out
+}
+
+// notifier returns the notifier of a target with its current settings
+func (m *Manager) notifier(t target) (notify.Notifier, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if t.id == 0 {
+		if m.config == nil || !m.config.Enabled {
+			return nil, fmt.Errorf("%w: email alerts are disabled", errUndeliverable)
+		}
+		return &notify.Email{Config: m.config, To: t.recipients}, nil
+	}
+	for _, ch := range m.channels {
+		if ch.ID != t.id {
+			continue
+		}
+		if !ch.Enabled {
+			return nil, fmt.Errorf("%w: channel is disabled", errUndeliverable)
+		}
+		n, err := notify.New(ch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errUndeliverable, err)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("%w: channel was deleted", errUndeliverable)
+}
+
+// Send queues a notification for email, if enabled, and every enabled
+// channel. The outbox worker delivers it.
+func (m *Manager) Send(n *notify.Notification) {
+	m.queue(n, m.targets())
+}
+
+// queue adds a notification to the outbox once for each target and wakes
+// the outbox worker
+func (m *Manager) queue(n *notify.Notification, targets []target) {
+	if len(targets) == 0 {
+		return
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		log.Printf("alerts: encode %s alert for %s: %v", n.Event, n.ServiceKey, err)
+		return
+	}
+	for _, t := range targets {
+		e := &models.OutboxEntry{
+			ChannelID:  t.id,
+			Channel:    t.name,
+			Recipients: t.recipients,
+			Event:      n.Event,
+			ServiceKey: n.ServiceKey,
+			Payload:    string(payload),
+			Status:     models.DeliveryPending,
+		}
+		if _, err := database.EnqueueNotification(e); err != nil {
+			log.Printf("alerts: queue %s alert for %s via %s: %v", n.Event, n.ServiceKey, t.name, err)
+		}
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// logAttempt records a notification sent outside the outbox, such as a
+// test, in the delivery log
+func (m *Manager) logAttempt(channel string, n *notify.Notification, sendErr error) {
+	a := &models.NotificationAttempt{
+		Channel:     channel,
+		Event:       n.Event,
+		ServiceKey:  n.ServiceKey,
+		Attempt:     1,
+		Status:      models.DeliverySent,
+		AttemptedAt: time.Now(),
+	}
+	if sendErr != nil {
+		a.Status, a.Error = models.DeliveryFailed, sendErr.Error()
+	}
+	if err := database.RecordDirectAttempt(a); err != nil {
+		log.Printf("alerts: log %s notification via %s: %v", n.Event, channel, err)
+	}
+}
+
+// RunOutbox delivers queued notifications until ctx is cancelled, retrying
+// failed deliveries with exponential backoff. Notifications still queued at
+// shutdown are delivered after the next start.
+func (m *Manager) RunOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxPoll)
+	defer ticker.Stop()
+
+	var pruned time.Time
+	for {
+		if time.Since(pruned) >= time.Hour {
+			if _, err := database.PruneNotifications(time.Now().Add(-outboxKeep)); err != nil {
+				log.Printf("alerts: prune outbox: %v", err)
+			}
+			pruned = time.Now()
+		}
+		m.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.wake:
+		}
+	}
+}
+
+// deliverDue attempts every notification that is due, concurrently
+func (m *Manager) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		entries, err := database.DueNotifications(time.Now(), outboxBatch)
+		if err != nil {
+			log.Printf("alerts: read outbox: %v", err)
+			return
+		}
+		var wg sync.WaitGroup
+		for _, e := range entries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.deliver(ctx, e)
+			}()
+		}
+		wg.Wait()
+		if len(entries) < outboxBatch {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt at a queued notification and schedules a retry
+// if it fails
+func (m *Manager) deliver(ctx context.Context, e *models.OutboxEntry) {
+	var n notify.Notification
+	err := json.Unmarshal([]byte(e.Payload), &n)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errUndeliverable, err)
+	}
+	var notifier notify.Notifier
+	if err == nil {
+		notifier, err = m.notifier(target{id: e.ChannelID, name: e.Channel, recipients: e.Recipients})
+	}
+	if err == nil {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err = notifier.Notify(sendCtx, &n)
+		cancel()
+		if err != nil && ctx.Err() != nil {
+			// Interrupted by shutdown; try again after the restart
+			return
+		}
+	}
+
+	now := time.Now()
+	e.Attempts++
+	a := &models.NotificationAttempt{Attempt: e.Attempts, Status: models.DeliverySent, AttemptedAt: now}
+	switch {
+	case err == nil:
+		e.Status, e.LastError = models.DeliverySent, ""
+	case errors.Is(err, errUndeliverable) || e.Attempts >= maxAttempts:
+		e.Status, e.LastError = models.DeliveryFailed, err.Error()
+		a.Status, a.Error = models.DeliveryFailed, err.Error()
+		log.Printf("alerts: giving up on %s alert for %s via %s after %d attempts: %v", e.Event, e.ServiceKey, e.Channel, e.Attempts, err)
+	default:
+		delay := backoff(e.Attempts)
+		e.LastError, e.NextAttempt = err.Error(), now.Add(delay)
+		a.Status, a.Error = models.DeliveryFailed, err.Error()
+		log.Printf("alerts: send %s alert for %s via %s failed, retrying in %v: %v", e.Event, e.ServiceKey, e.Channel, delay, err)
+	}
+	if err := database.RecordNotificationAttempt(e, a); err != nil {
+		log.Printf("alerts: record delivery of %s alert for %s via %s: %v", e.Event, e.ServiceKey, e.Channel, err)
+	}
+}
@@ -0,0 +1,105 @@
+package alerts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/notify"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 16 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	m := newTestManager(t)
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	m.channels = []*models.NotificationChannel{{ID: 7, Name: "ops", Type: models.ChannelWebhook, Enabled: true, Settings: models.ChannelSettings{URL: srv.URL}}}
+
+	m.queue(&notify.Notification{Event: notify.EventDown, ServiceKey: "plex", ServiceName: "Plex"}, []target{{id: 7, name: "ops"}})
+	due := func() *models.OutboxEntry {
+		t.Helper()
+		entries, err := database.DueNotifications(time.Now().Add(2*time.Hour), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("%d notifications queued, want 1", len(entries))
+		}
+		return entries[0]
+	}
+
+	// Each failure pushes the next attempt out by the backoff schedule,
+	// until the last attempt gives up
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		e := due()
+		before := time.Now()
+		m.deliver(context.Background(), e)
+		if e.Attempts != attempt {
+			t.Fatalf("Attempts = %d, want %d", e.Attempts, attempt)
+		}
+		if attempt == maxAttempts {
+			if e.Status != models.DeliveryFailed || e.LastError == "" {
+				t.Errorf("after %d attempts: status %q, error %q, want failed", attempt, e.Status, e.LastError)
+			}
+			break
+		}
+		if e.Status != models.DeliveryPending {
+			t.Fatalf("attempt %d: status %q, want pending", attempt, e.Status)
+		}
+		if d := e.NextAttempt.Sub(before); d < backoff(attempt) || d > backoff(attempt)+time.Second {
+			t.Errorf("attempt %d: retry in %v, want %v", attempt, d, backoff(attempt))
+		}
+	}
+	if entries, err := database.DueNotifications(time.Now().Add(2*time.Hour), 10); err != nil || len(entries) != 0 {
+		t.Errorf("notifications still queued after giving up = %+v, %v", entries, err)
+	}
+	attempts, err := database.ListNotificationAttempts(models.DeliveryFailed, 20)
+	if err != nil || len(attempts) != maxAttempts {
+		t.Errorf("failed attempts logged = %d, %v, want %d", len(attempts), err, maxAttempts)
+	}
+
+	// A notification for a deleted channel is not retried
+	m.queue(&notify.Notification{Event: notify.EventUp, ServiceKey: "plex"}, []target{{id: 8, name: "gone"}})
+	e := due()
+	m.deliver(context.Background(), e)
+	if e.Attempts != 1 || e.Status != models.DeliveryFailed {
+		t.Errorf("undeliverable notification: %d attempts, status %q, want 1 and failed", e.Attempts, e.Status)
+	}
+
+	// A retry that gets through is delivered
+	status = http.StatusNoContent
+	m.queue(&notify.Notification{Event: notify.EventUp, ServiceKey: "plex"}, []target{{id: 7, name: "ops"}})
+	e = due()
+	m.deliver(context.Background(), e)
+	if e.Status != models.DeliverySent || e.LastError != "" {
+		t.Errorf("delivered notification: status %q, error %q, want sent", e.Status, e.LastError)
+	}
+}
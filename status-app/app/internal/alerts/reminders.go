@@ -0,0 +1,74 @@
+package alerts
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/url"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/notify"
+	"status/app/internal/state"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ackLinkTTL is how long an acknowledge link in an alert email stays valid
+const ackLinkTTL = 7 * 24 * time.Hour
+
+// remind repeats the down alert of a service that is still down every
+// reminder interval, until its incident is acknowledged or resolved
+func (m *Manager) remind(serviceKey, serviceName string, st state.ServiceState, since time.Time, config *models.AlertConfig) {
+	if st.OK || config.ReminderMinutes <= 0 || !m.wants(serviceKey, notify.EventDown, config.AlertOnDown) {
+		return
+	}
+	incidentID, err := database.OpenIncidentID(serviceKey)
+	if err != nil || incidentID == 0 {
+		if err != nil {
+			log.Printf("alerts: find incident of %s: %v", serviceKey, err)
+		}
+		return
+	}
+	due, err := database.ClaimReminder(incidentID, time.Duration(config.ReminderMinutes)*time.Minute, st.CheckedAt)
+	if err != nil {
+		log.Printf("alerts: remind of incident %d: %v", incidentID, err)
+		return
+	}
+	if due {
+		minutes := int(st.CheckedAt.Sub(since) / time.Minute)
+		m.route(m.reminder(serviceKey, serviceName, models.SeverityDown, minutes, st))
+	}
+}
+
+// AckURL returns the signed link that acknowledges an incident from an
+// alert email, or "" without a status page URL to link to
+func (m *Manager) AckURL(incidentID int64) string {
+	base := strings.TrimSuffix(m.GetStatusPageURL(), "/")
+	if base == "" || incidentID == 0 || len(m.ackSecret) == 0 {
+		return ""
+	}
+	exp := time.Now().Add(ackLinkTTL).Unix()
+	q := url.Values{
+		"id":  {strconv.FormatInt(incidentID, 10)},
+		"exp": {strconv.FormatInt(exp, 10)},
+		"sig": {m.signAck(incidentID, exp)},
+	}
+	return base + "/api/incidents/ack?" + q.Encode()
+}
+
+// VerifyAck checks the signature and expiry of an acknowledge link
+func (m *Manager) VerifyAck(incidentID, exp int64, sig string) bool {
+	if len(m.ackSecret) == 0 || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(m.signAck(incidentID, exp)))
+}
+
+func (m *Manager) signAck(incidentID, exp int64) string {
+	mac := hmac.New(sha256.New, m.ackSecret)
+	fmt.Fprintf(mac, "incident-ack:%d:%d", incidentID, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,88 @@
+package alerts
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseAckURL returns the id, expiry and signature of an acknowledge link
+func parseAckURL(t *testing.T, link string) (int64, int64, string) {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	id, err1 := strconv.ParseInt(q.Get("id"), 10, 64)
+	exp, err2 := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err1 != nil || err2 != nil || q.Get("sig") == "" {
+		t.Fatalf("malformed ack link %q", link)
+	}
+	return id, exp, q.Get("sig")
+}
+
+func TestAckURL(t *testing.T) {
+	m := &Manager{statusPageURL: "https://status.example.com/", ackSecret: []byte("0123456789abcdef0123456789abcdef")}
+
+	link := m.AckURL(42)
+	if !strings.HasPrefix(link, "https://status.example.com/api/incidents/ack?") {
+		t.Fatalf("AckURL = %q", link)
+	}
+	id, exp, sig := parseAckURL(t, link)
+	if id != 42 {
+		t.Errorf("id = %d, want 42", id)
+	}
+	if d := time.Until(time.Unix(exp, 0)); d < ackLinkTTL-time.Minute || d > ackLinkTTL {
+		t.Errorf("link expires in %v, want %v", d, ackLinkTTL)
+	}
+	if !m.VerifyAck(id, exp, sig) {
+		t.Error("valid link rejected")
+	}
+
+	for name, m := range map[string]*Manager{
+		"no status page URL": {ackSecret: m.ackSecret},
+		"no secret":          {statusPageURL: m.statusPageURL},
+	} {
+		if link := m.AckURL(42); link != "" {
+			t.Errorf("%s: AckURL = %q, want none", name, link)
+		}
+	}
+	if link := m.AckURL(0); link != "" {
+		t.Errorf("AckURL without an incident = %q", link)
+	}
+}
+
+func TestVerifyAck(t *testing.T) {
+	m := &Manager{statusPageURL: "https://status.example.com", ackSecret: []byte("0123456789abcdef0123456789abcdef")}
+	id, exp, sig := parseAckURL(t, m.AckURL(42))
+	past := time.Now().Add(-time.Minute).Unix()
+	tampered := sig[:len(sig)-1] + "A"
+	if tampered == sig {
+		tampered = sig[:len(sig)-1] + "B"
+	}
+
+	tests := []struct {
+		name    string
+		m       *Manager
+		id, exp int64
+		sig     string
+		want    bool
+	}{
+		{"valid link", m, id, exp, sig, true},
+		{"expired link", m, id, past, m.signAck(id, past), false},
+		{"wrong incident id", m, 43, exp, sig, false},
+		{"expiry moved out", m, id, exp + 3600, sig, false},
+		{"bad signature", m, id, exp, tampered, false},
+		{"empty signature", m, id, exp, "", false},
+		{"signed with another secret", &Manager{ackSecret: []byte("another secret of at least 32 bytes")}, id, exp, sig, false},
+		{"no secret", &Manager{}, id, exp, (&Manager{}).signAck(id, exp), false},
+	}
+	for _, tt := range tests {
+		if got := tt.m.VerifyAck(tt.id, tt.exp, tt.sig); got != tt.want {
+			t.Errorf("%s: VerifyAck = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package alerts
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"slices"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/notify"
+	"status/app/internal/state"
+	"strings"
+	"time"
+)
+
+// matchingRules returns the enabled rules that apply to an alert
+func (m *Manager) matchingRules(serviceKey, severity string) []*models.AlertRule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []*models.AlertRule
+	for _, r := range m.rules {
+		if r.Matches(serviceKey, severity) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// wants reports whether a status change is alerted: by a matching rule, or
+// by the global alert condition when no rule matches
+func (m *Manager) wants(serviceKey, severity string, condition bool) bool {
+	return condition || len(m.matchingRules(serviceKey, severity)) > 0
+}
+
+// route queues a status change alert for the immediate steps of the rules
+// matching it. Without a matching rule it goes to email and every channel.
+func (m *Manager) route(n *notify.Notification) {
+	rules := m.matchingRules(n.ServiceKey, n.Event)
+	if len(rules) == 0 {
+		m.Send(n)
+		return
+	}
+	var targets []target
+	for _, r := range rules {
+		for _, step := range r.Steps {
+			if step.AfterMinutes == 0 {
+				targets = append(targets, m.stepTargets(step)...)
+			}
+		}
+	}
+	m.queue(n, uniqueTargets(targets))
+}
+
+// escalate queues the delayed steps of the rules matching a down or
+// degraded service once it has been in that status for their delay. Each
+// step is sent once per outage.
+func (m *Manager) escalate(serviceKey, serviceName string, st state.ServiceState, since time.Time) {
+	if st.OK && !st.Degraded {
+		return
+	}
+	severity := models.SeverityDown
+	if st.OK {
+		severity = models.SeverityDegraded
+	}
+	elapsed := st.CheckedAt.Sub(since)
+
+	for _, r := range m.matchingRules(serviceKey, severity) {
+		for i, step := range r.Steps {
+			if step.AfterMinutes == 0 || elapsed < time.Duration(step.AfterMinutes)*time.Minute {
+				continue
+			}
+			claimed, err := database.ClaimEscalation(serviceKey, r.ID, i, since)
+			if err != nil {
+				log.Printf("alerts: escalate %s by rule %s: %v", serviceKey, r.Name, err)
+				continue
+			}
+			if claimed {
+				m.queue(m.reminder(serviceKey, serviceName, severity, step.AfterMinutes, st), m.stepTargets(step))
+			}
+		}
+	}
+}
+
+// reminder builds the alert that a service is still down or degraded, for
+// escalations and reminders
+func (m *Manager) reminder(serviceKey, serviceName, severity string, minutes int, st state.ServiceState) *notify.Notification {
+	var subject, text, message string
+	var incidentID int64
+	if severity == models.SeverityDown {
+		subject = fmt.Sprintf("🔴 Still Down after %s: %s", minutesText(minutes), serviceName)
+		text = fmt.Sprintf("The service %s has been down for %s and is still not responding to health checks.", serviceName, minutesText(minutes))
+		message = fmt.Sprintf("The service <strong>%s</strong> has been down for %s and is still not responding to health checks.", serviceName, minutesText(minutes))
+		var err error
+		if incidentID, err = database.OpenIncidentID(serviceKey); err != nil {
+			log.Printf("alerts: find incident of %s: %v", serviceKey, err)
+		}
+	} else {
+		subject = fmt.Sprintf("⚠️ Still Degraded after %s: %s", minutesText(minutes), serviceName)
+		text = fmt.Sprintf("The service %s has been degraded for %s: %s.", serviceName, minutesText(minutes), st.Reason)
+		message = fmt.Sprintf("The service <strong>%s</strong> has been degraded for %s: %s.", serviceName, minutesText(minutes), html.EscapeString(st.Reason))
+	}
+
+	statusPageURL := m.GetStatusPageURL()
+	return &notify.Notification{
+		Event:       severity,
+		ServiceKey:  serviceKey,
+		ServiceName: serviceName,
+		Title:       subject,
+		Message:     text,
+		HTML:        CreateHTMLEmail(subject, severity, serviceName, serviceKey, message, statusPageURL, m.AckURL(incidentID)),
+		URL:         statusPageURL,
+		Time:        st.CheckedAt,
+		OldState:    severity,
+		NewState:    severity,
+		LatencyMS:   st.Result.MS,
+		IncidentID:  incidentID,
+	}
+}
+
+// stepTargets returns the channels and email recipients of a step. Disabled
+// channels are included so the delivery log shows why they were skipped.
+func (m *Manager) stepTargets(step models.EscalationStep) []target {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []target
+	if len(step.Recipients) > 0 || slices.Contains(step.Channels, 0) {
+		name := "email"
+		if len(step.Recipients) > 0 {
+			name += ": " + strings.Join(step.Recipients, ", ")
+		}
+		out = append(out, target{id: 0, name: name, recipients: step.Recipients})
+	}
+	for _, id := range step.Channels {
+		if id == 0 {
+			continue
+		}
+		name := fmt.Sprintf("channel %d", id)
+		for _, ch := range m.channels {
+			if ch.ID == id {
+				name = ch.Name
+			}
+		}
+		out = append(out, target{id: id, name: name})
+	}
+	return out
+}
+
+// uniqueTargets drops targets listed more than once, e.g. by two rules
+func uniqueTargets(targets []target) []target {
+	seen := map[string]bool{}
+	out := targets[:0]
+	for _, t := range targets {
+		key := fmt.Sprint(t.id, t.recipients)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// minutesText formats a delay such as "5 minutes" or "2 hours"
+func minutesText(minutes int) string {
+	switch {
+	case minutes == 1:
+		return "1 minute"
+	case minutes < 60 || minutes%60 != 0:
+		return fmt.Sprintf("%d minutes", minutes)
+	case minutes == 60:
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", minutes/60)
+}
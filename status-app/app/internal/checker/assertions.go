@@ -0,0 +1,334 @@
+package checker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"status/app/internal/models"
+	"strconv"
+	"strings"
+)
+
+// Assertion types supported on HTTP response bodies
+const (
+	AssertContains    = "contains"
+	AssertNotContains = "not_contains"
+	AssertRegex       = "regex"
+	AssertJSONPath    = "jsonpath"
+)
+
+// maxAssertBody caps how much of a response body is read for assertions
+const maxAssertBody = 1 << 20
+
+// ValidateAssertions checks that every assertion has a known type and a
+// well-formed value, so bad definitions are rejected when saved rather
+// than reported as service failures
+func ValidateAssertions(list []models.Assertion) error {
+	for i, a := range list {
+		if err := validateAssertion(a); err != nil {
+			return fmt.Errorf("assertions[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateAssertion(a models.Assertion) error {
+	if a.Value == "" {
+		return errors.New("value required")
+	}
+	switch a.Type {
+	case AssertContains, AssertNotContains:
+		return nil
+	case AssertRegex:
+		_, err := regexp.Compile(a.Value)
+		return err
+	case AssertJSONPath:
+		_, err := parseJSONPathExpr(a.Value)
+		return err
+	default:
+		return fmt.Errorf("unknown type %q", a.Type)
+	}
+}
+
+// checkAssertions evaluates the assertions against a response body and
+// returns a description of the first one that fails. truncated means body
+// is only the first maxAssertBody bytes, so a not_contains assertion can't
+// pass.
+func checkAssertions(body []byte, truncated bool, list []models.Assertion) error {
+	var doc any
+	var docErr error
+	docParsed := false
+
+	for _, a := range list {
+		switch a.Type {
+		case AssertContains:
+			if !bytes.Contains(body, []byte(a.Value)) {
+				return fmt.Errorf("assertion failed: body does not contain %q", a.Value)
+			}
+		case AssertNotContains:
+			if bytes.Contains(body, []byte(a.Value)) {
+				return fmt.Errorf("assertion failed: body contains %q", a.Value)
+			}
+			if truncated {
+				return fmt.Errorf("assertion failed: body is over %d bytes, can't check that it does not contain %q", maxAssertBody, a.Value)
+			}
+		case AssertRegex:
+			re, err := regexp.Compile(a.Value)
+			if err != nil {
+				return fmt.Errorf("assertion failed: invalid regex %q", a.Value)
+			}
+			if !re.Match(body) {
+				return fmt.Errorf("assertion failed: body does not match /%s/", a.Value)
+			}
+		case AssertJSONPath:
+			if !docParsed {
+				docParsed = true
+				dec := json.NewDecoder(bytes.NewReader(body))
+				dec.UseNumber()
+				docErr = dec.Decode(&doc)
+			}
+			if docErr != nil {
+				return fmt.Errorf("assertion failed: %s: body is not valid JSON", a.Value)
+			}
+			expr, err := parseJSONPathExpr(a.Value)
+			if err != nil {
+				return fmt.Errorf("assertion failed: %s: %v", a.Value, err)
+			}
+			if err := expr.eval(doc); err != nil {
+				return fmt.Errorf("assertion failed: %s: %v", a.Value, err)
+			}
+		default:
+			return fmt.Errorf("assertion failed: unknown type %q", a.Type)
+		}
+	}
+	return nil
+}
+
+// jsonPathExpr is a parsed "<path> [<op> <literal>]" expression such as
+// `$.commitTag != null` or `$.servers[0].status == "ok"`. Without an
+// operator the path must resolve to a non-null value.
+type jsonPathExpr struct {
+	path    []any // string keys and int indexes
+	op      string
+	literal any // nil, bool, float64 or string
+}
+
+var jsonPathOps = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+func parseJSONPathExpr(s string) (*jsonPathExpr, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "$") {
+		return nil, errors.New("jsonpath must start with $")
+	}
+
+	pathStr, rest := s, ""
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' || s[i] == '"' {
+			// Skip quoted keys so operators inside them aren't matched
+			if j := strings.IndexByte(s[i+1:], s[i]); j >= 0 {
+				i += j + 1
+			}
+			continue
+		}
+		if strings.ContainsRune("=!<>", rune(s[i])) {
+			pathStr, rest = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i:])
+			break
+		}
+	}
+
+	expr := &jsonPathExpr{}
+	path, err := parseJSONPath(pathStr)
+	if err != nil {
+		return nil, err
+	}
+	expr.path = path
+
+	if rest == "" {
+		return expr, nil
+	}
+	for _, op := range jsonPathOps {
+		if strings.HasPrefix(rest, op) {
+			expr.op = op
+			rest = strings.TrimSpace(rest[len(op):])
+			break
+		}
+	}
+	if expr.op == "" {
+		return nil, fmt.Errorf("unknown operator in %q", rest)
+	}
+	lit, err := parseLiteral(rest)
+	if err != nil {
+		return nil, err
+	}
+	if _, isNum := lit.(float64); !isNum && expr.op != "==" && expr.op != "!=" {
+		return nil, fmt.Errorf("operator %s needs a number", expr.op)
+	}
+	expr.literal = lit
+	return expr, nil
+}
+
+func parseJSONPath(s string) ([]any, error) {
+	var path []any
+	i := 1 // skip $
+	for i < len(s) {
+		switch s[i] {
+		case '.':
+			j := i + 1
+			for j < len(s) && s[j] != '.' && s[j] != '[' {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("empty key at offset %d", i)
+			}
+			if key := s[i+1 : j]; strings.ContainsAny(key, " \t*?@()'\"~") {
+				return nil, fmt.Errorf("invalid key %q, quote it as ['...']", key)
+			}
+			path = append(path, s[i+1:j])
+			i = j
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated [")
+			}
+			inner := strings.TrimSpace(s[i+1 : i+end])
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				path = append(path, inner[1:len(inner)-1])
+			} else if n, err := strconv.Atoi(inner); err == nil && n >= 0 {
+				path = append(path, n)
+			} else {
+				return nil, fmt.Errorf("invalid index [%s]", inner)
+			}
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", s[i], i)
+		}
+	}
+	return path, nil
+}
+
+func parseLiteral(s string) (any, error) {
+	switch s {
+	case "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid literal %q", s)
+}
+
+// resolve walks the path; found is false if any segment is missing
+func (e *jsonPathExpr) resolve(doc any) (v any, found bool) {
+	v = doc
+	for _, seg := range e.path {
+		switch key := seg.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = m[key]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := v.([]any)
+			if !ok || key >= len(arr) {
+				return nil, false
+			}
+			v = arr[key]
+		}
+	}
+	return v, true
+}
+
+func (e *jsonPathExpr) eval(doc any) error {
+	v, found := e.resolve(doc)
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("invalid number %s", n)
+		}
+		v = f
+	}
+
+	if e.op == "" {
+		if !found || v == nil {
+			return errors.New("value is missing or null")
+		}
+		return nil
+	}
+
+	switch e.op {
+	case "==":
+		if !jsonEqual(v, e.literal) {
+			return fmt.Errorf("got %s", describe(v, found))
+		}
+	case "!=":
+		if jsonEqual(v, e.literal) {
+			return fmt.Errorf("got %s", describe(v, found))
+		}
+	default:
+		got, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("got %s, want a number", describe(v, found))
+		}
+		want := e.literal.(float64)
+		var pass bool
+		switch e.op {
+		case ">":
+			pass = got > want
+		case ">=":
+			pass = got >= want
+		case "<":
+			pass = got < want
+		case "<=":
+			pass = got <= want
+		}
+		if !pass {
+			return fmt.Errorf("got %v", got)
+		}
+	}
+	return nil
+}
+
+// jsonEqual compares a decoded JSON value with a literal. A missing value
+// compares equal to null.
+func jsonEqual(v, lit any) bool {
+	switch l := lit.(type) {
+	case nil:
+		return v == nil
+	case bool:
+		b, ok := v.(bool)
+		return ok && b == l
+	case float64:
+		f, ok := v.(float64)
+		return ok && f == l
+	case string:
+		s, ok := v.(string)
+		return ok && s == l
+	}
+	return false
+}
+
+func describe(v any, found bool) string {
+	if !found {
+		return "missing value"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	if len(b) > 80 {
+		return string(b[:80]) + "…"
+	}
+	return string(b)
+}
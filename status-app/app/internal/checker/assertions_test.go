@@ -0,0 +1,208 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"status/app/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJSONPathExpr(t *testing.T) {
+	tests := []struct {
+		expr    string
+		path    []any
+		op      string
+		literal any
+		err     string
+	}{
+		{expr: "$", path: nil},
+		{expr: "$.a.b", path: []any{"a", "b"}},
+		{expr: " $.commitTag != null ", path: []any{"commitTag"}, op: "!=", literal: nil},
+		{expr: `$.servers[0].status == "ok"`, path: []any{"servers", 0, "status"}, op: "==", literal: "ok"},
+		{expr: `$.servers[ 12 ]`, path: []any{"servers", 12}},
+		{expr: `$['odd key'].x == 'single'`, path: []any{"odd key", "x"}, op: "==", literal: "single"},
+		{expr: `$["a==b"] != null`, path: []any{"a==b"}, op: "!=", literal: nil},
+		{expr: "$.n >= 10", path: []any{"n"}, op: ">=", literal: 10.0},
+		{expr: "$.n<-1.5", path: []any{"n"}, op: "<", literal: -1.5},
+		{expr: "$.n > 0", path: []any{"n"}, op: ">", literal: 0.0},
+		{expr: "$.n <= 1e3", path: []any{"n"}, op: "<=", literal: 1000.0},
+		{expr: "$.ok == true", path: []any{"ok"}, op: "==", literal: true},
+		{expr: "$.ok != false", path: []any{"ok"}, op: "!=", literal: false},
+
+		{expr: "", err: "must start with $"},
+		{expr: "a.b", err: "must start with $"},
+		{expr: "$a", err: "unexpected 'a' at offset 1"},
+		{expr: "$.", err: "empty key"},
+		{expr: "$..a", err: "empty key"},
+		{expr: "$.a[0", err: "unterminated ["},
+		{expr: "$.a[-1]", err: "invalid index"},
+		{expr: "$.a[*]", err: "invalid index"},
+		{expr: "$.a['x]", err: "invalid index"},
+		{expr: "$.a[?(@.x == 1)]", err: "unterminated ["}, // filters are not supported
+		{expr: "$.a.*", err: "invalid key"},
+		{expr: "$.version ~ 1", err: "invalid key"},
+		{expr: "$.a b == 1", err: "invalid key"},
+		{expr: "$.a => 1", err: "unknown operator"},
+		{expr: "$.a =", err: "unknown operator"},
+		{expr: "$.a ==", err: "invalid literal"},
+		{expr: "$.a == nope", err: "invalid literal"},
+		{expr: `$.a == "open`, err: "invalid literal"},
+		{expr: `$.a > "x"`, err: "needs a number"},
+		{expr: "$.a <= null", err: "needs a number"},
+	}
+	for _, tt := range tests {
+		got, err := parseJSONPathExpr(tt.expr)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parse(%q) error = %v, want %q", tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.path, tt.path) || got.op != tt.op || got.literal != tt.literal {
+			t.Errorf("parse(%q) = %v %q %v, want %v %q %v", tt.expr, got.path, got.op, got.literal, tt.path, tt.op, tt.literal)
+		}
+	}
+}
+
+func TestCheckAssertions(t *testing.T) {
+	body := []byte(`{"version": "1.2.3", "commitTag": null, "count": 3, "enabled": true,
+		"servers": [{"status": "ok", "load": 0.5}, {"status": "down", "load": 2}],
+		"odd key": {"x": 1}}`)
+
+	tests := []struct {
+		typ, value string
+		err        string // "" if the assertion passes
+	}{
+		{AssertContains, `"1.2.3"`, ""},
+		{AssertContains, "nope", `body does not contain "nope"`},
+		{AssertNotContains, "error", ""},
+		{AssertNotContains, "down", `body contains "down"`},
+		{AssertRegex, `"version": "\d+\.\d+`, ""},
+		{AssertRegex, `^\[`, "does not match"},
+
+		{AssertJSONPath, "$.version", ""},
+		{AssertJSONPath, "$.servers[1]", ""},
+		{AssertJSONPath, "$.commitTag", "missing or null"},
+		{AssertJSONPath, "$.missing", "missing or null"},
+		{AssertJSONPath, "$.servers[2]", "missing or null"},
+		{AssertJSONPath, "$.servers.status", "missing or null"},
+		{AssertJSONPath, "$.version[0]", "missing or null"},
+		{AssertJSONPath, "$.commitTag == null", ""},
+		{AssertJSONPath, "$.missing == null", ""},
+		{AssertJSONPath, "$.commitTag != null", "got null"},
+		{AssertJSONPath, `$.servers[0].status == "ok"`, ""},
+		{AssertJSONPath, `$.servers[1].status == 'ok'`, `got "down"`},
+		{AssertJSONPath, `$.servers[1].status != "ok"`, ""},
+		{AssertJSONPath, `$.missing == "ok"`, "got missing value"},
+		{AssertJSONPath, `$.count == "3"`, "got 3"},
+		{AssertJSONPath, "$.count == 3", ""},
+		{AssertJSONPath, "$.count != 3", "got 3"},
+		{AssertJSONPath, "$.count > 2", ""},
+		{AssertJSONPath, "$.count > 3", "got 3"},
+		{AssertJSONPath, "$.count >= 3", ""},
+		{AssertJSONPath, "$.count < 4", ""},
+		{AssertJSONPath, "$.count < 3", "got 3"},
+		{AssertJSONPath, "$.count <= 3", ""},
+		{AssertJSONPath, "$.servers[0].load < 1", ""},
+		{AssertJSONPath, "$.servers[1].load >= 2.5", "got 2"},
+		{AssertJSONPath, "$.version > 1", "want a number"},
+		{AssertJSONPath, "$.missing > 1", "got missing value, want a number"},
+		{AssertJSONPath, "$.enabled == true", ""},
+		{AssertJSONPath, "$.enabled != false", ""},
+		{AssertJSONPath, "$.enabled == false", "got true"},
+		{AssertJSONPath, "$['odd key'].x == 1", ""},
+
+		{"xpath", "/a", `unknown type "xpath"`},
+	}
+	for _, tt := range tests {
+		err := checkAssertions(body, false, []models.Assertion{{Type: tt.typ, Value: tt.value}})
+		if tt.err == "" && err != nil {
+			t.Errorf("%s %s: %v", tt.typ, tt.value, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s %s: error = %v, want %q", tt.typ, tt.value, err, tt.err)
+		}
+	}
+}
+
+func TestCheckAssertionsReportsTheFirstFailure(t *testing.T) {
+	err := checkAssertions([]byte(`{"a": 1}`), false, []models.Assertion{
+		{Type: AssertContains, Value: `"a"`},
+		{Type: AssertJSONPath, Value: "$.a == 2"},
+		{Type: AssertNotContains, Value: "a"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "$.a == 2: got 1") {
+		t.Errorf("error = %v", err)
+	}
+
+	err = checkAssertions([]byte("<html>"), false, []models.Assertion{{Type: AssertJSONPath, Value: "$.a"}})
+	if err == nil || !strings.Contains(err.Error(), "body is not valid JSON") {
+		t.Errorf("jsonpath on HTML: error = %v", err)
+	}
+}
+
+func TestValidateAssertions(t *testing.T) {
+	valid := []models.Assertion{
+		{Type: AssertContains, Value: "ok"},
+		{Type: AssertNotContains, Value: "error"},
+		{Type: AssertRegex, Value: `^\{`},
+		{Type: AssertJSONPath, Value: "$.status == 'ok'"},
+	}
+	if err := ValidateAssertions(valid); err != nil {
+		t.Errorf("valid assertions: %v", err)
+	}
+
+	for _, a := range []models.Assertion{
+		{Type: AssertContains},
+		{Type: AssertRegex, Value: "("},
+		{Type: AssertJSONPath, Value: "status"},
+		{Type: "xpath", Value: "/a"},
+	} {
+		if err := ValidateAssertions(append(valid, a)); err == nil || !strings.HasPrefix(err.Error(), "assertions[4]: ") {
+			t.Errorf("ValidateAssertions(%+v) = %v, want an error for assertions[4]", a, err)
+		}
+	}
+}
+
+func TestNotContainsOnTruncatedBody(t *testing.T) {
+	body := []byte("all good")
+	if err := checkAssertions(body, true, []models.Assertion{{Type: AssertNotContains, Value: "error"}}); err == nil ||
+		!strings.Contains(err.Error(), "can't check that it does not contain") {
+		t.Errorf("not_contains on a truncated body: %v", err)
+	}
+	// The other assertions only need the part that was read
+	if err := checkAssertions(body, true, []models.Assertion{{Type: AssertContains, Value: "good"}}); err != nil {
+		t.Errorf("contains on a truncated body: %v", err)
+	}
+}
+
+func TestHTTPCheckTruncatedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := "status: ok\n"
+		if r.URL.Path == "/large" {
+			// The forbidden text sits past the part read for assertions
+			body += strings.Repeat(".", maxAssertBody) + "ERROR"
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	for path, up := range map[string]bool{"/small": true, "/large": false} {
+		s := &models.Service{Key: "web", URL: srv.URL + path, MinOK: 200, MaxOK: 399,
+			Assertions: []models.Assertion{{Type: AssertContains, Value: "ok"}, {Type: AssertNotContains, Value: "ERROR"}}}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		res := httpChecker{}.Check(ctx, s)
+		cancel()
+		if got := res.Status == StatusUp; got != up {
+			t.Errorf("%s: up = %v, want %v (message %q)", path, got, up, res.Message)
+		}
+	}
+}
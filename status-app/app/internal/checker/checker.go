@@ -1,50 +1,227 @@
 package checker
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"net"
-	"net/http"
+	"net/url"
+	"slices"
 	"status/app/internal/models"
 	"strings"
+	"sync"
 	"time"
 )
 
-// HTTPCheck performs a health check on a service
-func HTTPCheck(url string, timeout time.Duration, minOK, maxOK int) (ok bool, code int, ms *int, errStr string) {
-	if strings.HasPrefix(url, "tcp://") {
-		addr := strings.TrimPrefix(url, "tcp://")
-		t0 := time.Now()
-		conn, err := net.DialTimeout("tcp", addr, timeout)
-		d := int(time.Since(t0).Milliseconds())
-		ms = &d
-		if err != nil {
-			log.Printf("tcp check error addr=%s err=%v", addr, err)
-			return false, 0, nil, err.Error()
-		}
-		_ = conn.Close()
-		return true, 0, ms, ""
+// Status is the outcome of a single probe, before any failure tolerance
+// is applied
+type Status string
+
+// Probe outcomes
+const (
+	StatusUp       Status = "up"
+	StatusDegraded Status = "degraded" // the check passed but something needs attention (e.g. certificate nearing expiry)
+	StatusDown     Status = "down"
+)
+
+// Well-known metadata keys
+const (
+	MetaCert = "cert" // *models.CertInfo from tls:// checks
+	MetaPing = "ping" // *models.PingStats from icmp:// checks
+)
+
+// Result is the outcome of a single service check
+type Result struct {
+	Status  Status
+	Code    int                   // protocol status code (the HTTP status), 0 when not applicable
+	MS      *int                  // latency in milliseconds, nil when no response was received
+	Message string                // why the service is down or degraded
+	Meta    map[string]any        // probe-specific details, stored as JSON with the sample
+	Phases  *models.LatencyPhases // HTTP latency breakdown, nil for other probes
+
+	Attempts      int      // tries it took, including retries
+	AttemptErrors []string // messages of the failed attempts, in order
+}
+
+// OK reports whether the probe passed, degraded or not
+func (r Result) OK() bool {
+	return r.Status == StatusUp || r.Status == StatusDegraded
+}
+
+// Degraded reports whether the probe passed with a warning
+func (r Result) Degraded() bool {
+	return r.Status == StatusDegraded
+}
+
+// Retried reports whether the probe only passed after a retry
+func (r Result) Retried() bool {
+	return r.OK() && r.Attempts > 1
+}
+
+// Cert returns the certificate recorded by a tls:// check, if any
+func (r Result) Cert() *models.CertInfo {
+	c, _ := r.Meta[MetaCert].(*models.CertInfo)
+	return c
+}
+
+// Sample converts the result into a sample row for the given service.
+// ok is the service status after failure tolerance has been applied.
+func (r Result) Sample(ts time.Time, key string, ok bool) models.Sample {
+	return models.Sample{
+		TakenAt:       ts,
+		ServiceKey:    key,
+		OK:            ok,
+		CheckStatus:   string(r.Status),
+		HTTPStatus:    r.Code,
+		MS:            r.MS,
+		Message:       r.Message,
+		Meta:          r.Meta,
+		Phases:        r.Phases,
+		Attempts:      r.Attempts,
+		AttemptErrors: r.AttemptErrors,
 	}
+}
 
-	client := &http.Client{Timeout: timeout}
-	t0 := time.Now()
-	resp, err := client.Get(url)
-	d := int(time.Since(t0).Milliseconds())
-	ms = &d
+// Checker probes services whose URL uses a particular scheme
+type Checker interface {
+	Check(ctx context.Context, s *models.Service) Result
+}
+
+// Validator is implemented by checkers that need to validate scheme-specific
+// parts of a service definition before it is saved
+type Validator interface {
+	Validate(s *models.Service) error
+}
+
+var (
+	mu       sync.RWMutex
+	checkers = map[string]Checker{}
+)
+
+// Register makes a checker available for a URL scheme, replacing any
+// checker previously registered for it
+func Register(scheme string, c Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+	checkers[strings.ToLower(scheme)] = c
+}
+
+// Lookup returns the checker registered for a URL scheme
+func Lookup(scheme string) (Checker, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := checkers[strings.ToLower(scheme)]
+	return c, ok
+}
+
+// Schemes lists the registered URL schemes in sorted order
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]string, 0, len(checkers))
+	for scheme := range checkers {
+		out = append(out, scheme)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Validate checks that a service uses a registered scheme and lets the
+// checker validate its own options
+func Validate(s *models.Service) error {
+	u, err := url.Parse(s.URL)
 	if err != nil {
-		log.Printf("http check error url=%s err=%v", url, err)
-		return false, 0, nil, err.Error()
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	c, ok := Lookup(u.Scheme)
+	if !ok {
+		return fmt.Errorf("unsupported url scheme %q (supported: %s)", u.Scheme, strings.Join(Schemes(), ", "))
+	}
+	if v, ok := c.(Validator); ok {
+		return v.Validate(s)
 	}
-	defer resp.Body.Close()
-	ok = resp.StatusCode >= minOK && resp.StatusCode <= maxOK
-	return ok, resp.StatusCode, ms, ""
+	return nil
 }
 
-// FindServiceByKey finds a service in the slice by its key
-func FindServiceByKey(services []*models.Service, key string) *models.Service {
-	for _, s := range services {
-		if s.Key == key {
-			return s
+// DefaultRetryDelay is the pause between attempts for services that retry
+// without setting their own delay
+const DefaultRetryDelay = 2 * time.Second
+
+// Run checks a service using the checker registered for its URL scheme.
+// A failed attempt is retried up to s.Retries times, s.RetryDelay apart,
+// and every attempt is bounded by the service timeout. The result is that of
+// the last attempt.
+func Run(ctx context.Context, s *models.Service) Result {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return Result{Status: StatusDown, Message: "invalid url: " + err.Error(), Attempts: 1}
+	}
+	c, ok := Lookup(u.Scheme)
+	if !ok {
+		return Result{Status: StatusDown, Message: fmt.Sprintf("unsupported url scheme %q", u.Scheme), Attempts: 1}
+	}
+
+	delay := s.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	var errs []string
+	for attempt := 1; ; attempt++ {
+		res := runOnce(ctx, c, s)
+		if !res.OK() {
+			errs = append(errs, res.Message)
+		}
+		if res.OK() || attempt > s.Retries || !sleep(ctx, delay) {
+			res.Attempts = attempt
+			res.AttemptErrors = errs
+			return res
 		}
 	}
-	return nil
+}
+
+func runOnce(ctx context.Context, c Checker, s *models.Service) Result {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	return c.Check(ctx, s)
+}
+
+// sleep waits for d and reports whether it did so before ctx was cancelled
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func elapsedMS(t0 time.Time) *int {
+	d := int(time.Since(t0).Milliseconds())
+	return &d
+}
+
+// validHost reports whether h is an IP address or a well-formed host name
+func validHost(h string) bool {
+	if net.ParseIP(h) != nil {
+		return true
+	}
+	h = strings.TrimSuffix(h, ".")
+	if h == "" || len(h) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(h, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
 }
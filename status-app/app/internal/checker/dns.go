@@ -0,0 +1,245 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"slices"
+	"status/app/internal/models"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func init() {
+	Register("dns", dnsChecker{})
+}
+
+// dnsChecker queries a specific resolver given as dns://resolver[:port]/name.
+// Query parameters:
+//
+//	type   - record type: A (default), AAAA, CNAME, TXT or MX
+//	expect - comma-separated values that must all appear in the answer
+//
+// Without expect, the check passes when the resolver returns any record.
+// The recorded latency is the query round-trip time, and the answers are
+// kept in the sample metadata.
+type dnsChecker struct{}
+
+// dnsTarget is what a dns:// URL asks to check
+type dnsTarget struct {
+	server     string
+	name       string
+	recordType string
+	expect     []string
+}
+
+func parseDNSURL(raw string) (dnsTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return dnsTarget{}, fmt.Errorf("invalid dns url: %w", err)
+	}
+	if !validHost(u.Hostname()) {
+		return dnsTarget{}, errors.New("dns url must include the resolver to query, e.g. dns://10.0.0.1/plex.lan")
+	}
+	t := dnsTarget{server: u.Host, name: strings.Trim(u.Path, "/")}
+	if u.Port() == "" {
+		t.server = net.JoinHostPort(u.Hostname(), "53")
+	}
+	if t.name == "" {
+		return dnsTarget{}, errors.New("dns url must include the name to resolve, e.g. dns://10.0.0.1/plex.lan")
+	}
+	if fqdn := strings.TrimSuffix(t.name, ".") + "."; strings.Contains(fqdn, "..") || len(fqdn) > 254 {
+		return dnsTarget{}, fmt.Errorf("invalid name %q", t.name)
+	}
+	t.recordType = strings.ToUpper(u.Query().Get("type"))
+	if t.recordType == "" {
+		t.recordType = "A"
+	}
+	if _, ok := dnsTypes[t.recordType]; !ok {
+		return dnsTarget{}, fmt.Errorf("unsupported record type %q (supported: %s)", t.recordType, strings.Join(slices.Sorted(maps.Keys(dnsTypes)), ", "))
+	}
+	for _, v := range strings.Split(u.Query().Get("expect"), ",") {
+		if t.recordType == "TXT" {
+			v = strings.TrimSpace(v)
+		} else {
+			v = normalizeDNSValue(v)
+		}
+		if v != "" {
+			t.expect = append(t.expect, v)
+		}
+	}
+	return t, nil
+}
+
+func (dnsChecker) Validate(s *models.Service) error {
+	_, err := parseDNSURL(s.URL)
+	return err
+}
+
+func (dnsChecker) Check(ctx context.Context, s *models.Service) Result {
+	t, err := parseDNSURL(s.URL)
+	if err != nil {
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+	server, name, recordType := t.server, t.name, t.recordType
+
+	t0 := time.Now()
+	answers, err := queryDNS(ctx, server, recordType, name)
+	ms := elapsedMS(t0)
+	if err != nil {
+		log.Printf("dns check error server=%s name=%s type=%s err=%v", server, name, recordType, err)
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+	res := Result{Status: StatusDown, MS: ms, Meta: map[string]any{"type": recordType, "answers": answers}}
+	if len(answers) == 0 {
+		res.Message = fmt.Sprintf("no %s records for %s", recordType, name)
+		return res
+	}
+	for _, want := range t.expect {
+		if !slices.Contains(answers, want) {
+			res.Message = fmt.Sprintf("%s %s: expected %s, got %s", recordType, name, want, strings.Join(answers, ", "))
+			return res
+		}
+	}
+	res.Status = StatusUp
+	return res
+}
+
+// dnsTypes maps the supported record types to their query types
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"TXT":   dnsmessage.TypeTXT,
+	"MX":    dnsmessage.TypeMX,
+}
+
+// queryDNS sends one recursive query for name to server and returns the
+// answers of the requested type. The query is built and sent directly so
+// it always reaches the server: the system resolver could answer from
+// /etc/hosts or skip the server for IP literals. A truncated UDP answer is
+// retried over TCP.
+func queryDNS(ctx context.Context, server, recordType, name string) ([]string, error) {
+	qtype, ok := dnsTypes[recordType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported record type %q", recordType)
+	}
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %q: %w", name, err)
+	}
+	id := uint16(rand.Uint32())
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := exchangeDNS(ctx, "udp", server, query)
+	if err == nil && resp.Truncated {
+		resp, err = exchangeDNS(ctx, "tcp", server, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.ID != id || !resp.Response {
+		return nil, errors.New("dns: mismatched response")
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, fmt.Errorf("%s: no such host", name)
+	default:
+		return nil, fmt.Errorf("dns: server answered %s", strings.TrimPrefix(resp.RCode.String(), "RCode"))
+	}
+
+	var out []string
+	var mxs []*dnsmessage.MXResource
+	for _, rr := range resp.Answers {
+		if rr.Header.Type != qtype {
+			// e.g. the CNAME records leading to the A records asked for
+			continue
+		}
+		switch b := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			out = append(out, net.IP(b.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			out = append(out, net.IP(b.AAAA[:]).String())
+		case *dnsmessage.CNAMEResource:
+			out = append(out, normalizeDNSValue(b.CNAME.String()))
+		case *dnsmessage.TXTResource:
+			out = append(out, strings.Join(b.TXT, ""))
+		case *dnsmessage.MXResource:
+			mxs = append(mxs, b)
+		}
+	}
+	slices.SortStableFunc(mxs, func(a, b *dnsmessage.MXResource) int { return int(a.Pref) - int(b.Pref) })
+	for _, mx := range mxs {
+		out = append(out, normalizeDNSValue(mx.MX.String()))
+	}
+	return out, nil
+}
+
+// exchangeDNS sends a packed query over udp or tcp and parses the response
+func exchangeDNS(ctx context.Context, network, server string, query []byte) (*dnsmessage.Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	buf := make([]byte, 65535)
+	var n int
+	if network == "tcp" {
+		// TCP messages are prefixed with their length
+		if _, err := conn.Write(append([]byte{byte(len(query) >> 8), byte(len(query))}, query...)); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return nil, err
+		}
+		n = int(buf[0])<<8 | int(buf[1])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		if n, err = conn.Read(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf[:n]); err != nil {
+		return nil, fmt.Errorf("dns: bad response: %w", err)
+	}
+	return &resp, nil
+}
+
+// normalizeDNSValue makes host names comparable regardless of case or a trailing dot
+func normalizeDNSValue(v string) string {
+	v = strings.TrimSpace(v)
+	if ip := net.ParseIP(v); ip != nil {
+		return ip.String()
+	}
+	return strings.TrimSuffix(strings.ToLower(v), ".")
+}
@@ -0,0 +1,269 @@
+package checker
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"slices"
+	"status/app/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS is an in-process DNS server answering over UDP and TCP on the
+// same port from a fixed zone
+type fakeDNS struct {
+	addr     string
+	zone     map[string][]dnsmessage.Resource // by lower-case name and type
+	truncate bool                             // answer UDP queries truncated, forcing TCP
+	queries  chan string                      // "network name type" of every query
+}
+
+func startFakeDNS(t *testing.T, zone []dnsmessage.Resource, truncate bool) *fakeDNS {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close(); ln.Close() })
+
+	f := &fakeDNS{addr: pc.LocalAddr().String(), zone: map[string][]dnsmessage.Resource{}, truncate: truncate, queries: make(chan string, 100)}
+	for _, rr := range zone {
+		key := strings.ToLower(rr.Header.Name.String()) + " " + rr.Header.Type.String()
+		f.zone[key] = append(f.zone[key], rr)
+	}
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := f.answer(buf[:n], "udp"); resp != nil {
+				_, _ = pc.WriteTo(resp, from)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var size [2]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				if resp := f.answer(query, "tcp"); resp != nil {
+					_, _ = conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp))))
+					_, _ = conn.Write(resp)
+				}
+			}()
+		}
+	}()
+	return f
+}
+
+func (f *fakeDNS) answer(query []byte, network string) []byte {
+	var q dnsmessage.Message
+	if err := q.Unpack(query); err != nil || len(q.Questions) != 1 {
+		return nil
+	}
+	question := q.Questions[0]
+	f.queries <- network + " " + strings.ToLower(question.Name.String()) + " " + question.Type.String()
+
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.ID, Response: true, RecursionAvailable: true},
+		Questions: q.Questions,
+	}
+	name := strings.ToLower(question.Name.String())
+	if f.truncate && network == "udp" {
+		resp.Truncated = true
+	} else if cname := f.zone[name+" TypeCNAME"]; len(cname) > 0 && question.Type != dnsmessage.TypeCNAME {
+		target := strings.ToLower(cname[0].Body.(*dnsmessage.CNAMEResource).CNAME.String())
+		resp.Answers = append(append(resp.Answers, cname...), f.zone[target+" "+question.Type.String()]...)
+	} else if answers, ok := f.zone[name+" "+question.Type.String()]; ok {
+		resp.Answers = answers
+	} else if !f.hasName(name) {
+		resp.RCode = dnsmessage.RCodeNameError
+	}
+	out, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func (f *fakeDNS) hasName(name string) bool {
+	for key := range f.zone {
+		if strings.HasPrefix(key, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func rrHeader(name string, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: typ, Class: dnsmessage.ClassINET, TTL: 60}
+}
+
+func testZone() []dnsmessage.Resource {
+	return []dnsmessage.Resource{
+		{Header: rrHeader("plex.lan.", dnsmessage.TypeA), Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 5}}},
+		{Header: rrHeader("plex.lan.", dnsmessage.TypeAAAA), Body: &dnsmessage.AAAAResource{AAAA: [16]byte{0xfd, 15: 5}}},
+		// localhost would resolve to 127.0.0.1 from /etc/hosts
+		{Header: rrHeader("localhost.", dnsmessage.TypeA), Body: &dnsmessage.AResource{A: [4]byte{10, 9, 9, 9}}},
+		{Header: rrHeader("media.lan.", dnsmessage.TypeCNAME), Body: &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("Plex.LAN.")}},
+		{Header: rrHeader("lan.", dnsmessage.TypeTXT), Body: &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}}},
+		{Header: rrHeader("lan.", dnsmessage.TypeMX), Body: &dnsmessage.MXResource{Pref: 20, MX: dnsmessage.MustNewName("mx2.lan.")}},
+		{Header: rrHeader("lan.", dnsmessage.TypeMX), Body: &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mx1.lan.")}},
+	}
+}
+
+func runDNSCheck(t *testing.T, f *fakeDNS, path string) Result {
+	t.Helper()
+	s := &models.Service{Key: "dns", URL: "dns://" + f.addr + "/" + path}
+	if err := (dnsChecker{}).Validate(s); err != nil {
+		t.Fatalf("Validate(%s): %v", s.URL, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return dnsChecker{}.Check(ctx, s)
+}
+
+func answers(res Result) []string {
+	a, _ := res.Meta["answers"].([]string)
+	return a
+}
+
+func TestDNSCheck(t *testing.T) {
+	f := startFakeDNS(t, testZone(), false)
+
+	tests := []struct {
+		path    string
+		up      bool
+		answers []string
+		message string
+	}{
+		{path: "plex.lan", up: true, answers: []string{"10.0.0.5"}},
+		{path: "plex.lan?expect=10.0.0.5", up: true, answers: []string{"10.0.0.5"}},
+		{path: "plex.lan?expect=10.0.0.6", message: "expected 10.0.0.6, got 10.0.0.5"},
+		{path: "plex.lan?type=AAAA&expect=fd00::5", up: true, answers: []string{"fd00::5"}},
+		{path: "media.lan?expect=10.0.0.5", up: true, answers: []string{"10.0.0.5"}},
+		{path: "media.lan?type=CNAME&expect=plex.lan", up: true, answers: []string{"plex.lan"}},
+		{path: "lan?type=TXT&expect=v=spf1 -all", up: true, answers: []string{"v=spf1 -all"}},
+		{path: "lan?type=MX&expect=mx1.lan,mx2.lan", up: true, answers: []string{"mx1.lan", "mx2.lan"}},
+		{path: "lan", message: "no A records for lan"},
+		{path: "missing.lan", message: "no such host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res := runDNSCheck(t, f, tt.path)
+			if got := res.Status == StatusUp; got != tt.up {
+				t.Fatalf("up = %v, want %v (message %q)", got, tt.up, res.Message)
+			}
+			if tt.answers != nil && !slices.Equal(answers(res), tt.answers) {
+				t.Errorf("answers = %v, want %v", answers(res), tt.answers)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Errorf("message = %q, want it to contain %q", res.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestDNSValidate(t *testing.T) {
+	for _, u := range []string{
+		"dns://10.0.0.1/plex.lan",
+		"dns://10.0.0.1:5353/plex.lan?type=aaaa",
+		"dns://resolver.lan/_sip._tcp.lan?type=TXT",
+		"dns://[fd00::1]/lan?type=MX&expect=mx1.lan",
+	} {
+		if err := (dnsChecker{}).Validate(&models.Service{URL: u}); err != nil {
+			t.Errorf("Validate(%s): %v", u, err)
+		}
+	}
+
+	for u, want := range map[string]string{
+		"dns:///plex.lan":                             "must include the resolver",
+		"dns://:53/plex.lan":                          "must include the resolver",
+		"dns://bad_host!/plex.lan":                    "must include the resolver",
+		"dns://10.0.0.1":                              "must include the name",
+		"dns://10.0.0.1/":                             "must include the name",
+		"dns://10.0.0.1/a..b":                         "invalid name",
+		"dns://10.0.0.1/" + strings.Repeat("a.", 130): "invalid name",
+		"dns://10.0.0.1/plex.lan?type=FOO":            `unsupported record type "FOO" (supported: A, AAAA, CNAME, MX, TXT)`,
+		"dns://10.0.0.1/plex.lan?type=SRV":            "unsupported record type",
+		"dns://10.0.0.1/plex.lan?type=A,AAAA":         "unsupported record type",
+	} {
+		err := (dnsChecker{}).Validate(&models.Service{URL: u})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate(%s) = %v, want %q", u, err, want)
+		}
+	}
+}
+
+func TestDNSCheckAsksTheResolver(t *testing.T) {
+	f := startFakeDNS(t, testZone(), false)
+
+	// Names in /etc/hosts and IP literals must still be sent to the server
+	res := runDNSCheck(t, f, "localhost?expect=10.9.9.9")
+	if res.Status != StatusUp {
+		t.Fatalf("localhost: %q, answers %v", res.Message, answers(res))
+	}
+	if q := <-f.queries; q != "udp localhost. TypeA" {
+		t.Errorf("query = %q", q)
+	}
+
+	runDNSCheck(t, f, "10.0.0.5")
+	if q := <-f.queries; q != "udp 10.0.0.5. TypeA" {
+		t.Errorf("query = %q", q)
+	}
+}
+
+func TestDNSCheckTruncatedFallsBackToTCP(t *testing.T) {
+	f := startFakeDNS(t, testZone(), true)
+
+	res := runDNSCheck(t, f, "plex.lan?expect=10.0.0.5")
+	if res.Status != StatusUp {
+		t.Fatalf("message %q", res.Message)
+	}
+	if q := <-f.queries; q != "udp plex.lan. TypeA" {
+		t.Errorf("first query = %q", q)
+	}
+	if q := <-f.queries; q != "tcp plex.lan. TypeA" {
+		t.Errorf("second query = %q", q)
+	}
+}
+
+func TestDNSCheckTimeout(t *testing.T) {
+	// A UDP socket that never answers
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	res := dnsChecker{}.Check(ctx, &models.Service{Key: "dns", URL: "dns://" + pc.LocalAddr().String() + "/plex.lan"})
+	if res.Status != StatusDown || res.Message == "" {
+		t.Fatalf("status %v, message %q", res.Status, res.Message)
+	}
+}
@@ -0,0 +1,235 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"status/app/internal/models"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+func init() {
+	Register("http", httpChecker{})
+	Register("https", httpChecker{})
+}
+
+// Redirect policies for HTTP checks
+const (
+	RedirectsFollow = "follow"
+	RedirectsNone   = "none"
+)
+
+// maxRedirects matches the net/http default
+const maxRedirects = 10
+
+var httpMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// httpChecker requests the service URL and checks the status code against
+// the service's accepted range. The request method, headers, body, auth and
+// redirect policy come from the service's HTTP options and secrets. The
+// response body is also checked against the service's assertions, and the
+// first failing assertion is reported.
+type httpChecker struct{}
+
+func (httpChecker) Validate(s *models.Service) error {
+	o := &s.HTTP
+	o.Method = strings.ToUpper(strings.TrimSpace(o.Method))
+	if o.Method != "" && !slices.Contains(httpMethods, o.Method) {
+		return fmt.Errorf("http method must be one of %s", strings.Join(httpMethods, ", "))
+	}
+	switch o.Auth {
+	case "", "bearer":
+	case "basic":
+		if o.Username == "" {
+			return errors.New("basic auth needs a username")
+		}
+	default:
+		return errors.New(`http auth must be "basic" or "bearer"`)
+	}
+	switch o.Redirects {
+	case "", RedirectsFollow, RedirectsNone:
+	default:
+		return fmt.Errorf("http redirects must be %q or %q", RedirectsFollow, RedirectsNone)
+	}
+	for _, headers := range []map[string]string{o.Headers, s.Secrets.Headers} {
+		for name, value := range headers {
+			if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+				return fmt.Errorf("invalid header %q", name)
+			}
+		}
+	}
+	return ValidateAssertions(s.Assertions)
+}
+
+func (httpChecker) Check(ctx context.Context, s *models.Service) Result {
+	trace := &phaseTrace{}
+	req, err := newHTTPRequest(httptrace.WithClientTrace(ctx, trace.clientTrace()), s)
+	if err != nil {
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+
+	t0 := time.Now()
+	resp, err := httpClient(s).Do(req)
+	ms := elapsedMS(t0)
+	if err != nil {
+		log.Printf("http check error url=%s err=%v", s.URL, err)
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	// The body is always read so the transfer phase can be measured; one
+	// byte past the limit tells whether it was cut short
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxAssertBody+1))
+	truncated := len(body) > maxAssertBody
+	if truncated {
+		body = body[:maxAssertBody]
+	}
+	res := Result{Status: StatusUp, Code: resp.StatusCode, MS: ms, Phases: trace.phases(time.Now())}
+	if resp.StatusCode < s.MinOK || resp.StatusCode > s.MaxOK {
+		res.Status = StatusDown
+		res.Message = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		return res
+	}
+	if len(s.Assertions) == 0 {
+		return res
+	}
+	if readErr != nil {
+		res.Status = StatusDown
+		res.Message = "read body: " + readErr.Error()
+		return res
+	}
+	if err := checkAssertions(body, truncated, s.Assertions); err != nil {
+		res.Status = StatusDown
+		res.Message = err.Error()
+	}
+	return res
+}
+
+// newHTTPRequest builds the check request from the service's HTTP options,
+// adding secret headers and credentials last so they take precedence
+func newHTTPRequest(ctx context.Context, s *models.Service) (*http.Request, error) {
+	method := s.HTTP.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if s.HTTP.Body != "" {
+		body = strings.NewReader(s.HTTP.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, headers := range []map[string]string{s.HTTP.Headers, s.Secrets.Headers} {
+		for name, value := range headers {
+			if strings.EqualFold(name, "Host") {
+				req.Host = value
+				continue
+			}
+			req.Header.Set(name, value)
+		}
+	}
+	switch s.HTTP.Auth {
+	case "basic":
+		req.SetBasicAuth(s.HTTP.Username, s.Secrets.Password)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+s.Secrets.Token)
+	}
+	return req, nil
+}
+
+// httpTransport never reuses connections, so every check measures DNS,
+// connect and TLS setup rather than an idle keep-alive connection
+var httpTransport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DisableKeepAlives = true
+	return t
+}()
+
+// httpClient returns a client applying the service's redirect policy. When
+// following redirects, secret headers are dropped once the redirect leaves
+// the original host, like net/http already does for Authorization.
+func httpClient(s *models.Service) *http.Client {
+	return &http.Client{
+		Transport: httpTransport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if s.HTTP.Redirects == RedirectsNone {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Host != via[0].URL.Host {
+				for name := range s.Secrets.Headers {
+					req.Header.Del(name)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// phaseTrace records the connection timings of a check request. After a
+// redirect the timings describe the final hop. Dial callbacks can run
+// concurrently when both IPv4 and IPv6 are tried, hence the lock.
+type phaseTrace struct {
+	mu                        sync.Mutex
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	gotConn, firstByte        time.Time
+}
+
+func (t *phaseTrace) mark(field *time.Time) {
+	t.mu.Lock()
+	*field = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *phaseTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart:         func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { t.mark(&t.gotConn) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+}
+
+// phases converts the recorded timings into a breakdown, given when the
+// response body was fully read
+func (t *phaseTrace) phases(done time.Time) *models.LatencyPhases {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := func(start, end time.Time) float64 {
+		if start.IsZero() || end.Before(start) {
+			return 0
+		}
+		return durMS(end.Sub(start))
+	}
+	return &models.LatencyPhases{
+		DNS:      span(t.dnsStart, t.dnsDone),
+		Connect:  span(t.connectStart, t.connectDone),
+		TLS:      span(t.tlsStart, t.tlsDone),
+		TTFB:     span(t.gotConn, t.firstByte),
+		Transfer: span(t.firstByte, done),
+	}
+}
@@ -0,0 +1,203 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"status/app/internal/models"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func init() {
+	Register("icmp", icmpChecker{})
+}
+
+// icmpChecker pings an icmp://host URL using unprivileged ICMP datagram
+// sockets, so it needs no root or CAP_NET_RAW as long as the process group
+// is within net.ipv4.ping_group_range. Query parameters:
+//
+//	count         - echo requests to send (default 3, max 20)
+//	degraded_loss - packet loss percentage at which the service is degraded (default 1)
+//	down_loss     - packet loss percentage at which the service is down (default 100)
+//
+// degraded_loss must be below down_loss. The timeout is shared between all
+// echo requests, and the recorded latency is the average round-trip time.
+type icmpChecker struct{}
+
+// icmpTarget is what an icmp:// URL asks to check
+type icmpTarget struct {
+	host         string
+	count        int
+	degradedLoss int
+	downLoss     int
+}
+
+func parseICMPURL(raw string) (icmpTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return icmpTarget{}, fmt.Errorf("invalid icmp url: %w", err)
+	}
+	t := icmpTarget{host: u.Hostname()}
+	if !validHost(t.host) {
+		return icmpTarget{}, errors.New("icmp url must include a host, e.g. icmp://10.0.0.3")
+	}
+	q := u.Query()
+	for _, p := range []struct {
+		key         string
+		dst         *int
+		def, lo, hi int
+	}{
+		{"count", &t.count, 3, 1, 20},
+		{"degraded_loss", &t.degradedLoss, 1, 0, 100},
+		{"down_loss", &t.downLoss, 100, 1, 100},
+	} {
+		if *p.dst, err = queryInt(q, p.key, p.def, p.lo, p.hi); err != nil {
+			return icmpTarget{}, err
+		}
+	}
+	if !q.Has("degraded_loss") {
+		t.degradedLoss = min(t.degradedLoss, t.downLoss-1)
+	}
+	if t.degradedLoss >= t.downLoss {
+		return icmpTarget{}, fmt.Errorf("degraded_loss (%d) must be below down_loss (%d)", t.degradedLoss, t.downLoss)
+	}
+	return t, nil
+}
+
+func (icmpChecker) Validate(s *models.Service) error {
+	_, err := parseICMPURL(s.URL)
+	return err
+}
+
+func (icmpChecker) Check(ctx context.Context, s *models.Service) Result {
+	t, err := parseICMPURL(s.URL)
+	if err != nil {
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+	count := t.count
+
+	var resolver net.Resolver
+	addrs, err := resolver.LookupIPAddr(ctx, t.host)
+	if err != nil {
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+	dst := addrs[0]
+
+	network, listenAddr, proto := "udp4", "0.0.0.0", 1
+	var echoType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if dst.IP.To4() == nil {
+		network, listenAddr, proto = "udp6", "::", 58
+		echoType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	conn, err := icmp.ListenPacket(network, listenAddr)
+	if err != nil {
+		log.Printf("icmp check error host=%s err=%v", t.host, err)
+		if errors.Is(err, os.ErrPermission) {
+			return Result{Status: StatusDown, Message: "unprivileged ICMP not permitted (check net.ipv4.ping_group_range)"}
+		}
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+	defer conn.Close()
+
+	perPacket := s.Timeout / time.Duration(count)
+	if deadline, ok := ctx.Deadline(); ok {
+		perPacket = time.Until(deadline) / time.Duration(count)
+	}
+	stats := &models.PingStats{Sent: count}
+	var total time.Duration
+	minRTT, maxRTT := time.Duration(math.MaxInt64), time.Duration(0)
+	id := os.Getpid() & 0xffff
+	buf := make([]byte, 1500)
+
+	for seq := 1; seq <= count && ctx.Err() == nil; seq++ {
+		msg := icmp.Message{
+			Type: echoType,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("servicarr")},
+		}
+		wb, err := msg.Marshal(nil)
+		if err != nil {
+			return Result{Status: StatusDown, Message: err.Error()}
+		}
+
+		t0 := time.Now()
+		if _, err := conn.WriteTo(wb, &net.UDPAddr{IP: dst.IP, Zone: dst.Zone}); err != nil {
+			return Result{Status: StatusDown, Message: err.Error()}
+		}
+		_ = conn.SetReadDeadline(t0.Add(perPacket))
+
+		// Read until the matching reply arrives or the per-packet deadline
+		// passes. The kernel rewrites the echo ID on datagram sockets, so
+		// replies are matched on sequence number only.
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			reply, err := icmp.ParseMessage(proto, buf[:n])
+			if err != nil || reply.Type != replyType {
+				continue
+			}
+			if echo, ok := reply.Body.(*icmp.Echo); ok && echo.Seq == seq {
+				rtt := time.Since(t0)
+				stats.Received++
+				total += rtt
+				minRTT = min(minRTT, rtt)
+				maxRTT = max(maxRTT, rtt)
+				break
+			}
+		}
+	}
+
+	stats.LossPct = float64(count-stats.Received) * 100 / float64(count)
+	res := Result{Status: StatusUp, Meta: map[string]any{MetaPing: stats}}
+	if stats.Received > 0 {
+		stats.MinMS = durMS(minRTT)
+		stats.MaxMS = durMS(maxRTT)
+		stats.AvgMS = durMS(total / time.Duration(stats.Received))
+		avg := int(math.Round(stats.AvgMS))
+		res.MS = &avg
+	}
+
+	res.Status, res.Message = lossStatus(stats, t.degradedLoss, t.downLoss)
+	return res
+}
+
+// lossStatus maps the packet loss of a ping to a status. Any loss at or
+// above degradedLoss degrades the service, so a degradedLoss of 0 still
+// needs a packet to be lost.
+func lossStatus(stats *models.PingStats, degradedLoss, downLoss int) (Status, string) {
+	switch {
+	case stats.LossPct >= float64(downLoss):
+		return StatusDown, fmt.Sprintf("%.0f%% packet loss (%d/%d received)", stats.LossPct, stats.Received, stats.Sent)
+	case stats.LossPct >= float64(degradedLoss) && stats.LossPct > 0:
+		return StatusDegraded, fmt.Sprintf("%.0f%% packet loss", stats.LossPct)
+	}
+	return StatusUp, ""
+}
+
+// queryInt reads an optional integer query parameter, def when absent
+func queryInt(q url.Values, key string, def, lo, hi int) (int, error) {
+	if !q.Has(key) {
+		return def, nil
+	}
+	n, err := strconv.Atoi(q.Get(key))
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("%s must be a number between %d and %d, got %q", key, lo, hi, q.Get(key))
+	}
+	return n, nil
+}
+
+func durMS(d time.Duration) float64 {
+	return math.Round(float64(d.Microseconds())/10) / 100
+}
@@ -0,0 +1,80 @@
+package checker
+
+import (
+	"status/app/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestParseICMPURL(t *testing.T) {
+	tests := []struct {
+		url                           string
+		count, degradedLoss, downLoss int
+		err                           string
+	}{
+		{url: "icmp://10.0.0.3", count: 3, degradedLoss: 1, downLoss: 100},
+		{url: "icmp://nas.lan?count=5&degraded_loss=20&down_loss=60", count: 5, degradedLoss: 20, downLoss: 60},
+		{url: "icmp://nas.lan?count=20&degraded_loss=0", count: 20, degradedLoss: 0, downLoss: 100},
+		{url: "icmp://nas.lan?down_loss=1", count: 3, degradedLoss: 0, downLoss: 1},
+
+		{url: "icmp://", err: "must include a host"},
+		{url: "icmp://nas.lan?count=0", err: "count must be a number between 1 and 20"},
+		{url: "icmp://nas.lan?count=21", err: "count must be"},
+		{url: "icmp://nas.lan?count=abc", err: "count must be"},
+		{url: "icmp://nas.lan?count=", err: "count must be"},
+		{url: "icmp://nas.lan?degraded_loss=-1", err: "degraded_loss must be"},
+		{url: "icmp://nas.lan?degraded_loss=101", err: "degraded_loss must be"},
+		{url: "icmp://nas.lan?down_loss=0", err: "down_loss must be"},
+		{url: "icmp://nas.lan?down_loss=150", err: "down_loss must be"},
+		{url: "icmp://nas.lan?degraded_loss=50&down_loss=50", err: "degraded_loss (50) must be below down_loss (50)"},
+		{url: "icmp://nas.lan?degraded_loss=100", err: "degraded_loss (100) must be below down_loss (100)"},
+	}
+	for _, tt := range tests {
+		got, err := parseICMPURL(tt.url)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseICMPURL(%q) error = %v, want %q", tt.url, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseICMPURL(%q): %v", tt.url, err)
+			continue
+		}
+		if got.count != tt.count || got.degradedLoss != tt.degradedLoss || got.downLoss != tt.downLoss {
+			t.Errorf("parseICMPURL(%q) = %+v", tt.url, got)
+		}
+	}
+
+	if err := (icmpChecker{}).Validate(&models.Service{URL: "icmp://nas.lan?count=50"}); err == nil {
+		t.Error("Validate accepted count=50")
+	}
+}
+
+func TestLossStatus(t *testing.T) {
+	tests := []struct {
+		sent, received         int
+		degradedLoss, downLoss int
+		status                 Status
+		msg                    string
+	}{
+		{sent: 5, received: 5, degradedLoss: 1, downLoss: 100, status: StatusUp},
+		{sent: 5, received: 4, degradedLoss: 1, downLoss: 100, status: StatusDegraded, msg: "20% packet loss"},
+		{sent: 5, received: 1, degradedLoss: 1, downLoss: 100, status: StatusDegraded, msg: "80% packet loss"},
+		{sent: 5, received: 0, degradedLoss: 1, downLoss: 100, status: StatusDown, msg: "100% packet loss (0/5 received)"},
+		{sent: 5, received: 4, degradedLoss: 20, downLoss: 60, status: StatusDegraded, msg: "20% packet loss"},
+		{sent: 10, received: 9, degradedLoss: 20, downLoss: 60, status: StatusUp},
+		{sent: 5, received: 2, degradedLoss: 20, downLoss: 60, status: StatusDown, msg: "60% packet loss (2/5 received)"},
+		{sent: 3, received: 3, degradedLoss: 0, downLoss: 100, status: StatusUp},
+		{sent: 3, received: 2, degradedLoss: 0, downLoss: 100, status: StatusDegraded, msg: "33% packet loss"},
+		{sent: 3, received: 2, degradedLoss: 0, downLoss: 1, status: StatusDown, msg: "33% packet loss (2/3 received)"},
+	}
+	for _, tt := range tests {
+		stats := &models.PingStats{Sent: tt.sent, Received: tt.received, LossPct: float64(tt.sent-tt.received) * 100 / float64(tt.sent)}
+		status, msg := lossStatus(stats, tt.degradedLoss, tt.downLoss)
+		if status != tt.status || msg != tt.msg {
+			t.Errorf("%d/%d received, degraded at %d%%, down at %d%%: %s %q, want %s %q",
+				tt.received, tt.sent, tt.degradedLoss, tt.downLoss, status, msg, tt.status, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package checker
+
+import (
+	"context"
+	"log"
+	"net"
+	"status/app/internal/models"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("tcp", tcpChecker{})
+}
+
+// tcpChecker opens a TCP connection to a tcp://host:port URL
+type tcpChecker struct{}
+
+func (tcpChecker) Check(ctx context.Context, s *models.Service) Result {
+	addr := strings.TrimPrefix(s.URL, "tcp://")
+	var d net.Dialer
+	t0 := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	ms := elapsedMS(t0)
+	if err != nil {
+		log.Printf("tcp check error addr=%s err=%v", addr, err)
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+	_ = conn.Close()
+	return Result{Status: StatusUp, MS: ms}
+}
@@ -0,0 +1,147 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"status/app/internal/models"
+	"strconv"
+	"time"
+)
+
+// defaultCertWarnDays is how close to expiry a certificate may get before
+// the service is reported as degraded
+const defaultCertWarnDays = 14
+
+func init() {
+	Register("tls", tlsChecker{})
+}
+
+// tlsChecker performs a TLS handshake against a tls://host[:port] URL and
+// validates the presented certificate chain. The URL accepts the optional
+// query parameters warn_days (degraded threshold, default 14) and
+// server_name (SNI/hostname to verify, defaults to the URL host).
+//
+// The service is down when the handshake fails or the certificate is
+// expired, untrusted or doesn't cover the hostname, and degraded when it
+// expires within warn_days.
+type tlsChecker struct{}
+
+// tlsTarget is what a tls:// URL asks to check
+type tlsTarget struct {
+	addr       string
+	serverName string
+	warnDays   int
+}
+
+func parseTLSURL(raw string) (tlsTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return tlsTarget{}, fmt.Errorf("invalid tls url: %w", err)
+	}
+	host := u.Hostname()
+	if !validHost(host) {
+		return tlsTarget{}, errors.New("tls url must include a host, e.g. tls://example.com:443")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	t := tlsTarget{addr: net.JoinHostPort(host, port), serverName: host, warnDays: defaultCertWarnDays}
+	q := u.Query()
+	if q.Has("server_name") {
+		t.serverName = q.Get("server_name")
+		if !validHost(t.serverName) {
+			return tlsTarget{}, fmt.Errorf("invalid server_name %q", t.serverName)
+		}
+	}
+	if q.Has("warn_days") {
+		n, err := strconv.Atoi(q.Get("warn_days"))
+		if err != nil || n < 0 || n > 365 {
+			return tlsTarget{}, fmt.Errorf("warn_days must be a number of days between 0 and 365, got %q", q.Get("warn_days"))
+		}
+		t.warnDays = n
+	}
+	return t, nil
+}
+
+func (tlsChecker) Validate(s *models.Service) error {
+	_, err := parseTLSURL(s.URL)
+	return err
+}
+
+func (tlsChecker) Check(ctx context.Context, s *models.Service) Result {
+	t, err := parseTLSURL(s.URL)
+	if err != nil {
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+
+	// Verification is done by hand below so the certificate details are
+	// still recorded when the chain is invalid.
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName:         t.serverName,
+		InsecureSkipVerify: true, // #nosec G402 -- chain is verified explicitly below
+	}}
+	t0 := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", t.addr)
+	ms := elapsedMS(t0)
+	if err != nil {
+		log.Printf("tls check error addr=%s err=%v", t.addr, err)
+		return Result{Status: StatusDown, Message: err.Error()}
+	}
+	state := conn.(*tls.Conn).ConnectionState()
+	_ = conn.Close()
+
+	if len(state.PeerCertificates) == 0 {
+		return Result{Status: StatusDown, MS: ms, Message: "no certificate presented"}
+	}
+	leaf := state.PeerCertificates[0]
+	daysLeft, status, msg := certExpiry(leaf.NotAfter, time.Now(), t.warnDays)
+	cert := &models.CertInfo{
+		Subject:   leaf.Subject.CommonName,
+		Issuer:    leaf.Issuer.CommonName,
+		DNSNames:  leaf.DNSNames,
+		ExpiresAt: leaf.NotAfter.UTC(),
+		DaysLeft:  daysLeft,
+	}
+	res := Result{Status: StatusDown, MS: ms, Meta: map[string]any{MetaCert: cert}}
+
+	if status == StatusDown {
+		res.Message = msg
+		return res
+	}
+	if err := leaf.VerifyHostname(t.serverName); err != nil {
+		res.Message = "certificate does not cover " + t.serverName
+		return res
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: t.serverName, Intermediates: intermediates}); err != nil {
+		res.Message = "certificate chain invalid: " + err.Error()
+		return res
+	}
+
+	res.Status, res.Message = status, msg
+	return res
+}
+
+// certExpiry classifies a certificate by its expiry: down once expired and
+// degraded when fewer than warnDays whole days are left
+func certExpiry(notAfter, now time.Time, warnDays int) (daysLeft int, status Status, msg string) {
+	daysLeft = int(notAfter.Sub(now).Hours() / 24)
+	switch {
+	case now.After(notAfter):
+		return daysLeft, StatusDown, fmt.Sprintf("certificate expired on %s", notAfter.UTC().Format("2006-01-02"))
+	case daysLeft < warnDays:
+		return daysLeft, StatusDegraded, fmt.Sprintf("certificate expires in %d days", daysLeft)
+	}
+	return daysLeft, StatusUp, ""
+}
@@ -0,0 +1,109 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"status/app/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTLSURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		addr       string
+		serverName string
+		warnDays   int
+		err        string
+	}{
+		{url: "tls://example.com", addr: "example.com:443", serverName: "example.com", warnDays: 14},
+		{url: "tls://example.com:8443?warn_days=30", addr: "example.com:8443", serverName: "example.com", warnDays: 30},
+		{url: "tls://10.0.0.2:443?server_name=nas.lan&warn_days=0", addr: "10.0.0.2:443", serverName: "nas.lan", warnDays: 0},
+		{url: "tls://[::1]:443", addr: "[::1]:443", serverName: "::1", warnDays: 14},
+
+		{url: "tls://", err: "must include a host"},
+		{url: "tls:///path", err: "must include a host"},
+		{url: "tls://bad_host!:443", err: "must include a host"},
+		{url: "tls://example.com?warn_days=abc", err: "warn_days must be"},
+		{url: "tls://example.com?warn_days=-3", err: "warn_days must be"},
+		{url: "tls://example.com?warn_days=", err: "warn_days must be"},
+		{url: "tls://example.com?warn_days=1000", err: "warn_days must be"},
+		{url: "tls://example.com?server_name=", err: "invalid server_name"},
+		{url: "tls://example.com?server_name=a..b", err: "invalid server_name"},
+		{url: "tls://example.com?server_name=-bad.example", err: "invalid server_name"},
+		{url: "tls://example.com?server_name=has%20space", err: "invalid server_name"},
+	}
+	for _, tt := range tests {
+		got, err := parseTLSURL(tt.url)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseTLSURL(%q) error = %v, want %q", tt.url, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTLSURL(%q): %v", tt.url, err)
+			continue
+		}
+		if got.addr != tt.addr || got.serverName != tt.serverName || got.warnDays != tt.warnDays {
+			t.Errorf("parseTLSURL(%q) = %+v", tt.url, got)
+		}
+	}
+
+	if err := (tlsChecker{}).Validate(&models.Service{URL: "tls://example.com?warn_days=abc"}); err == nil {
+		t.Error("Validate accepted warn_days=abc")
+	}
+}
+
+func TestCertExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		left     time.Duration
+		warnDays int
+		daysLeft int
+		status   Status
+		msg      string
+	}{
+		{left: 90 * day, warnDays: 14, daysLeft: 90, status: StatusUp},
+		{left: 14*day + time.Hour, warnDays: 14, daysLeft: 14, status: StatusUp},
+		{left: 14*day - time.Hour, warnDays: 14, daysLeft: 13, status: StatusDegraded, msg: "certificate expires in 13 days"},
+		{left: time.Hour, warnDays: 14, daysLeft: 0, status: StatusDegraded, msg: "certificate expires in 0 days"},
+		{left: time.Hour, warnDays: 0, daysLeft: 0, status: StatusUp},
+		{left: 0, warnDays: 14, daysLeft: 0, status: StatusDegraded, msg: "certificate expires in 0 days"},
+		{left: -time.Second, warnDays: 0, daysLeft: 0, status: StatusDown, msg: "certificate expired on 2026-03-10"},
+		{left: -3 * day, warnDays: 14, daysLeft: -3, status: StatusDown, msg: "certificate expired on 2026-03-07"},
+	}
+	for _, tt := range tests {
+		daysLeft, status, msg := certExpiry(now.Add(tt.left), now, tt.warnDays)
+		if daysLeft != tt.daysLeft || status != tt.status || msg != tt.msg {
+			t.Errorf("certExpiry(%v left, warn %d) = %d, %s, %q, want %d, %s, %q",
+				tt.left, tt.warnDays, daysLeft, status, msg, tt.daysLeft, tt.status, tt.msg)
+		}
+	}
+}
+
+func TestTLSCheckRecordsUntrustedCertificates(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	// The test certificate covers example.com but is not signed by a
+	// trusted root
+	for query, want := range map[string]string{
+		"?server_name=example.com": "certificate chain invalid",
+		"?server_name=other.test":  "certificate does not cover other.test",
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		res := tlsChecker{}.Check(ctx, &models.Service{URL: "tls://" + addr + query})
+		cancel()
+		if res.Status != StatusDown || !strings.HasPrefix(res.Message, want) {
+			t.Errorf("%s: %s %q, want down with %q", query, res.Status, res.Message, want)
+		}
+		if c := res.Cert(); c == nil || c.DaysLeft <= 0 {
+			t.Errorf("%s: certificate not recorded: %+v", query, c)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"status/app/internal/models"
 	"strconv"
 	"strings"
 	"time"
@@ -22,16 +23,29 @@ type Config struct {
 
 	// Server
 	Port            string
-	DBPath          string
+	DBDriver        string // sqlite or postgres
+	DBSource        string // file path for sqlite, connection URL for postgres
 	EnableScheduler bool
-	PollInterval    time.Duration
+	PollInterval    time.Duration // default interval for services without their own
+	MaxConcurrent   int           // maximum number of checks running at once
 	StatusPageURL   string
 
+	// How long raw samples and their hourly and daily rollups are kept;
+	// zero keeps them forever
+	RawRetention    time.Duration
+	HourlyRetention time.Duration
+	DailyRetention  time.Duration
+
 	// Services (loaded from env)
 	ServiceConfigs []ServiceConfig
 
 	// Resources (Glances)
 	GlancesBaseURL string
+
+	// Optional declarative config file (watched for changes)
+	ConfigFile        string
+	ConfigWatchPeriod time.Duration
+	File              *FileConfig
 }
 
 // ServiceConfig holds configuration for a single service
@@ -42,9 +56,11 @@ type ServiceConfig struct {
 	Timeout time.Duration
 	MinOK   int
 	MaxOK   int
+	Secrets models.ServiceSecrets
 }
 
-// Load reads configuration from environment variables
+// Load reads configuration from environment variables and, when CONFIG_FILE
+// is set, from a YAML config file whose sections take precedence
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
@@ -53,11 +69,25 @@ func Load() (*Config, error) {
 		InsecureDev:     envBool("INSECURE_DEV", true),
 		SessionMaxAgeS:  envInt("SESSION_MAX_AGE_SECONDS", 86400),
 		Port:            getenv("PORT", "4555"),
-		DBPath:          getenv("DB_PATH", "./uptime.db"),
 		EnableScheduler: strings.ToLower(getenv("ENABLE_SCHEDULER", "true")) == "true",
 		PollInterval:    envDurSecs("POLL_SECONDS", 60),
+		MaxConcurrent:   envInt("MAX_CONCURRENT_CHECKS", 8),
 		StatusPageURL:   getenv("STATUS_PAGE_URL", ""),
+		RawRetention:    envDurDays("RAW_RETENTION_DAYS", 14),
+		HourlyRetention: envDurDays("HOURLY_RETENTION_DAYS", 90),
+		DailyRetention:  envDurDays("DAILY_RETENTION_DAYS", 0),
 		GlancesBaseURL:  strings.TrimSuffix(getenv("GLANCES_BASE_URL", "http://10.0.0.2:61208/api/4"), "/"),
+
+		ConfigFile:        getenv("CONFIG_FILE", ""),
+		ConfigWatchPeriod: envDurSecs("CONFIG_WATCH_SECONDS", 5),
+	}
+	cfg.DBDriver, cfg.DBSource = Database()
+
+	// Raw samples back the last 24h of failures and the rollup of the
+	// previous day, so keep at least two days of them
+	if cfg.RawRetention > 0 && cfg.RawRetention < 48*time.Hour {
+		log.Printf("RAW_RETENTION_DAYS raised to the minimum of 2")
+		cfg.RawRetention = 48 * time.Hour
 	}
 
 	// Load auth password/hash
@@ -82,19 +112,54 @@ func Load() (*Config, error) {
 	}
 	cfg.HmacSecret = []byte(secret)
 
+	// Services without their own interval use the poll interval, which
+	// bounds their jitter
+	if cfg.PollInterval > 0 {
+		models.DefaultInterval = cfg.PollInterval
+	}
+
 	// Load service configurations
 	cfg.ServiceConfigs = loadServiceConfigs()
 
+	// Load the config file, if any; an invalid file is fatal at startup
+	if cfg.ConfigFile != "" {
+		fc, err := LoadFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.File = fc
+	}
+
 	return cfg, nil
 }
 
+// Database returns the database driver from DB_DRIVER and its data source:
+// DB_PATH for sqlite, DATABASE_URL for postgres. Commands that only need the
+// database use it without loading the rest of the configuration.
+func Database() (driver, source string) {
+	_ = godotenv.Load()
+	driver = strings.ToLower(getenv("DB_DRIVER", "sqlite"))
+	if driver == "postgres" {
+		return driver, getenv("DATABASE_URL", "")
+	}
+	return driver, getenv("DB_PATH", "./uptime.db")
+}
+
 func loadServiceConfigs() []ServiceConfig {
+	// Tokens are sent as headers rather than query parameters so they never
+	// end up in stored URLs or logged request errors
 	plexURL := getenv("PLEX_BASE_URL", "")
-	plexToken := getenv("PLEX_TOKEN", "")
 	plexIdentity := ""
-	if plexURL != "" && plexToken != "" {
-		plexURL = strings.TrimSuffix(plexURL, "/")
-		plexIdentity = plexURL + "/identity?X-Plex-Token=" + plexToken
+	if plexURL != "" {
+		plexIdentity = strings.TrimSuffix(plexURL, "/") + "/identity"
+	}
+	plexSecrets := models.ServiceSecrets{}
+	if token := getenv("PLEX_TOKEN", ""); token != "" {
+		plexSecrets.Headers = map[string]string{"X-Plex-Token": token}
+	}
+	overseerrSecrets := models.ServiceSecrets{}
+	if key := getenv("OVERSEERR_API_KEY", ""); key != "" {
+		overseerrSecrets.Headers = map[string]string{"X-Api-Key": key}
 	}
 
 	return []ServiceConfig{
@@ -113,6 +178,7 @@ func loadServiceConfigs() []ServiceConfig {
 			Timeout: envDurSecs("PLEX_TIMEOUT_SECS", 5),
 			MinOK:   envInt("PLEX_OK_MIN", 200),
 			MaxOK:   envInt("PLEX_OK_MAX", 399),
+			Secrets: plexSecrets,
 		},
 		{
 			Key:     "overseerr",
@@ -121,6 +187,7 @@ func loadServiceConfigs() []ServiceConfig {
 			Timeout: envDurSecs("OVERSEERR_TIMEOUT_SECS", 4),
 			MinOK:   envInt("OVERSEERR_OK_MIN", 200),
 			MaxOK:   envInt("OVERSEERR_OK_MAX", 399),
+			Secrets: overseerrSecrets,
 		},
 	}
 }
@@ -153,3 +220,7 @@ func envBool(k string, def bool) bool {
 func envDurSecs(k string, def int) time.Duration {
 	return time.Duration(envInt(k, def)) * time.Second
 }
+
+func envDurDays(k string, def int) time.Duration {
+	return time.Duration(max(envInt(k, def), 0)) * 24 * time.Hour
+}
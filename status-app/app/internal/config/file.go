@@ -0,0 +1,274 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"status/app/internal/checker"
+	"status/app/internal/models"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig is the declarative configuration file. Only YAML is supported.
+// Every section is optional; sections that are omitted leave the
+// env/database settings alone.
+type FileConfig struct {
+	Services  []FileService  `yaml:"services"`
+	Alerts    *FileAlerts    `yaml:"alerts"`
+	Resources *FileResources `yaml:"resources"`
+	Branding  *Branding      `yaml:"branding"`
+}
+
+// FileService defines a monitored service in the config file
+type FileService struct {
+	Key            string              `yaml:"key"`
+	Label          string              `yaml:"label"`
+	URL            string              `yaml:"url"`
+	TimeoutSeconds int                 `yaml:"timeout_seconds"`
+	IntervalSecs   int                 `yaml:"interval_seconds"`
+	JitterSecs     int                 `yaml:"jitter_seconds"`
+	Retries        int                 `yaml:"retries"`
+	RetryDelaySecs int                 `yaml:"retry_delay_seconds"`
+	MinOK          int                 `yaml:"min_ok"`
+	MaxOK          int                 `yaml:"max_ok"`
+	Assertions     []models.Assertion  `yaml:"assertions"`
+	HTTP           models.HTTPOptions  `yaml:"http"`
+	Policy         models.StatusPolicy `yaml:"policy"`
+	// Secret values may reference environment variables as ${NAME} so
+	// credentials don't have to be written into the file
+	Secrets models.ServiceSecrets `yaml:"secrets"`
+}
+
+// FileAlerts holds the email alert settings in the config file. Keys left
+// out of the file are nil and keep the value saved from the admin panel.
+type FileAlerts struct {
+	Enabled  *bool   `yaml:"enabled"`
+	SMTPHost *string `yaml:"smtp_host"`
+	SMTPPort *int    `yaml:"smtp_port"`
+	SMTPUser *string `yaml:"smtp_user"`
+	// May reference environment variables as ${NAME}
+	SMTPPassword    *string `yaml:"smtp_password"`
+	AlertEmail      *string `yaml:"alert_email"`
+	FromEmail       *string `yaml:"from_email"`
+	AlertOnDown     *bool   `yaml:"alert_on_down"`
+	AlertOnDegraded *bool   `yaml:"alert_on_degraded"`
+	AlertOnUp       *bool   `yaml:"alert_on_up"`
+	ReminderMinutes *int    `yaml:"reminder_minutes"`
+}
+
+// FileResources configures the Glances host used by the Resources section
+type FileResources struct {
+	GlancesURL string `yaml:"glances_url"`
+}
+
+// Branding customizes the public status page
+type Branding struct {
+	Title         string `yaml:"title" json:"title"`
+	StatusPageURL string `yaml:"status_page_url" json:"-"`
+}
+
+// LoadFile reads and validates a YAML config file. Unknown keys are rejected
+// so that typos don't silently fall back to defaults.
+func LoadFile(path string) (*FileConfig, error) {
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return nil, fmt.Errorf("%s: TOML is not supported, the config file must be YAML", path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fc FileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := fc.Validate(); err != nil {
+		return nil, fmt.Errorf("validate %s: %w", path, err)
+	}
+	return &fc, nil
+}
+
+// Validate checks the config file contents and fills in defaults
+func (fc *FileConfig) Validate() error {
+	seen := map[string]bool{}
+	for i, fs := range fc.Services {
+		svc := fs.toModel()
+		if err := svc.Validate(); err != nil {
+			return fmt.Errorf("services[%d] (%s): %w", i, fs.Key, err)
+		}
+		if err := checker.Validate(svc); err != nil {
+			return fmt.Errorf("services[%d] (%s): %w", i, fs.Key, err)
+		}
+		if seen[svc.Key] {
+			return fmt.Errorf("services[%d]: duplicate key %q", i, svc.Key)
+		}
+		seen[svc.Key] = true
+	}
+
+	if a := fc.Alerts; a != nil {
+		if a.SMTPPort != nil && (*a.SMTPPort < 1 || *a.SMTPPort > 65535) {
+			return errors.New("alerts: smtp_port must be between 1 and 65535")
+		}
+		if a.ReminderMinutes != nil && (*a.ReminderMinutes < 0 || *a.ReminderMinutes > 7*24*60) {
+			return errors.New("alerts: reminder_minutes must be between 0 and 10080")
+		}
+		if a.Enabled != nil && *a.Enabled && (isEmpty(a.SMTPHost) || isEmpty(a.AlertEmail)) {
+			return errors.New("alerts: smtp_host and alert_email are required when enabled")
+		}
+	}
+
+	if r := fc.Resources; r != nil && r.GlancesURL != "" {
+		u, err := url.Parse(r.GlancesURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("resources: glances_url must be an absolute http(s) URL")
+		}
+	}
+
+	if b := fc.Branding; b != nil && b.StatusPageURL != "" {
+		if _, err := url.ParseRequestURI(b.StatusPageURL); err != nil {
+			return errors.New("branding: status_page_url must be an absolute URL")
+		}
+	}
+	return nil
+}
+
+// ServiceModels converts the configured services into validated service models
+func (fc *FileConfig) ServiceModels() []*models.Service {
+	out := make([]*models.Service, 0, len(fc.Services))
+	for _, fs := range fc.Services {
+		svc := fs.toModel()
+		// Already validated on load; this fills in defaults and normalizes options
+		_ = svc.Validate()
+		_ = checker.Validate(svc)
+		svc.Source = models.ServiceSourceFile
+		out = append(out, svc)
+	}
+	return out
+}
+
+// isEmpty reports whether an optional key is set to an empty string
+func isEmpty(v *string) bool {
+	return v != nil && *v == ""
+}
+
+// MergeAlertConfig returns the current alert configuration with the keys
+// present in the alerts section applied on top
+func (a *FileAlerts) MergeAlertConfig(current *models.AlertConfig) (*models.AlertConfig, error) {
+	ac := *current
+	set(&ac.Enabled, a.Enabled)
+	set(&ac.SMTPHost, a.SMTPHost)
+	set(&ac.SMTPPort, a.SMTPPort)
+	set(&ac.SMTPUser, a.SMTPUser)
+	if a.SMTPPassword != nil {
+		ac.SMTPPassword = expandEnv(*a.SMTPPassword)
+	}
+	set(&ac.AlertEmail, a.AlertEmail)
+	set(&ac.FromEmail, a.FromEmail)
+	set(&ac.AlertOnDown, a.AlertOnDown)
+	set(&ac.AlertOnDegraded, a.AlertOnDegraded)
+	set(&ac.AlertOnUp, a.AlertOnUp)
+	set(&ac.ReminderMinutes, a.ReminderMinutes)
+
+	if ac.Enabled && (ac.SMTPHost == "" || ac.AlertEmail == "") {
+		return nil, errors.New("alerts: smtp_host and alert_email are required when enabled")
+	}
+	return &ac, nil
+}
+
+func set[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func (fs FileService) toModel() *models.Service {
+	return &models.Service{
+		Key:        fs.Key,
+		Label:      fs.Label,
+		URL:        fs.URL,
+		Timeout:    time.Duration(fs.TimeoutSeconds) * time.Second,
+		Interval:   time.Duration(fs.IntervalSecs) * time.Second,
+		Jitter:     time.Duration(fs.JitterSecs) * time.Second,
+		Retries:    fs.Retries,
+		RetryDelay: time.Duration(fs.RetryDelaySecs) * time.Second,
+		MinOK:      fs.MinOK,
+		MaxOK:      fs.MaxOK,
+		Assertions: fs.Assertions,
+		HTTP:       fs.HTTP,
+		Policy:     fs.Policy,
+		Secrets:    expandSecrets(fs.Secrets),
+	}
+}
+
+var envRefRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${NAME} references in a secret value with the
+// corresponding environment variables
+func expandEnv(v string) string {
+	return envRefRe.ReplaceAllStringFunc(v, func(ref string) string {
+		return os.Getenv(envRefRe.FindStringSubmatch(ref)[1])
+	})
+}
+
+// expandSecrets expands the environment references in service secrets
+func expandSecrets(sec models.ServiceSecrets) models.ServiceSecrets {
+	out := models.ServiceSecrets{Password: expandEnv(sec.Password), Token: expandEnv(sec.Token)}
+	if len(sec.Headers) > 0 {
+		out.Headers = make(map[string]string, len(sec.Headers))
+		for name, value := range sec.Headers {
+			out.Headers[name] = expandEnv(value)
+		}
+	}
+	return out
+}
+
+// WatchFile polls the config file and calls onChange with the new contents
+// whenever it is modified, until ctx is cancelled. Invalid files are logged
+// and ignored so a bad edit never takes down the running configuration.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func(*FileConfig)) {
+	var lastMod time.Time
+	var lastSize int64
+	if fi, err := os.Stat(path); err == nil {
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().Equal(lastMod) && fi.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+
+		fc, err := LoadFile(path)
+		if err != nil {
+			log.Printf("config: ignoring invalid config file: %v", err)
+			continue
+		}
+		log.Printf("config: %s changed, applying", path)
+		onChange(fc)
+	}
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"status/app/internal/models"
+	"strings"
+	"time"
+)
+
+// SaveCertInfo records the latest certificate seen for a tls:// service
+func SaveCertInfo(key string, cert *models.CertInfo) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := DB.Exec(`INSERT INTO service_certs (service_key, subject, issuer, dns_names, expires_at, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(service_key) DO UPDATE SET subject=?, issuer=?, dns_names=?, expires_at=?, checked_at=?`,
+		key, cert.Subject, cert.Issuer, strings.Join(cert.DNSNames, ","), cert.ExpiresAt.UTC().Format(time.RFC3339), now,
+		cert.Subject, cert.Issuer, strings.Join(cert.DNSNames, ","), cert.ExpiresAt.UTC().Format(time.RFC3339), now)
+	return err
+}
+
+// LoadCertInfos returns the latest recorded certificate per service key,
+// with days-until-expiry computed against the current time
+func LoadCertInfos() (map[string]*models.CertInfo, error) {
+	rows, err := DB.Query(`SELECT service_key, subject, issuer, dns_names, expires_at FROM service_certs`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]*models.CertInfo{}
+	for rows.Next() {
+		var key, subject, issuer, dnsNames, expiresAt string
+		if err := rows.Scan(&key, &subject, &issuer, &dnsNames, &expiresAt); err != nil {
+			return nil, err
+		}
+		exp, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			continue
+		}
+		cert := &models.CertInfo{
+			Subject:   subject,
+			Issuer:    issuer,
+			ExpiresAt: exp,
+			DaysLeft:  int(time.Until(exp).Hours() / 24),
+		}
+		if dnsNames != "" {
+			cert.DNSNames = strings.Split(dnsNames, ",")
+		}
+		out[key] = cert
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Conn is a database handle for one of the supported drivers. Queries are
+// written once with ? placeholders and portable SQL; Conn rewrites the
+// placeholders for drivers that use another style.
+type Conn struct {
+	*sql.DB
+	driver string
+}
+
+// Tx is a transaction on a Conn
+type Tx struct {
+	*sql.Tx
+	driver string
+}
+
+func openConn(driver, dsn string) (*Conn, error) {
+	switch driver {
+	case DriverSQLite, DriverPostgres:
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+	if driver == DriverSQLite && !strings.Contains(dsn, "busy_timeout") {
+		// Wait for other writers instead of failing with SQLITE_BUSY; the
+		// scheduler, handlers and notification worker write concurrently
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_pragma=busy_timeout(5000)"
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Conn{DB: db, driver: driver}, nil
+}
+
+// Driver returns the name of the driver behind the connection
+func (c *Conn) Driver() string {
+	return c.driver
+}
+
+func (c *Conn) Exec(query string, args ...any) (sql.Result, error) {
+	return c.DB.Exec(rebind(c.driver, query), args...)
+}
+
+func (c *Conn) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.DB.Query(rebind(c.driver, query), args...)
+}
+
+func (c *Conn) QueryRow(query string, args ...any) *sql.Row {
+	return c.DB.QueryRow(rebind(c.driver, query), args...)
+}
+
+func (c *Conn) Begin() (*Tx, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, driver: c.driver}, nil
+}
+
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.Tx.Exec(rebind(tx.driver, query), args...)
+}
+
+func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.Query(rebind(tx.driver, query), args...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRow(rebind(tx.driver, query), args...)
+}
+
+// rebind turns ? placeholders into $1, $2, ... for PostgreSQL, leaving
+// question marks inside string literals alone
+func rebind(driver, query string) string {
+	if driver != DriverPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n, quoted := 0, false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			quoted = !quoted
+		case ch == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+// ddl adapts a CREATE statement written for SQLite to the connection's
+// driver. Only the column types the schema uses need translating.
+func ddl(driver, stmt string) string {
+	if driver != DriverPostgres {
+		return stmt
+	}
+	return strings.NewReplacer(
+		" INTEGER PRIMARY KEY AUTOINCREMENT", " BIGSERIAL PRIMARY KEY",
+		" REAL", " DOUBLE PRECISION",
+	).Replace(stmt)
+}
@@ -0,0 +1,33 @@
+package database
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		driver, query, want string
+	}{
+		{DriverSQLite, `SELECT * FROM t WHERE a = ? AND b = ?`, `SELECT * FROM t WHERE a = ? AND b = ?`},
+		{DriverPostgres, `SELECT * FROM t WHERE a = ? AND b = ?`, `SELECT * FROM t WHERE a = $1 AND b = $2`},
+		{DriverPostgres, `SELECT 1`, `SELECT 1`},
+		{DriverPostgres, `INSERT INTO t (a,b,c) VALUES (?,?,?)`, `INSERT INTO t (a,b,c) VALUES ($1,$2,$3)`},
+		{DriverPostgres, `UPDATE t SET note = 'why?' WHERE id = ?`, `UPDATE t SET note = 'why?' WHERE id = $1`},
+		{DriverPostgres, `SELECT ? WHERE x = 'it''s?' AND y = ?`, `SELECT $1 WHERE x = 'it''s?' AND y = $2`},
+		{DriverPostgres, `SELECT ?,?,?,?,?,?,?,?,?,?,?`, `SELECT $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11`},
+	}
+	for _, tt := range tests {
+		if got := rebind(tt.driver, tt.query); got != tt.want {
+			t.Errorf("rebind(%s, %q) = %q, want %q", tt.driver, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDDL(t *testing.T) {
+	stmt := `CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, ms REAL, n INTEGER)`
+	if got := ddl(DriverSQLite, stmt); got != stmt {
+		t.Errorf("sqlite: %q", got)
+	}
+	want := `CREATE TABLE t (id BIGSERIAL PRIMARY KEY, ms DOUBLE PRECISION, n INTEGER)`
+	if got := ddl(DriverPostgres, stmt); got != want {
+		t.Errorf("postgres: %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,420 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"status/app/internal/models"
+	"strings"
+	"time"
+)
+
+// ErrIncidentNotFound is returned when an incident id does not exist
+var ErrIncidentNotFound = errors.New("incident not found")
+
+// ErrIncidentReopen is returned for an update that would reopen a resolved
+// automatic incident; a later outage opens a new one instead
+var ErrIncidentReopen = errors.New("a resolved automatic incident cannot be reopened")
+
+// Incident sources
+const (
+	incidentAuto   = "auto"
+	incidentManual = "manual"
+)
+
+// OpenIncident starts an automatic incident for a service that went down,
+// unless one is already open for it, and returns the id of the open incident
+func OpenIncident(key, title, rootError string, at time.Time) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	open, err := openAutoIncidents(tx, key)
+	if err != nil {
+		return 0, err
+	}
+	if len(open) > 0 {
+		return open[0], nil
+	}
+
+	ts := at.UTC().Format(time.RFC3339)
+	var id int64
+	if err := tx.QueryRow(`INSERT INTO incidents (service_key, started_at, root_error, title, status, impact, source) VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		key, ts, rootError, title, models.IncidentInvestigating, models.ImpactMajor, incidentAuto).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := setIncidentServices(tx, id, []string{key}); err != nil {
+		return 0, err
+	}
+	if err := insertIncidentUpdate(tx, id, models.IncidentInvestigating, "Monitoring detected that the service is down.", ts); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// ResolveIncident ends the open automatic incident of a service that
+// recovered and returns its id, 0 if none was open
+func ResolveIncident(key string, at time.Time) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ids, err := openAutoIncidents(tx, key)
+	if err != nil {
+		return 0, err
+	}
+	ts := at.UTC().Format(time.RFC3339)
+	var resolved int64
+	for _, id := range ids {
+		if _, err := tx.Exec(`UPDATE incidents SET resolved_at = ?, status = ? WHERE id = ?`, ts, models.IncidentResolved, id); err != nil {
+			return 0, err
+		}
+		if err := insertIncidentUpdate(tx, id, models.IncidentResolved, "The service has recovered.", ts); err != nil {
+			return 0, err
+		}
+		resolved = id
+	}
+	return resolved, tx.Commit()
+}
+
+// OpenIncidentID returns the id of the open automatic incident of a
+// service, 0 if there is none
+func OpenIncidentID(key string) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT id FROM incidents WHERE service_key = ? AND source = ? AND resolved_at IS NULL ORDER BY id LIMIT 1`,
+		key, incidentAuto).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// AcknowledgeIncident records who acknowledged an incident, which stops its
+// reminders. It reports false if the incident was already acknowledged.
+func AcknowledgeIncident(id int64, by string, at time.Time) (bool, error) {
+	res, err := DB.Exec(`UPDATE incidents SET acknowledged_at = ?, acknowledged_by = ? WHERE id = ? AND acknowledged_at IS NULL`,
+		at.UTC().Format(time.RFC3339), by, id)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return true, nil
+	}
+	var exists int
+	err = DB.QueryRow(`SELECT 1 FROM incidents WHERE id = ?`, id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrIncidentNotFound
+	}
+	return false, err
+}
+
+// ClaimReminder marks a reminder as sent for an open, unacknowledged
+// incident whose last reminder, or start, is at least interval ago. It
+// reports whether the reminder is due, so only one caller sends it.
+func ClaimReminder(id int64, interval time.Duration, at time.Time) (bool, error) {
+	res, err := DB.Exec(`UPDATE incidents SET reminded_at = ?
+		WHERE id = ? AND resolved_at IS NULL AND acknowledged_at IS NULL AND COALESCE(reminded_at, started_at) <= ?`,
+		at.UTC().Format(time.RFC3339), id, at.Add(-interval).UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func openAutoIncidents(tx *Tx, key string) ([]int64, error) {
+	rows, err := tx.Query(`SELECT id FROM incidents WHERE service_key = ? AND source = ? AND resolved_at IS NULL ORDER BY id`, key, incidentAuto)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CreateIncident opens an incident posted by an admin, with message as its
+// first update, and returns its id
+func CreateIncident(inc *models.Incident, message string, at time.Time) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ts := at.UTC().Format(time.RFC3339)
+	var resolvedAt any
+	if inc.Status == models.IncidentResolved {
+		resolvedAt = ts
+	}
+	var id int64
+	if err := tx.QueryRow(`INSERT INTO incidents (service_key, started_at, resolved_at, title, status, impact, source) VALUES ('', ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		ts, resolvedAt, inc.Title, inc.Status, inc.Impact, incidentManual).Scan(&id); err != nil {
+		return 0, err
+	}
+	keys := make([]string, 0, len(inc.Services))
+	for _, s := range inc.Services {
+		keys = append(keys, s.Key)
+	}
+	if err := setIncidentServices(tx, id, keys); err != nil {
+		return 0, err
+	}
+	if err := insertIncidentUpdate(tx, id, inc.Status, message, ts); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// AddIncidentUpdate posts an update to an incident and moves it to the
+// update's status. A non-empty impact and non-nil services replace the
+// incident's impact and affected services. Resolving an incident ends it;
+// any other status reopens a resolved manual incident. Resolved automatic
+// incidents cannot be reopened, as the next outage would be added to them.
+func AddIncidentUpdate(id int64, status, message, impact string, services []string, at time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var source string
+	var wasResolved sql.NullString
+	err = tx.QueryRow(`SELECT source, resolved_at FROM incidents WHERE id = ?`, id).Scan(&source, &wasResolved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrIncidentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if source == incidentAuto && wasResolved.Valid && status != models.IncidentResolved {
+		return ErrIncidentReopen
+	}
+
+	ts := at.UTC().Format(time.RFC3339)
+	var resolvedAt any
+	if status == models.IncidentResolved {
+		resolvedAt = ts
+	}
+	if _, err := tx.Exec(`UPDATE incidents SET status = ?, resolved_at = ?, impact = COALESCE(NULLIF(?, ''), impact) WHERE id = ?`,
+		status, resolvedAt, impact, id); err != nil {
+		return err
+	}
+	if services != nil {
+		if err := setIncidentServices(tx, id, services); err != nil {
+			return err
+		}
+	}
+	if err := insertIncidentUpdate(tx, id, status, message, ts); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setIncidentServices(tx *Tx, id int64, keys []string) error {
+	if _, err := tx.Exec(`DELETE FROM incident_services WHERE incident_id = ?`, id); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, err := tx.Exec(`INSERT INTO incident_services (incident_id, service_key) VALUES (?, ?) ON CONFLICT DO NOTHING`, id, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertIncidentUpdate(tx *Tx, id int64, status, message, ts string) error {
+	_, err := tx.Exec(`INSERT INTO incident_updates (incident_id, status, message, created_at) VALUES (?, ?, ?, ?)`,
+		id, status, message, ts)
+	return err
+}
+
+// ListIncidents returns the incidents that were open at some point since
+// the given time, newest first, with their affected services (keys only)
+// and updates
+func ListIncidents(since time.Time) ([]models.Incident, error) {
+	cutoff := since.UTC().Format(time.RFC3339)
+	rows, err := DB.Query(`SELECT id, service_key, started_at, resolved_at, root_error, title, status, impact, source, acknowledged_at, acknowledged_by FROM incidents
+		WHERE resolved_at IS NULL OR resolved_at >= ?
+		ORDER BY started_at DESC, id DESC`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	out := []models.Incident{}
+	byID := map[int64]*models.Incident{}
+	for rows.Next() {
+		var inc models.Incident
+		var startedAt, source string
+		var resolvedAt, rootError, ackAt, ackBy sql.NullString
+		if err := rows.Scan(&inc.ID, &inc.ServiceKey, &startedAt, &resolvedAt, &rootError, &inc.Title, &inc.Status, &inc.Impact, &source, &ackAt, &ackBy); err != nil {
+			return nil, err
+		}
+		inc.StartedAt, _ = time.Parse(time.RFC3339, startedAt)
+		end := now
+		if resolvedAt.Valid {
+			if t, err := time.Parse(time.RFC3339, resolvedAt.String); err == nil {
+				inc.ResolvedAt = &t
+				end = t
+			}
+		}
+		inc.DurationSeconds = int(end.Sub(inc.StartedAt).Seconds())
+		inc.RootError = rootError.String
+		if t, err := time.Parse(time.RFC3339, ackAt.String); ackAt.Valid && err == nil {
+			inc.AcknowledgedAt = &t
+			inc.AcknowledgedBy = ackBy.String
+		}
+		inc.Automatic = source == incidentAuto
+		inc.Services = []models.IncidentService{}
+		inc.Updates = []models.IncidentUpdate{}
+		out = append(out, inc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range out {
+		byID[out[i].ID] = &out[i]
+	}
+
+	inWindow := `SELECT id FROM incidents WHERE resolved_at IS NULL OR resolved_at >= ?`
+	svcRows, err := DB.Query(`SELECT incident_id, service_key FROM incident_services WHERE incident_id IN (`+inWindow+`) ORDER BY service_key`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer svcRows.Close()
+	for svcRows.Next() {
+		var id int64
+		var key string
+		if err := svcRows.Scan(&id, &key); err != nil {
+			return nil, err
+		}
+		if inc := byID[id]; inc != nil {
+			inc.Services = append(inc.Services, models.IncidentService{Key: key})
+		}
+	}
+	if err := svcRows.Err(); err != nil {
+		return nil, err
+	}
+
+	updRows, err := DB.Query(`SELECT id, incident_id, status, message, created_at FROM incident_updates
+		WHERE incident_id IN (`+inWindow+`) ORDER BY created_at DESC, id DESC`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer updRows.Close()
+	for updRows.Next() {
+		var u models.IncidentUpdate
+		var incidentID int64
+		var createdAt string
+		if err := updRows.Scan(&u.ID, &incidentID, &u.Status, &u.Message, &createdAt); err != nil {
+			return nil, err
+		}
+		u.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		if inc := byID[incidentID]; inc != nil {
+			inc.Updates = append(inc.Updates, u)
+		}
+	}
+	if err := updRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Incidents opened before affected services were recorded
+	for i := range out {
+		if len(out[i].Services) == 0 && out[i].ServiceKey != "" {
+			out[i].Services = append(out[i].Services, models.IncidentService{Key: out[i].ServiceKey})
+		}
+	}
+	return out, nil
+}
+
+// DeleteIncident removes an incident and its updates, e.g. one caused by a
+// monitoring mistake
+func DeleteIncident(id int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(`DELETE FROM incidents WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrIncidentNotFound
+	}
+	if err := deleteIncidentDetails(tx, []int64{id}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteResolvedIncidents removes resolved automatic incidents that started
+// since the given time. Incidents posted by admins are kept.
+func DeleteResolvedIncidents(since time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Query(`SELECT id FROM incidents WHERE source = ? AND resolved_at IS NOT NULL AND started_at >= ?`,
+		incidentAuto, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := deleteIncidentDetails(tx, ids); err != nil {
+		return err
+	}
+	placeholders, args := idArgs(ids)
+	if _, err := tx.Exec(`DELETE FROM incidents WHERE source = ? AND id IN (`+placeholders+`)`, append([]any{incidentAuto}, args...)...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteIncidentDetails(tx *Tx, ids []int64) error {
+	placeholders, args := idArgs(ids)
+	if _, err := tx.Exec(`DELETE FROM incident_services WHERE incident_id IN (`+placeholders+`)`, args...); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM incident_updates WHERE incident_id IN (`+placeholders+`)`, args...)
+	return err
+}
+
+func idArgs(ids []int64) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","), args
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"status/app/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClaimReminder(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+		id, err := OpenIncident("plex", "Plex is down", "connection refused", start)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claim := func(at time.Time) bool {
+			t.Helper()
+			due, err := ClaimReminder(id, 30*time.Minute, at)
+			if err != nil {
+				t.Fatalf("ClaimReminder: %v", err)
+			}
+			return due
+		}
+		// Due an interval after the start, then an interval after the last
+		// reminder, and claimed only once
+		for _, step := range []struct {
+			after time.Duration
+			due   bool
+		}{
+			{10 * time.Minute, false},
+			{30 * time.Minute, true},
+			{30 * time.Minute, false},
+			{59 * time.Minute, false},
+			{60 * time.Minute, true},
+		} {
+			if got := claim(start.Add(step.after)); got != step.due {
+				t.Errorf("reminder due after %v = %v, want %v", step.after, got, step.due)
+			}
+		}
+
+		// Acknowledged incidents are not reminded of
+		if ok, err := AcknowledgeIncident(id, "admin", start.Add(70*time.Minute)); err != nil || !ok {
+			t.Fatalf("AcknowledgeIncident = %v, %v", ok, err)
+		}
+		if claim(start.Add(2 * time.Hour)) {
+			t.Error("reminder due after the acknowledgement")
+		}
+		if ok, err := AcknowledgeIncident(id, "admin", start.Add(2*time.Hour)); err != nil || ok {
+			t.Errorf("second AcknowledgeIncident = %v, %v", ok, err)
+		}
+
+		// Neither are resolved ones
+		id, err = OpenIncident("nas", "NAS is down", "timeout", start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ResolveIncident("nas", start.Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if claim(start.Add(time.Hour)) {
+			t.Error("reminder due for a resolved incident")
+		}
+
+		if _, err := AcknowledgeIncident(999, "admin", start); err != ErrIncidentNotFound {
+			t.Errorf("AcknowledgeIncident of a missing incident: %v", err)
+		}
+	})
+}
+
+func TestManualIncidentUpdates(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+		get := func(id int64) models.Incident {
+			t.Helper()
+			list, err := ListIncidents(start.Add(-time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, inc := range list {
+				if inc.ID == id {
+					return inc
+				}
+			}
+			t.Fatalf("incident %d not listed", id)
+			return models.Incident{}
+		}
+
+		id, err := CreateIncident(&models.Incident{
+			Title:    "Storage maintenance overran",
+			Status:   models.IncidentIdentified,
+			Impact:   models.ImpactMinor,
+			Services: []models.IncidentService{{Key: "nas"}, {Key: "plex"}},
+		}, "The array is rebuilding.", start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inc := get(id); inc.Automatic || inc.Status != models.IncidentIdentified || inc.Impact != models.ImpactMinor ||
+			inc.ResolvedAt != nil || len(inc.Services) != 2 || len(inc.Updates) != 1 || inc.Updates[0].Message != "The array is rebuilding." {
+			t.Fatalf("created incident = %+v", inc)
+		}
+
+		steps := []struct {
+			name         string
+			status       string
+			impact       string
+			services     []string
+			wantImpact   string
+			wantServices []string
+			wantResolved bool
+		}{
+			{"status only", models.IncidentMonitoring, "", nil, models.ImpactMinor, []string{"nas", "plex"}, false},
+			{"impact and services replaced", models.IncidentMonitoring, models.ImpactMajor, []string{"nas"}, models.ImpactMajor, []string{"nas"}, false},
+			{"resolved", models.IncidentResolved, "", nil, models.ImpactMajor, []string{"nas"}, true},
+			{"reopened", models.IncidentInvestigating, "", nil, models.ImpactMajor, []string{"nas"}, false},
+			{"services cleared", models.IncidentIdentified, "", []string{}, models.ImpactMajor, nil, false},
+			{"resolved again", models.IncidentResolved, "", nil, models.ImpactMajor, nil, true},
+		}
+		for i, step := range steps {
+			at := start.Add(time.Duration(i+1) * time.Minute)
+			if err := AddIncidentUpdate(id, step.status, step.name, step.impact, step.services, at); err != nil {
+				t.Fatalf("%s: AddIncidentUpdate: %v", step.name, err)
+			}
+			inc := get(id)
+			if inc.Status != step.status || inc.Impact != step.wantImpact {
+				t.Errorf("%s: status %q, impact %q, want %q, %q", step.name, inc.Status, inc.Impact, step.status, step.wantImpact)
+			}
+			if resolved := inc.ResolvedAt != nil; resolved != step.wantResolved || (resolved && !inc.ResolvedAt.Equal(at)) {
+				t.Errorf("%s: resolved at %v, want resolved %v at %v", step.name, inc.ResolvedAt, step.wantResolved, at)
+			}
+			var keys []string
+			for _, svc := range inc.Services {
+				keys = append(keys, svc.Key)
+			}
+			if strings.Join(keys, ",") != strings.Join(step.wantServices, ",") {
+				t.Errorf("%s: services %v, want %v", step.name, keys, step.wantServices)
+			}
+			if len(inc.Updates) != i+2 || inc.Updates[0].Status != step.status || inc.Updates[0].Message != step.name {
+				t.Errorf("%s: latest update %+v of %d", step.name, inc.Updates[0], len(inc.Updates))
+			}
+		}
+
+		if err := AddIncidentUpdate(999, models.IncidentMonitoring, "", "", nil, start); err != ErrIncidentNotFound {
+			t.Errorf("update of a missing incident: %v, want ErrIncidentNotFound", err)
+		}
+	})
+}
+
+func TestAutomaticIncidentUpdates(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+		id, err := OpenIncident("plex", "Plex is down", "connection refused", start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, err := OpenIncident("plex", "Plex is down", "timeout", start.Add(time.Minute)); err != nil || again != id {
+			t.Errorf("OpenIncident while open = %d, %v, want %d", again, err, id)
+		}
+
+		// Admins can post to an open automatic incident, and resolving it
+		// is final
+		if err := AddIncidentUpdate(id, models.IncidentIdentified, "A disk failed.", "", nil, start.Add(2*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddIncidentUpdate(id, models.IncidentResolved, "Disk replaced.", "", nil, start.Add(3*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddIncidentUpdate(id, models.IncidentMonitoring, "Still watching.", "", nil, start.Add(4*time.Minute)); err != ErrIncidentReopen {
+			t.Errorf("reopening an automatic incident: %v, want ErrIncidentReopen", err)
+		}
+		if open, err := OpenIncidentID("plex"); err != nil || open != 0 {
+			t.Errorf("OpenIncidentID = %d, %v, want none", open, err)
+		}
+		if resolved, err := ResolveIncident("plex", start.Add(5*time.Minute)); err != nil || resolved != 0 {
+			t.Errorf("ResolveIncident without an open incident = %d, %v", resolved, err)
+		}
+
+		// Clearing resolved incidents keeps the ones admins posted
+		manual, err := CreateIncident(&models.Incident{Title: "Planned move", Status: models.IncidentResolved, Impact: models.ImpactNone}, "Done.", start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := DeleteResolvedIncidents(start); err != nil {
+			t.Fatal(err)
+		}
+		list, err := ListIncidents(start.Add(-time.Hour))
+		if err != nil || len(list) != 1 || list[0].ID != manual || list[0].ResolvedAt == nil {
+			t.Errorf("incidents after DeleteResolvedIncidents = %+v, %v", list, err)
+		}
+	})
+}
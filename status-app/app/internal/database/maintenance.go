@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"status/app/internal/models"
+	"time"
+)
+
+// ErrMaintenanceNotFound is returned when a maintenance window id does not exist
+var ErrMaintenanceNotFound = errors.New("maintenance window not found")
+
+// ListMaintenanceWindows returns all maintenance windows, earliest first
+func ListMaintenanceWindows() ([]*models.MaintenanceWindow, error) {
+	rows, err := DB.Query(`SELECT id, title, message, services, starts_at, duration_mins, rrule, cron
+		FROM maintenance_windows ORDER BY starts_at ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*models.MaintenanceWindow{}
+	for rows.Next() {
+		var m models.MaintenanceWindow
+		var startsAt string
+		var message, services, rrule, cron sql.NullString
+		if err := rows.Scan(&m.ID, &m.Title, &message, &services, &startsAt, &m.DurationMinutes, &rrule, &cron); err != nil {
+			return nil, err
+		}
+		if services.Valid && services.String != "" {
+			if err := json.Unmarshal([]byte(services.String), &m.Services); err != nil {
+				return nil, err
+			}
+		}
+		if m.Services == nil {
+			m.Services = []string{}
+		}
+		m.StartsAt, _ = time.Parse(time.RFC3339, startsAt)
+		m.Message, m.RRule, m.Cron = message.String, rrule.String, cron.String
+		out = append(out, &m)
+	}
+	return out, rows.Err()
+}
+
+// InsertMaintenanceWindow stores a new maintenance window and returns its id
+func InsertMaintenanceWindow(m *models.MaintenanceWindow) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`INSERT INTO maintenance_windows (title, message, services, starts_at, duration_mins, rrule, cron)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		m.Title, nullString(m.Message), listJSON(m.Services), m.StartsAt.UTC().Format(time.RFC3339), m.DurationMinutes,
+		nullString(m.RRule), nullString(m.Cron)).Scan(&id)
+	return id, err
+}
+
+// UpdateMaintenanceWindow replaces the definition of a maintenance window
+func UpdateMaintenanceWindow(m *models.MaintenanceWindow) error {
+	res, err := DB.Exec(`UPDATE maintenance_windows SET title = ?, message = ?, services = ?, starts_at = ?, duration_mins = ?, rrule = ?, cron = ?
+		WHERE id = ?`,
+		m.Title, nullString(m.Message), listJSON(m.Services), m.StartsAt.UTC().Format(time.RFC3339), m.DurationMinutes,
+		nullString(m.RRule), nullString(m.Cron), m.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMaintenanceNotFound
+	}
+	return nil
+}
+
+// DeleteMaintenanceWindow removes a maintenance window. Samples already
+// tagged as maintenance keep their tag.
+func DeleteMaintenanceWindow(id int64) error {
+	res, err := DB.Exec(`DELETE FROM maintenance_windows WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMaintenanceNotFound
+	}
+	return nil
+}
+
+// listJSON encodes a list such as the service keys of a window; an empty
+// list, e.g. all services, is stored as NULL
+func listJSON(values []string) any {
+	if len(values) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,543 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer build
+// than this one; running against it could corrupt data the older code does
+// not understand
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// Migration is a numbered schema change. Each migration runs in its own
+// transaction and is recorded in schema_migrations when it commits.
+type Migration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time // zero while pending
+	up        func(tx *Tx) error
+}
+
+// migrations lists every schema change in order. Append new migrations to
+// the end and never edit or renumber one that has shipped.
+var migrations = []Migration{
+	{Version: 1, Name: "baseline", up: migrateBaseline},
+	{Version: 2, Name: "maintenance windows", up: migrateMaintenanceWindows},
+	{Version: 3, Name: "sample rollups", up: migrateSampleRollups},
+	{Version: 4, Name: "notification channels", up: migrateNotificationChannels},
+	{Version: 5, Name: "notification outbox", up: migrateNotificationOutbox},
+	{Version: 6, Name: "alert routing", up: migrateAlertRouting},
+	{Version: 7, Name: "incident acknowledgement", up: migrateIncidentAcknowledgement},
+	{Version: 8, Name: "direct notification attempts", up: migrateDirectAttempts},
+}
+
+// LatestSchemaVersion is the schema version this build migrates to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ensureMigrationsTable creates the table tracking applied migrations
+func ensureMigrationsTable() error {
+	_, err := DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  applied_at TEXT NOT NULL
+)`)
+	return err
+}
+
+// SchemaVersion returns the highest applied migration, 0 for a new database
+func SchemaVersion() (int, error) {
+	var v int
+	err := DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	return v, err
+}
+
+// checkSchemaVersion refuses databases migrated past what this build knows
+func checkSchemaVersion() error {
+	v, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); v > latest {
+		return fmt.Errorf("%w: database is at version %d, this build supports up to %d", ErrSchemaTooNew, v, latest)
+	}
+	return nil
+}
+
+// Migrations returns all known migrations with the time each was applied
+func Migrations() ([]Migration, error) {
+	rows, err := DB.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var v int
+		var at string
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v], _ = time.Parse(time.RFC3339, at)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]Migration, len(migrations))
+	for i, m := range migrations {
+		m.AppliedAt = applied[m.Version]
+		out[i] = m
+	}
+	return out, nil
+}
+
+// PendingMigrations returns the migrations not yet applied, in order
+func PendingMigrations() ([]Migration, error) {
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range all {
+		if m.AppliedAt.IsZero() {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies all pending migrations and returns the ones it applied.
+// It stops at the first failure, leaving that migration unapplied.
+func Migrate() ([]Migration, error) {
+	pending, err := PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, m := range pending {
+		if err := applyMigration(m); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+func applyMigration(m Migration) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addColumn adds a column unless the table already has it. Databases
+// created before migrations were tracked may have any subset of the columns
+// added over time.
+func addColumn(tx *Tx, table, column, def string) error {
+	query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	if tx.driver == DriverPostgres {
+		query = `SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`
+	}
+	var n int
+	if err := tx.QueryRow(query, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := tx.Exec(ddl(tx.driver, `ALTER TABLE `+table+` ADD COLUMN `+column+` `+def))
+	return err
+}
+
+// migrateBaseline creates the schema as it was before migrations were
+// tracked and brings older installs up to it
+func migrateBaseline(tx *Tx) error {
+	_, err := tx.Exec(ddl(tx.driver, `
+CREATE TABLE IF NOT EXISTS samples (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  taken_at TEXT NOT NULL,
+  service_key TEXT NOT NULL,
+  ok INTEGER NOT NULL,
+  http_status INTEGER,
+  latency_ms INTEGER,
+  error TEXT,
+  check_status TEXT,
+  meta TEXT,
+  dns_ms REAL,
+  connect_ms REAL,
+  tls_ms REAL,
+  ttfb_ms REAL,
+  transfer_ms REAL,
+  attempts INTEGER NOT NULL DEFAULT 1,
+  attempt_errors TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_samples_taken ON samples(taken_at);
+CREATE INDEX IF NOT EXISTS idx_samples_service ON samples(service_key);
+
+CREATE TABLE IF NOT EXISTS ip_blocks (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  ip_address TEXT NOT NULL,
+  blocked_at TEXT,
+  attempts INTEGER NOT NULL DEFAULT 1,
+  expires_at TEXT NOT NULL,
+  reason TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_ip_blocks_ip ON ip_blocks(ip_address);
+CREATE INDEX IF NOT EXISTS idx_ip_blocks_expires ON ip_blocks(expires_at);
+
+CREATE TABLE IF NOT EXISTS service_state (
+  service_key TEXT PRIMARY KEY,
+  disabled INTEGER NOT NULL DEFAULT 0,
+  updated_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS alert_config (
+  id INTEGER PRIMARY KEY CHECK (id = 1),
+  enabled INTEGER NOT NULL DEFAULT 0,
+  smtp_host TEXT,
+  smtp_port INTEGER DEFAULT 587,
+  smtp_user TEXT,
+  smtp_password TEXT,
+  alert_email TEXT,
+  from_email TEXT,
+  alert_on_down INTEGER NOT NULL DEFAULT 1,
+  alert_on_degraded INTEGER NOT NULL DEFAULT 1,
+  alert_on_up INTEGER NOT NULL DEFAULT 0,
+  updated_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS resources_ui_config (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	enabled INTEGER NOT NULL DEFAULT 1,
+	cpu INTEGER NOT NULL DEFAULT 1,
+	memory INTEGER NOT NULL DEFAULT 1,
+	network INTEGER NOT NULL DEFAULT 1,
+	temp INTEGER NOT NULL DEFAULT 1,
+	storage INTEGER NOT NULL DEFAULT 1,
+	updated_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS service_status_history (
+  service_key TEXT PRIMARY KEY,
+  ok INTEGER NOT NULL,
+  degraded INTEGER NOT NULL,
+  updated_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS services (
+  service_key TEXT PRIMARY KEY,
+  label TEXT NOT NULL,
+  url TEXT NOT NULL,
+  timeout_secs INTEGER NOT NULL DEFAULT 5,
+  interval_secs INTEGER NOT NULL DEFAULT 0,
+  jitter_secs INTEGER NOT NULL DEFAULT 0,
+  retries INTEGER NOT NULL DEFAULT 0,
+  retry_delay_secs INTEGER NOT NULL DEFAULT 0,
+  min_ok INTEGER NOT NULL DEFAULT 200,
+  max_ok INTEGER NOT NULL DEFAULT 399,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+  source TEXT NOT NULL DEFAULT 'api',
+  assertions TEXT,
+  http_options TEXT,
+  status_policy TEXT,
+  created_at TEXT,
+  updated_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS service_secrets (
+  service_key TEXT NOT NULL,
+  name TEXT NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (service_key, name)
+);
+
+CREATE TABLE IF NOT EXISTS service_certs (
+  service_key TEXT PRIMARY KEY,
+  subject TEXT,
+  issuer TEXT,
+  dns_names TEXT,
+  expires_at TEXT NOT NULL,
+  checked_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS incidents (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  service_key TEXT NOT NULL,
+  started_at TEXT NOT NULL,
+  resolved_at TEXT,
+  root_error TEXT,
+  title TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'investigating',
+  impact TEXT NOT NULL DEFAULT 'major',
+  source TEXT NOT NULL DEFAULT 'auto'
+);
+CREATE INDEX IF NOT EXISTS idx_incidents_service ON incidents(service_key, resolved_at);
+
+CREATE TABLE IF NOT EXISTS incident_services (
+  incident_id INTEGER NOT NULL,
+  service_key TEXT NOT NULL,
+  PRIMARY KEY (incident_id, service_key)
+);
+
+CREATE TABLE IF NOT EXISTS incident_updates (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  incident_id INTEGER NOT NULL,
+  status TEXT NOT NULL,
+  message TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_incident_updates_incident ON incident_updates(incident_id);
+
+CREATE TABLE IF NOT EXISTS status_alerts (
+  id TEXT PRIMARY KEY,
+  service_key TEXT,
+  message TEXT NOT NULL,
+  level TEXT NOT NULL DEFAULT 'info',
+  created_at TEXT NOT NULL
+);
+`))
+	if err != nil {
+		return err
+	}
+
+	// Columns introduced before migrations were tracked
+	columns := []struct{ table, column, def string }{
+		{"resources_ui_config", "storage", "INTEGER NOT NULL DEFAULT 1"},
+		{"services", "source", "TEXT NOT NULL DEFAULT 'api'"},
+		{"services", "assertions", "TEXT"},
+		{"services", "http_options", "TEXT"},
+		{"services", "interval_secs", "INTEGER NOT NULL DEFAULT 0"},
+		{"services", "jitter_secs", "INTEGER NOT NULL DEFAULT 0"},
+		{"services", "status_policy", "TEXT"},
+		{"services", "retries", "INTEGER NOT NULL DEFAULT 0"},
+		{"services", "retry_delay_secs", "INTEGER NOT NULL DEFAULT 0"},
+		{"samples", "error", "TEXT"},
+		{"samples", "check_status", "TEXT"},
+		{"samples", "meta", "TEXT"},
+		{"samples", "dns_ms", "REAL"},
+		{"samples", "connect_ms", "REAL"},
+		{"samples", "tls_ms", "REAL"},
+		{"samples", "ttfb_ms", "REAL"},
+		{"samples", "transfer_ms", "REAL"},
+		{"samples", "attempts", "INTEGER NOT NULL DEFAULT 1"},
+		{"samples", "attempt_errors", "TEXT"},
+		{"incidents", "title", "TEXT NOT NULL DEFAULT ''"},
+		{"incidents", "status", "TEXT NOT NULL DEFAULT 'investigating'"},
+		{"incidents", "impact", "TEXT NOT NULL DEFAULT 'major'"},
+		{"incidents", "source", "TEXT NOT NULL DEFAULT 'auto'"},
+	}
+	for _, c := range columns {
+		if err := addColumn(tx, c.table, c.column, c.def); err != nil {
+			return err
+		}
+	}
+
+	// Incidents resolved before they had a status
+	_, err = tx.Exec(`UPDATE incidents SET status='resolved' WHERE resolved_at IS NOT NULL AND status<>'resolved'`)
+	return err
+}
+
+// migrateMaintenanceWindows adds scheduled maintenance and the sample flag
+// recording that a check ran during it
+func migrateMaintenanceWindows(tx *Tx) error {
+	_, err := tx.Exec(ddl(tx.driver, `
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  title TEXT NOT NULL,
+  message TEXT,
+  services TEXT,
+  starts_at TEXT NOT NULL,
+  duration_mins INTEGER NOT NULL,
+  rrule TEXT,
+  cron TEXT
+);
+`))
+	if err != nil {
+		return err
+	}
+	return addColumn(tx, "samples", "maintenance", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// migrateSampleRollups adds the hourly and daily sample tiers and the
+// watermarks of how far each has been rolled up
+func migrateSampleRollups(tx *Tx) error {
+	_, err := tx.Exec(ddl(tx.driver, `
+CREATE TABLE IF NOT EXISTS samples_hourly (
+  service_key TEXT NOT NULL,
+  bucket TEXT NOT NULL,
+  up_count INTEGER NOT NULL,
+  total_count INTEGER NOT NULL,
+  maintenance_count INTEGER NOT NULL,
+  avg_ms REAL,
+  min_ms REAL,
+  max_ms REAL,
+  p95_ms REAL,
+  dns_ms REAL,
+  connect_ms REAL,
+  tls_ms REAL,
+  ttfb_ms REAL,
+  transfer_ms REAL,
+  PRIMARY KEY (service_key, bucket)
+);
+CREATE INDEX IF NOT EXISTS idx_samples_hourly_bucket ON samples_hourly(bucket);
+
+CREATE TABLE IF NOT EXISTS samples_daily (
+  service_key TEXT NOT NULL,
+  bucket TEXT NOT NULL,
+  up_count INTEGER NOT NULL,
+  total_count INTEGER NOT NULL,
+  maintenance_count INTEGER NOT NULL,
+  avg_ms REAL,
+  min_ms REAL,
+  max_ms REAL,
+  p95_ms REAL,
+  dns_ms REAL,
+  connect_ms REAL,
+  tls_ms REAL,
+  ttfb_ms REAL,
+  transfer_ms REAL,
+  PRIMARY KEY (service_key, bucket)
+);
+CREATE INDEX IF NOT EXISTS idx_samples_daily_bucket ON samples_daily(bucket);
+
+CREATE TABLE IF NOT EXISTS rollup_state (
+  tier TEXT PRIMARY KEY,
+  rolled_until TEXT NOT NULL
+);
+`))
+	return err
+}
+
+// migrateNotificationChannels adds the chat, push and webhook channels
+// alerts are sent to besides email
+func migrateNotificationChannels(tx *Tx) error {
+	_, err := tx.Exec(ddl(tx.driver, `
+CREATE TABLE IF NOT EXISTS notification_channels (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  type TEXT NOT NULL,
+  enabled INTEGER NOT NULL DEFAULT 1,
+  settings TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+`))
+	return err
+}
+
+// migrateNotificationOutbox adds the queue of notifications awaiting
+// delivery and the log of every delivery attempt
+func migrateNotificationOutbox(tx *Tx) error {
+	_, err := tx.Exec(ddl(tx.driver, `
+CREATE TABLE IF NOT EXISTS notification_outbox (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  channel_id INTEGER NOT NULL,
+  channel TEXT NOT NULL,
+  event TEXT NOT NULL,
+  service_key TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TEXT NOT NULL,
+  last_error TEXT,
+  created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_due ON notification_outbox(status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS notification_attempts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  outbox_id INTEGER NOT NULL,
+  attempt INTEGER NOT NULL,
+  status TEXT NOT NULL,
+  error TEXT,
+  attempted_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_notification_attempts_outbox ON notification_attempts(outbox_id);
+`))
+	return err
+}
+
+// migrateAlertRouting adds routing rules with their escalation steps, the
+// record of escalations already sent, the time each service entered its
+// current status and the email recipients of queued notifications
+func migrateAlertRouting(tx *Tx) error {
+	_, err := tx.Exec(ddl(tx.driver, `
+CREATE TABLE IF NOT EXISTS alert_rules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  enabled INTEGER NOT NULL DEFAULT 1,
+  services TEXT,
+  severities TEXT,
+  steps TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS alert_escalations (
+  service_key TEXT NOT NULL,
+  rule_id INTEGER NOT NULL,
+  step INTEGER NOT NULL,
+  since TEXT NOT NULL,
+  sent_at TEXT NOT NULL,
+  PRIMARY KEY (service_key, rule_id, step, since)
+);
+`))
+	if err != nil {
+		return err
+	}
+	if err := addColumn(tx, "service_status_history", "since", "TEXT"); err != nil {
+		return err
+	}
+	return addColumn(tx, "notification_outbox", "recipients", "TEXT")
+}
+
+// migrateIncidentAcknowledgement adds the reminder interval and who
+// acknowledged an incident and when its last reminder was sent
+func migrateIncidentAcknowledgement(tx *Tx) error {
+	if err := addColumn(tx, "alert_config", "reminder_minutes", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	for _, column := range []string{"acknowledged_at", "acknowledged_by", "reminded_at"} {
+		if err := addColumn(tx, "incidents", column, "TEXT"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateDirectAttempts lets the delivery log hold notifications sent
+// without going through the outbox, such as tests, and moves the tests
+// logged with a placeholder outbox entry onto their attempt
+func migrateDirectAttempts(tx *Tx) error {
+	for _, column := range []string{"channel", "event", "service_key"} {
+		if err := addColumn(tx, "notification_attempts", column, "TEXT"); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE notification_attempts SET
+		channel = (SELECT o.channel FROM notification_outbox o WHERE o.id = notification_attempts.outbox_id),
+		event = (SELECT o.event FROM notification_outbox o WHERE o.id = notification_attempts.outbox_id),
+		service_key = (SELECT o.service_key FROM notification_outbox o WHERE o.id = notification_attempts.outbox_id),
+		outbox_id = 0
+		WHERE outbox_id IN (SELECT id FROM notification_outbox WHERE payload = '{}')`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM notification_outbox WHERE payload = '{}'`)
+	return err
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"status/app/internal/models"
+	"time"
+)
+
+// ErrChannelNotFound is returned when a notification channel id does not exist
+var ErrChannelNotFound = errors.New("notification channel not found")
+
+// ListNotificationChannels returns all notification channels in the order
+// they were added
+func ListNotificationChannels() ([]*models.NotificationChannel, error) {
+	rows, err := DB.Query(`SELECT id, name, type, enabled, settings FROM notification_channels ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*models.NotificationChannel{}
+	for rows.Next() {
+		ch, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ch)
+	}
+	return out, rows.Err()
+}
+
+// GetNotificationChannel returns one notification channel
+func GetNotificationChannel(id int64) (*models.NotificationChannel, error) {
+	ch, err := scanChannel(DB.QueryRow(`SELECT id, name, type, enabled, settings FROM notification_channels WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChannelNotFound
+	}
+	return ch, err
+}
+
+// scanChannel reads a channel row selected as id, name, type, enabled, settings
+func scanChannel(row interface{ Scan(...any) error }) (*models.NotificationChannel, error) {
+	var ch models.NotificationChannel
+	var enabled int
+	var settings string
+	if err := row.Scan(&ch.ID, &ch.Name, &ch.Type, &enabled, &settings); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(settings), &ch.Settings); err != nil {
+		return nil, err
+	}
+	ch.Enabled = enabled == 1
+	return &ch, nil
+}
+
+// InsertNotificationChannel stores a new notification channel and returns its id
+func InsertNotificationChannel(ch *models.NotificationChannel) (int64, error) {
+	settings, err := json.Marshal(ch.Settings)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	var id int64
+	err = DB.QueryRow(`INSERT INTO notification_channels (name, type, enabled, settings, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING id`,
+		ch.Name, ch.Type, boolInt(ch.Enabled), string(settings), now, now).Scan(&id)
+	return id, err
+}
+
+// UpdateNotificationChannel replaces the definition of a notification channel
+func UpdateNotificationChannel(ch *models.NotificationChannel) error {
+	settings, err := json.Marshal(ch.Settings)
+	if err != nil {
+		return err
+	}
+	res, err := DB.Exec(`UPDATE notification_channels SET name = ?, type = ?, enabled = ?, settings = ?, updated_at = ?
+		WHERE id = ?`,
+		ch.Name, ch.Type, boolInt(ch.Enabled), string(settings), time.Now().UTC().Format(time.RFC3339), ch.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrChannelNotFound
+	}
+	return nil
+}
+
+// DeleteNotificationChannel removes a notification channel
+func DeleteNotificationChannel(id int64) error {
+	res, err := DB.Exec(`DELETE FROM notification_channels WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrChannelNotFound
+	}
+	return nil
+}
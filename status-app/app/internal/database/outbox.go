@@ -0,0 +1,149 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"status/app/internal/models"
+	"time"
+)
+
+// EnqueueNotification adds a notification to the outbox and returns its id
+func EnqueueNotification(e *models.OutboxEntry) (int64, error) {
+	now := time.Now().UTC()
+	if e.NextAttempt.IsZero() {
+		e.NextAttempt = now
+	}
+	var id int64
+	err := DB.QueryRow(`INSERT INTO notification_outbox (channel_id, channel, recipients, event, service_key, payload, status, attempts, next_attempt_at, last_error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		e.ChannelID, e.Channel, listJSON(e.Recipients), e.Event, e.ServiceKey, e.Payload, e.Status, e.Attempts,
+		e.NextAttempt.UTC().Format(time.RFC3339), nullString(e.LastError), now.Format(time.RFC3339)).Scan(&id)
+	e.ID = id
+	return id, err
+}
+
+// DueNotifications returns up to limit pending notifications whose next
+// attempt is due, oldest first
+func DueNotifications(now time.Time, limit int) ([]*models.OutboxEntry, error) {
+	rows, err := DB.Query(`SELECT id, channel_id, channel, recipients, event, service_key, payload, status, attempts, next_attempt_at, last_error, created_at
+		FROM notification_outbox WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at ASC, id ASC LIMIT ?`,
+		models.DeliveryPending, now.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*models.OutboxEntry
+	for rows.Next() {
+		var e models.OutboxEntry
+		var next, created string
+		var recipients, lastError sql.NullString
+		if err := rows.Scan(&e.ID, &e.ChannelID, &e.Channel, &recipients, &e.Event, &e.ServiceKey, &e.Payload, &e.Status, &e.Attempts,
+			&next, &lastError, &created); err != nil {
+			return nil, err
+		}
+		e.NextAttempt, _ = time.Parse(time.RFC3339, next)
+		e.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		if recipients.Valid && recipients.String != "" {
+			if err := json.Unmarshal([]byte(recipients.String), &e.Recipients); err != nil {
+				return nil, err
+			}
+		}
+		e.LastError = lastError.String
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
+
+// RecordNotificationAttempt logs a delivery attempt and saves the status,
+// attempt count and next attempt time of its outbox entry
+func RecordNotificationAttempt(e *models.OutboxEntry, a *models.NotificationAttempt) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`INSERT INTO notification_attempts (outbox_id, attempt, status, error, attempted_at) VALUES (?, ?, ?, ?, ?)`,
+		e.ID, a.Attempt, a.Status, nullString(a.Error), a.AttemptedAt.UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE notification_outbox SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		e.Status, e.Attempts, e.NextAttempt.UTC().Format(time.RFC3339), nullString(e.LastError), e.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordDirectAttempt logs a notification sent right away instead of
+// through the outbox, such as a test. It has no outbox entry, so it is
+// never retried.
+func RecordDirectAttempt(a *models.NotificationAttempt) error {
+	_, err := DB.Exec(`INSERT INTO notification_attempts (outbox_id, channel, event, service_key, attempt, status, error, attempted_at)
+		VALUES (0, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Channel, a.Event, a.ServiceKey, a.Attempt, a.Status, nullString(a.Error), a.AttemptedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// ListNotificationAttempts returns the most recent delivery attempts, newest
+// first, optionally only those with the given status
+func ListNotificationAttempts(status string, limit int) ([]*models.NotificationAttempt, error) {
+	query := `SELECT a.id, a.outbox_id, COALESCE(o.channel, a.channel), COALESCE(o.event, a.event), COALESCE(o.service_key, a.service_key),
+		a.attempt, a.status, a.error, a.attempted_at, COALESCE(o.status, a.status)
+		FROM notification_attempts a LEFT JOIN notification_outbox o ON o.id = a.outbox_id`
+	args := []any{}
+	if status != "" {
+		query += ` WHERE a.status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY a.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*models.NotificationAttempt{}
+	for rows.Next() {
+		var a models.NotificationAttempt
+		var attemptErr sql.NullString
+		var at string
+		if err := rows.Scan(&a.ID, &a.OutboxID, &a.Channel, &a.Event, &a.ServiceKey, &a.Attempt, &a.Status, &attemptErr, &at, &a.Delivery); err != nil {
+			return nil, err
+		}
+		a.Error = attemptErr.String
+		a.AttemptedAt, _ = time.Parse(time.RFC3339, at)
+		out = append(out, &a)
+	}
+	return out, rows.Err()
+}
+
+// PruneNotifications deletes delivered and abandoned notifications queued
+// before cutoff, with their attempts, and returns how many were deleted.
+// Notifications sent directly are deleted once attempted before cutoff.
+func PruneNotifications(cutoff time.Time) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ts := cutoff.UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(`DELETE FROM notification_attempts WHERE outbox_id IN
+		(SELECT id FROM notification_outbox WHERE status <> ? AND created_at < ?)`, models.DeliveryPending, ts); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`DELETE FROM notification_outbox WHERE status <> ? AND created_at < ?`, models.DeliveryPending, ts)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	res, err = tx.Exec(`DELETE FROM notification_attempts WHERE outbox_id = 0 AND attempted_at < ?`, ts)
+	if err != nil {
+		return 0, err
+	}
+	direct, _ := res.RowsAffected()
+	return n + direct, tx.Commit()
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"status/app/internal/models"
+	"testing"
+	"time"
+)
+
+func TestDirectAttemptsStayOutOfTheOutbox(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		now := time.Now().UTC().Truncate(time.Second)
+		queued := &models.OutboxEntry{ChannelID: 3, Channel: "ops", Event: "down", ServiceKey: "plex", Payload: `{"event":"down"}`, Status: models.DeliveryPending}
+		if _, err := EnqueueNotification(queued); err != nil {
+			t.Fatal(err)
+		}
+		queued.Attempts, queued.LastError, queued.NextAttempt = 1, "timeout", now.Add(-time.Second)
+		if err := RecordNotificationAttempt(queued, &models.NotificationAttempt{Attempt: 1, Status: models.DeliveryFailed, Error: "timeout", AttemptedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range []*models.NotificationAttempt{
+			{Channel: "email", Event: "test", Attempt: 1, Status: models.DeliverySent, AttemptedAt: now},
+			{Channel: "ops", Event: "test", ServiceKey: "test", Attempt: 1, Status: models.DeliveryFailed, Error: "bad token", AttemptedAt: now},
+		} {
+			if err := RecordDirectAttempt(a); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Only the queued notification is retried
+		due, err := DueNotifications(now, 10)
+		if err != nil || len(due) != 1 || due[0].ID != queued.ID {
+			t.Fatalf("DueNotifications = %+v, %v", due, err)
+		}
+
+		attempts, err := ListNotificationAttempts("", 10)
+		if err != nil || len(attempts) != 3 {
+			t.Fatalf("ListNotificationAttempts = %+v, %v", attempts, err)
+		}
+		if a := attempts[0]; a.OutboxID != 0 || a.Channel != "ops" || a.Event != "test" || a.ServiceKey != "test" ||
+			a.Status != models.DeliveryFailed || a.Delivery != models.DeliveryFailed || a.Error != "bad token" {
+			t.Errorf("failed test = %+v", a)
+		}
+		if a := attempts[1]; a.OutboxID != 0 || a.Channel != "email" || a.Delivery != models.DeliverySent {
+			t.Errorf("email test = %+v", a)
+		}
+		if a := attempts[2]; a.OutboxID != queued.ID || a.Channel != "ops" || a.Event != "down" || a.Delivery != models.DeliveryPending {
+			t.Errorf("queued attempt = %+v", a)
+		}
+		if failed, err := ListNotificationAttempts(models.DeliveryFailed, 10); err != nil || len(failed) != 2 {
+			t.Errorf("failed attempts = %+v, %v", failed, err)
+		}
+
+		// Old tests are pruned; pending notifications are kept
+		if n, err := PruneNotifications(now.Add(time.Minute)); err != nil || n != 2 {
+			t.Errorf("PruneNotifications = %d, %v, want 2", n, err)
+		}
+		if attempts, err := ListNotificationAttempts("", 10); err != nil || len(attempts) != 1 || attempts[0].OutboxID != queued.ID {
+			t.Errorf("attempts after pruning = %+v, %v", attempts, err)
+		}
+	})
+}
+
+func TestMigrateDirectAttempts(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		// A test logged before direct attempts existed, with its placeholder
+		// outbox entry
+		placeholder := &models.OutboxEntry{ChannelID: 3, Channel: "ops", Event: "test", ServiceKey: "test", Payload: "{}", Status: models.DeliverySent, Attempts: 1}
+		if _, err := EnqueueNotification(placeholder); err != nil {
+			t.Fatal(err)
+		}
+		at := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+		if err := RecordNotificationAttempt(placeholder, &models.NotificationAttempt{Attempt: 1, Status: models.DeliverySent, AttemptedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err := DB.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := migrateDirectAttempts(tx); err != nil {
+			_ = tx.Rollback()
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		var entries int
+		if err := DB.QueryRow(`SELECT COUNT(*) FROM notification_outbox`).Scan(&entries); err != nil || entries != 0 {
+			t.Errorf("outbox entries after migration = %d, %v", entries, err)
+		}
+		attempts, err := ListNotificationAttempts("", 10)
+		if err != nil || len(attempts) != 1 {
+			t.Fatalf("ListNotificationAttempts = %+v, %v", attempts, err)
+		}
+		if a := attempts[0]; a.OutboxID != 0 || a.Channel != "ops" || a.Event != "test" || a.ServiceKey != "test" ||
+			a.Delivery != models.DeliverySent || !a.AttemptedAt.Equal(at) {
+			t.Errorf("migrated attempt = %+v", a)
+		}
+	})
+}
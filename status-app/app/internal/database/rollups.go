@@ -0,0 +1,387 @@
+package database
+
+import (
+	"cmp"
+	"database/sql"
+	"math"
+	"slices"
+	"status/app/internal/models"
+	"time"
+)
+
+// Rollup tiers. Raw samples are rolled into hourly and daily buckets by
+// Compact; everything before a tier's watermark is read from its table and
+// anything later from the raw samples.
+const (
+	TierHourly = "hourly"
+	TierDaily  = "daily"
+)
+
+// rollupGrace keeps compaction away from the hour still being written to,
+// so a check finishing just after the hour is not missed
+const rollupGrace = time.Minute
+
+// Retention is how long each tier is kept; zero keeps it forever. Raw
+// samples are never pruned before they have been rolled up.
+type Retention struct {
+	Raw    time.Duration
+	Hourly time.Duration
+	Daily  time.Duration
+}
+
+// CompactStats reports what a compaction did
+type CompactStats struct {
+	HourlyBuckets int
+	DailyBuckets  int
+	RawPruned     int64
+	HourlyPruned  int64
+	DailyPruned   int64
+}
+
+func tierTable(tier string) string {
+	if tier == TierDaily {
+		return "samples_daily"
+	}
+	return "samples_hourly"
+}
+
+// truncate returns the start of the bucket containing t
+func truncate(tier string, t time.Time) time.Time {
+	t = t.UTC()
+	if tier == TierDaily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+func bucketName(tier string, t time.Time) string {
+	if tier == TierDaily {
+		return t.UTC().Format(time.DateOnly)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// rolledUntil returns the watermark of a tier, zero if it was never rolled up
+func rolledUntil(tier string) (time.Time, error) {
+	var ts string
+	err := DB.QueryRow(`SELECT rolled_until FROM rollup_state WHERE tier = ?`, tier).Scan(&ts)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, ts)
+}
+
+// bucketAcc accumulates the samples of one bucket
+type bucketAcc struct {
+	b         models.MetricBucket
+	latencies []float64
+	phases    models.LatencyPhases
+	phaseN    int
+}
+
+func (a *bucketAcc) finish() models.MetricBucket {
+	b := a.b
+	if n := len(a.latencies); n > 0 {
+		slices.Sort(a.latencies)
+		sum := 0.0
+		for _, v := range a.latencies {
+			sum += v
+		}
+		avg, lo, hi := sum/float64(n), a.latencies[0], a.latencies[n-1]
+		// Nearest-rank 95th percentile
+		p95 := a.latencies[int(math.Ceil(0.95*float64(n)))-1]
+		b.AvgMS, b.MinMS, b.MaxMS, b.P95MS = &avg, &lo, &hi, &p95
+	}
+	if a.phaseN > 0 {
+		n := float64(a.phaseN)
+		b.Phases = &models.LatencyPhases{
+			DNS:      a.phases.DNS / n,
+			Connect:  a.phases.Connect / n,
+			TLS:      a.phases.TLS / n,
+			TTFB:     a.phases.TTFB / n,
+			Transfer: a.phases.Transfer / n,
+		}
+	}
+	return b
+}
+
+// aggregateSamples rolls the raw samples taken in [from, to) into buckets
+// of the given tier, ordered by bucket and service. A zero to means no end.
+func aggregateSamples(tier string, from, to time.Time) ([]models.MetricBucket, error) {
+	query := `SELECT service_key, taken_at, ok, maintenance, latency_ms, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms
+		FROM samples WHERE taken_at >= ?`
+	args := []any{from.UTC().Format(time.RFC3339)}
+	if !to.IsZero() {
+		query += ` AND taken_at < ?`
+		args = append(args, to.UTC().Format(time.RFC3339))
+	}
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accs := map[[2]string]*bucketAcc{}
+	for rows.Next() {
+		var key, takenAt string
+		var ok, maint int
+		var latency sql.NullInt64
+		var dns, connect, tls, ttfb, transfer sql.NullFloat64
+		if err := rows.Scan(&key, &takenAt, &ok, &maint, &latency, &dns, &connect, &tls, &ttfb, &transfer); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, takenAt)
+		if err != nil {
+			continue
+		}
+		id := [2]string{bucketName(tier, truncate(tier, t)), key}
+		a := accs[id]
+		if a == nil {
+			a = &bucketAcc{b: models.MetricBucket{ServiceKey: key, Bucket: id[0]}}
+			accs[id] = a
+		}
+		if maint != 0 {
+			a.b.Maintenance++
+		} else {
+			a.b.Total++
+			a.b.Up += ok
+		}
+		if latency.Valid {
+			a.latencies = append(a.latencies, float64(latency.Int64))
+		}
+		if ttfb.Valid {
+			a.phases.DNS += dns.Float64
+			a.phases.Connect += connect.Float64
+			a.phases.TLS += tls.Float64
+			a.phases.TTFB += ttfb.Float64
+			a.phases.Transfer += transfer.Float64
+			a.phaseN++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([][2]string, 0, len(accs))
+	for id := range accs {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b [2]string) int {
+		if c := cmp.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a[1], b[1])
+	})
+	out := make([]models.MetricBucket, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, accs[id].finish())
+	}
+	return out, nil
+}
+
+// MetricBuckets returns the buckets of a tier from the one containing since
+// onwards, ordered by bucket. Rolled-up buckets come from the tier's table
+// and the buckets after its watermark are aggregated from raw samples.
+func MetricBuckets(tier string, since time.Time) ([]models.MetricBucket, error) {
+	from := truncate(tier, since)
+	until, err := rolledUntil(tier)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []models.MetricBucket{}
+	if until.After(from) {
+		// #nosec G201 -- the table name comes from a fixed set
+		rows, err := DB.Query(`SELECT service_key, bucket, up_count, total_count, maintenance_count, avg_ms, min_ms, max_ms, p95_ms,
+			dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms
+			FROM `+tierTable(tier)+` WHERE bucket >= ? AND bucket < ? ORDER BY bucket, service_key`,
+			bucketName(tier, from), bucketName(tier, until))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var b models.MetricBucket
+			var avg, lo, hi, p95, dns, connect, tls, ttfb, transfer sql.NullFloat64
+			if err := rows.Scan(&b.ServiceKey, &b.Bucket, &b.Up, &b.Total, &b.Maintenance, &avg, &lo, &hi, &p95,
+				&dns, &connect, &tls, &ttfb, &transfer); err != nil {
+				return nil, err
+			}
+			if avg.Valid {
+				b.AvgMS, b.MinMS, b.MaxMS, b.P95MS = &avg.Float64, &lo.Float64, &hi.Float64, &p95.Float64
+			}
+			if ttfb.Valid {
+				b.Phases = &models.LatencyPhases{DNS: dns.Float64, Connect: connect.Float64, TLS: tls.Float64, TTFB: ttfb.Float64, Transfer: transfer.Float64}
+			}
+			out = append(out, b)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		from = until
+	}
+
+	recent, err := aggregateSamples(tier, from, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return append(out, recent...), nil
+}
+
+// Compact rolls completed hours and days of raw samples into their tiers
+// and prunes each tier past its retention
+func Compact(now time.Time, ret Retention) (CompactStats, error) {
+	var stats CompactStats
+	end := now.Add(-rollupGrace)
+
+	var err error
+	if stats.HourlyBuckets, err = rollup(TierHourly, truncate(TierHourly, end)); err != nil {
+		return stats, err
+	}
+	if stats.DailyBuckets, err = rollup(TierDaily, truncate(TierDaily, end)); err != nil {
+		return stats, err
+	}
+
+	if ret.Raw > 0 {
+		// Only prune raw samples both tiers have rolled up
+		cutoff := now.Add(-ret.Raw)
+		for _, tier := range []string{TierHourly, TierDaily} {
+			until, err := rolledUntil(tier)
+			if err != nil {
+				return stats, err
+			}
+			if until.Before(cutoff) {
+				cutoff = until
+			}
+		}
+		res, err := DB.Exec(`DELETE FROM samples WHERE taken_at < ?`, cutoff.UTC().Format(time.RFC3339))
+		if err != nil {
+			return stats, err
+		}
+		stats.RawPruned, _ = res.RowsAffected()
+	}
+	for _, p := range []struct {
+		tier   string
+		keep   time.Duration
+		pruned *int64
+	}{{TierHourly, ret.Hourly, &stats.HourlyPruned}, {TierDaily, ret.Daily, &stats.DailyPruned}} {
+		if p.keep <= 0 {
+			continue
+		}
+		cutoff := bucketName(p.tier, truncate(p.tier, now.Add(-p.keep)))
+		// #nosec G201 -- the table name comes from a fixed set
+		res, err := DB.Exec(`DELETE FROM `+tierTable(p.tier)+` WHERE bucket < ?`, cutoff)
+		if err != nil {
+			return stats, err
+		}
+		*p.pruned, _ = res.RowsAffected()
+	}
+	return stats, nil
+}
+
+// rollup aggregates the raw samples between the tier's watermark and end,
+// a day at a time, and moves the watermark to end. It returns the number
+// of buckets written.
+func rollup(tier string, end time.Time) (int, error) {
+	from, err := rolledUntil(tier)
+	if err != nil {
+		return 0, err
+	}
+	if from.IsZero() {
+		var first sql.NullString
+		if err := DB.QueryRow(`SELECT MIN(taken_at) FROM samples`).Scan(&first); err != nil {
+			return 0, err
+		}
+		from = end
+		if t, err := time.Parse(time.RFC3339, first.String); first.Valid && err == nil {
+			from = truncate(tier, t)
+		}
+	}
+
+	written := 0
+	for from.Before(end) {
+		to := from.Add(24 * time.Hour)
+		if to.After(end) {
+			to = end
+		}
+		buckets, err := aggregateSamples(tier, from, to)
+		if err != nil {
+			return written, err
+		}
+		if err := saveBuckets(tier, buckets, to); err != nil {
+			return written, err
+		}
+		written += len(buckets)
+		from = to
+	}
+	return written, nil
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func setRolledUntil(db execer, tier string, t time.Time) error {
+	_, err := db.Exec(`INSERT INTO rollup_state (tier, rolled_until) VALUES (?, ?)
+		ON CONFLICT(tier) DO UPDATE SET rolled_until = excluded.rolled_until`, tier, t.UTC().Format(time.RFC3339))
+	return err
+}
+
+// saveBuckets stores rolled-up buckets and moves the watermark in one transaction
+func saveBuckets(tier string, buckets []models.MetricBucket, until time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, b := range buckets {
+		var dns, connect, tls, ttfb, transfer any
+		if p := b.Phases; p != nil {
+			dns, connect, tls, ttfb, transfer = p.DNS, p.Connect, p.TLS, p.TTFB, p.Transfer
+		}
+		// #nosec G201 -- the table name comes from a fixed set
+		if _, err := tx.Exec(`INSERT INTO `+tierTable(tier)+` (service_key, bucket, up_count, total_count, maintenance_count,
+			avg_ms, min_ms, max_ms, p95_ms, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(service_key, bucket) DO UPDATE SET up_count=excluded.up_count, total_count=excluded.total_count,
+				maintenance_count=excluded.maintenance_count, avg_ms=excluded.avg_ms, min_ms=excluded.min_ms, max_ms=excluded.max_ms,
+				p95_ms=excluded.p95_ms, dns_ms=excluded.dns_ms, connect_ms=excluded.connect_ms, tls_ms=excluded.tls_ms,
+				ttfb_ms=excluded.ttfb_ms, transfer_ms=excluded.transfer_ms`,
+			b.ServiceKey, b.Bucket, b.Up, b.Total, b.Maintenance, b.AvgMS, b.MinMS, b.MaxMS, b.P95MS,
+			dns, connect, tls, ttfb, transfer); err != nil {
+			return err
+		}
+	}
+	if err := setRolledUntil(tx, tier, until); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RewindRollups discards the rolled-up buckets from since onwards so they
+// are read from, and later rolled up again from, the raw samples. It is
+// used after raw samples were deleted.
+func RewindRollups(since time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, tier := range []string{TierHourly, TierDaily} {
+		from := truncate(tier, since)
+		// #nosec G201 -- the table name comes from a fixed set
+		if _, err := tx.Exec(`DELETE FROM `+tierTable(tier)+` WHERE bucket >= ?`, bucketName(tier, from)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE rollup_state SET rolled_until = ? WHERE tier = ? AND rolled_until > ?`,
+			from.Format(time.RFC3339), tier, from.Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
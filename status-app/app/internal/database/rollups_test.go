@@ -0,0 +1,267 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"status/app/internal/models"
+	"testing"
+	"time"
+)
+
+// forEachDB runs fn against a fresh SQLite database and, when
+// TEST_DATABASE_URL is set, PostgreSQL. The rollup functions use the
+// package database, so each one is opened in turn.
+func forEachDB(t *testing.T, fn func(t *testing.T, s Store)) {
+	t.Helper()
+	t.Run(DriverSQLite, func(t *testing.T) {
+		fn(t, openTestStore(t, DriverSQLite, filepath.Join(t.TempDir(), "test.db")))
+	})
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		t.Run(DriverPostgres, func(t *testing.T) {
+			fn(t, openTestStore(t, DriverPostgres, dsn))
+		})
+	}
+}
+
+func insertSamples(t *testing.T, s Store, samples ...models.Sample) {
+	t.Helper()
+	for _, smp := range samples {
+		if err := s.InsertSample(smp); err != nil {
+			t.Fatalf("InsertSample: %v", err)
+		}
+	}
+}
+
+func sample(key string, at time.Time, ok bool, ms int) models.Sample {
+	smp := models.Sample{TakenAt: at, ServiceKey: key, OK: ok, CheckStatus: "down"}
+	if ok {
+		smp.CheckStatus = "up"
+	}
+	if ms > 0 {
+		smp.MS = &ms
+	}
+	return smp
+}
+
+// bucketCounts maps each bucket of a service to its up, total and
+// maintenance counts
+func bucketCounts(t *testing.T, tier string, since time.Time, key string) map[string][3]int {
+	t.Helper()
+	buckets, err := MetricBuckets(tier, since)
+	if err != nil {
+		t.Fatalf("MetricBuckets(%s): %v", tier, err)
+	}
+	out := map[string][3]int{}
+	for _, b := range buckets {
+		if b.ServiceKey != key {
+			continue
+		}
+		if _, dup := out[b.Bucket]; dup {
+			t.Errorf("bucket %s of %s returned twice", b.Bucket, key)
+		}
+		out[b.Bucket] = [3]int{b.Up, b.Total, b.Maintenance}
+	}
+	return out
+}
+
+func compact(t *testing.T, now time.Time, ret Retention) CompactStats {
+	t.Helper()
+	stats, err := Compact(now, ret)
+	if err != nil {
+		t.Fatalf("Compact(%s): %v", now.Format(time.RFC3339), err)
+	}
+	return stats
+}
+
+func TestCompactHourly(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		at := func(h, m, sec int) time.Time { return time.Date(2026, 3, 10, h, m, sec, 0, time.UTC) }
+
+		// Hour 10: 20 checks with latencies 1..20 of which every fourth
+		// failed, plus one in maintenance
+		for i := range 20 {
+			insertSamples(t, s, sample("plex", at(10, i*3, 0), i%4 != 0, i+1))
+		}
+		maint := sample("plex", at(10, 59, 0), false, 0)
+		maint.Maintenance = true
+		insertSamples(t, s, maint)
+		// Either side of the 12:00 watermark
+		insertSamples(t, s,
+			sample("plex", at(11, 59, 59), true, 50),
+			sample("plex", at(12, 0, 0), true, 70),
+			sample("plex", at(12, 10, 0), false, 0),
+			sample("nas", at(11, 30, 0), true, 5),
+		)
+
+		stats := compact(t, at(12, 30, 0), Retention{})
+		if stats.HourlyBuckets != 3 || stats.DailyBuckets != 0 {
+			t.Errorf("stats = %+v, want 3 hourly and no daily buckets", stats)
+		}
+		want := map[string][3]int{
+			"2026-03-10T10:00:00Z": {15, 20, 1},
+			"2026-03-10T11:00:00Z": {1, 1, 0},
+			"2026-03-10T12:00:00Z": {1, 2, 0}, // still read from raw samples
+		}
+		check := func(when string) {
+			t.Helper()
+			got := bucketCounts(t, TierHourly, at(10, 0, 0), "plex")
+			if len(got) != len(want) {
+				t.Errorf("%s: buckets = %v, want %v", when, got, want)
+			}
+			for bucket, counts := range want {
+				if got[bucket] != counts {
+					t.Errorf("%s: %s up/total/maintenance = %v, want %v", when, bucket, got[bucket], counts)
+				}
+			}
+		}
+		check("first compaction")
+
+		buckets, err := MetricBuckets(TierHourly, at(10, 0, 0))
+		if err != nil || len(buckets) == 0 {
+			t.Fatalf("MetricBuckets = %v, %v", buckets, err)
+		}
+		b := buckets[0]
+		if b.Bucket != "2026-03-10T10:00:00Z" || b.AvgMS == nil || *b.AvgMS != 10.5 || *b.MinMS != 1 || *b.MaxMS != 20 || *b.P95MS != 19 {
+			t.Errorf("hour 10 latency = %+v", b)
+		}
+
+		// Compacting again, or once the next hour is over, counts nothing twice
+		if stats := compact(t, at(12, 45, 0), Retention{}); stats.HourlyBuckets != 0 {
+			t.Errorf("second compaction wrote %d buckets", stats.HourlyBuckets)
+		}
+		check("second compaction")
+		if stats := compact(t, at(13, 30, 0), Retention{}); stats.HourlyBuckets != 1 {
+			t.Errorf("compaction after 13:00 wrote %d buckets, want 1", stats.HourlyBuckets)
+		}
+		check("compaction after 13:00")
+
+		// Within the grace period the hour is left to the raw samples
+		if stats := compact(t, at(14, 0, 30), Retention{}); stats.HourlyBuckets != 0 {
+			t.Errorf("compaction within the grace period wrote %d buckets", stats.HourlyBuckets)
+		}
+		if until, err := rolledUntil(TierHourly); err != nil || !until.Equal(at(13, 0, 0)) {
+			t.Errorf("hourly watermark = %v, %v, want 13:00", until, err)
+		}
+	})
+}
+
+func TestCompactDaily(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+		insertSamples(t, s,
+			sample("plex", day, true, 10),
+			sample("plex", day.Add(12*time.Hour), false, 0),
+			sample("plex", day.Add(24*time.Hour-time.Second), true, 30),
+			sample("plex", day.Add(24*time.Hour), true, 40),
+		)
+
+		stats := compact(t, day.Add(24*time.Hour+30*time.Minute), Retention{})
+		if stats.DailyBuckets != 1 {
+			t.Errorf("daily buckets = %d, want 1", stats.DailyBuckets)
+		}
+		want := map[string][3]int{"2026-03-10": {2, 3, 0}, "2026-03-11": {1, 1, 0}}
+		for range 2 {
+			got := bucketCounts(t, TierDaily, day, "plex")
+			if len(got) != 2 || got["2026-03-10"] != want["2026-03-10"] || got["2026-03-11"] != want["2026-03-11"] {
+				t.Errorf("daily buckets = %v, want %v", got, want)
+			}
+			compact(t, day.Add(24*time.Hour+45*time.Minute), Retention{})
+		}
+
+		// The hourly tier agrees with the daily one
+		total := 0
+		for _, counts := range bucketCounts(t, TierHourly, day, "plex") {
+			total += counts[1]
+		}
+		if total != 4 {
+			t.Errorf("hourly total = %d, want 4", total)
+		}
+	})
+}
+
+func TestCompactRetention(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		old := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+		now := time.Date(2026, 3, 20, 0, 30, 0, 0, time.UTC)
+		insertSamples(t, s,
+			sample("plex", old, true, 10),
+			sample("plex", now.Add(-time.Hour), true, 20),
+		)
+
+		stats := compact(t, now, Retention{Raw: 14 * 24 * time.Hour, Hourly: 7 * 24 * time.Hour})
+		if stats.RawPruned != 1 || stats.HourlyPruned != 1 || stats.DailyPruned != 0 {
+			t.Errorf("stats = %+v, want one raw sample and one hourly bucket pruned", stats)
+		}
+		if got := bucketCounts(t, TierHourly, old, "plex"); len(got) != 1 || got["2026-03-19T23:00:00Z"] != [3]int{1, 1, 0} {
+			t.Errorf("hourly buckets = %v", got)
+		}
+		// The daily tier keeps what the raw samples and hourly tier no longer have
+		if got := bucketCounts(t, TierDaily, old, "plex"); got["2026-03-01"] != [3]int{1, 1, 0} || got["2026-03-19"] != [3]int{1, 1, 0} {
+			t.Errorf("daily buckets = %v", got)
+		}
+	})
+}
+
+func TestCompactKeepsRawSamplesUntilRolledUp(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		insertSamples(t, s,
+			sample("plex", day.Add(10*time.Hour), true, 10),
+			sample("plex", day.Add(11*time.Hour), true, 10),
+		)
+
+		// Both hours are rolled up, but the day is not over, so the
+		// samples outlive the raw retention
+		stats := compact(t, day.Add(13*time.Hour), Retention{Raw: time.Hour})
+		if stats.RawPruned != 0 {
+			t.Errorf("pruned %d raw samples before the daily rollup", stats.RawPruned)
+		}
+		if got := bucketCounts(t, TierDaily, day, "plex"); got["2026-03-01"] != [3]int{2, 2, 0} {
+			t.Errorf("daily buckets = %v", got)
+		}
+		stats = compact(t, day.Add(24*time.Hour+time.Hour), Retention{Raw: time.Hour})
+		if stats.RawPruned != 2 {
+			t.Errorf("pruned %d raw samples after the daily rollup, want 2", stats.RawPruned)
+		}
+		if got := bucketCounts(t, TierDaily, day, "plex"); got["2026-03-01"] != [3]int{2, 2, 0} {
+			t.Errorf("daily buckets after pruning = %v", got)
+		}
+	})
+}
+
+func TestRewindRollups(t *testing.T) {
+	forEachDB(t, func(t *testing.T, s Store) {
+		day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+		insertSamples(t, s,
+			sample("plex", day.Add(10*time.Hour), false, 0),
+			sample("plex", day.Add(11*time.Hour), false, 0),
+			sample("plex", day.Add(11*time.Hour+time.Minute), true, 10),
+		)
+		compact(t, day.Add(24*time.Hour+30*time.Minute), Retention{})
+
+		since := day.Add(11 * time.Hour)
+		if err := s.DeleteFailedSamples(since); err != nil {
+			t.Fatal(err)
+		}
+		if err := RewindRollups(since); err != nil {
+			t.Fatal(err)
+		}
+		for tier, want := range map[string]time.Time{TierHourly: since, TierDaily: day} {
+			if until, err := rolledUntil(tier); err != nil || !until.Equal(want) {
+				t.Errorf("%s watermark = %v, %v, want %v", tier, until, err, want)
+			}
+		}
+		wantHourly := map[string][3]int{"2026-03-10T10:00:00Z": {0, 1, 0}, "2026-03-10T11:00:00Z": {1, 1, 0}}
+		for _, when := range []string{"after rewind", "after compaction"} {
+			got := bucketCounts(t, TierHourly, day, "plex")
+			if len(got) != 2 || got["2026-03-10T10:00:00Z"] != wantHourly["2026-03-10T10:00:00Z"] ||
+				got["2026-03-10T11:00:00Z"] != wantHourly["2026-03-10T11:00:00Z"] {
+				t.Errorf("%s: hourly buckets = %v, want %v", when, got, wantHourly)
+			}
+			if got := bucketCounts(t, TierDaily, day, "plex"); got["2026-03-10"] != [3]int{1, 2, 0} {
+				t.Errorf("%s: daily buckets = %v", when, got)
+			}
+			compact(t, day.Add(24*time.Hour+30*time.Minute), Retention{})
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"status/app/internal/models"
+	"time"
+)
+
+// ErrRuleNotFound is returned when an alert rule id does not exist
+var ErrRuleNotFound = errors.New("alert rule not found")
+
+// ListAlertRules returns all alert rules in the order they were added
+func ListAlertRules() ([]*models.AlertRule, error) {
+	rows, err := DB.Query(`SELECT id, name, enabled, services, severities, steps FROM alert_rules ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*models.AlertRule{}
+	for rows.Next() {
+		var r models.AlertRule
+		var enabled int
+		var services, severities sql.NullString
+		var steps string
+		if err := rows.Scan(&r.ID, &r.Name, &enabled, &services, &severities, &steps); err != nil {
+			return nil, err
+		}
+		r.Enabled = enabled == 1
+		r.Services, r.Severities = []string{}, []string{}
+		for _, f := range []struct {
+			col sql.NullString
+			dst *[]string
+		}{{services, &r.Services}, {severities, &r.Severities}} {
+			if f.col.Valid && f.col.String != "" {
+				if err := json.Unmarshal([]byte(f.col.String), f.dst); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := json.Unmarshal([]byte(steps), &r.Steps); err != nil {
+			return nil, err
+		}
+		out = append(out, &r)
+	}
+	return out, rows.Err()
+}
+
+// InsertAlertRule stores a new alert rule and returns its id
+func InsertAlertRule(r *models.AlertRule) (int64, error) {
+	steps, err := json.Marshal(r.Steps)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	var id int64
+	err = DB.QueryRow(`INSERT INTO alert_rules (name, enabled, services, severities, steps, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		r.Name, boolInt(r.Enabled), listJSON(r.Services), listJSON(r.Severities), string(steps), now, now).Scan(&id)
+	return id, err
+}
+
+// UpdateAlertRule replaces the definition of an alert rule
+func UpdateAlertRule(r *models.AlertRule) error {
+	steps, err := json.Marshal(r.Steps)
+	if err != nil {
+		return err
+	}
+	res, err := DB.Exec(`UPDATE alert_rules SET name = ?, enabled = ?, services = ?, severities = ?, steps = ?, updated_at = ?
+		WHERE id = ?`,
+		r.Name, boolInt(r.Enabled), listJSON(r.Services), listJSON(r.Severities), string(steps), time.Now().UTC().Format(time.RFC3339), r.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+// DeleteAlertRule removes an alert rule and its escalation record
+func DeleteAlertRule(id int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(`DELETE FROM alert_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRuleNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM alert_escalations WHERE rule_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClaimEscalation records that a step of a rule was sent for the status a
+// service entered at since. It reports false if the step was already sent.
+func ClaimEscalation(key string, ruleID int64, step int, since time.Time) (bool, error) {
+	res, err := DB.Exec(`INSERT INTO alert_escalations (service_key, rule_id, step, since, sent_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		key, ruleID, step, since.UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ClearEscalations forgets the escalations sent for a service that recovered
+func ClearEscalations(key string) error {
+	_, err := DB.Exec(`DELETE FROM alert_escalations WHERE service_key = ?`, key)
+	return err
+}
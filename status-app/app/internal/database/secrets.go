@@ -0,0 +1,143 @@
+package database
+
+import (
+	"net/url"
+	"status/app/internal/models"
+	"strings"
+)
+
+// Secret names in the service_secrets table. Secret headers are stored as
+// "header:<Name>".
+const (
+	secretPassword     = "password"
+	secretToken        = "token"
+	secretHeaderPrefix = "header:"
+)
+
+// urlTokenParams are query parameters that carry credentials and are moved
+// out of stored URLs into secret headers of the same name
+var urlTokenParams = []string{"X-Plex-Token"}
+
+// loadServiceSecrets returns the secrets of every service keyed by service key
+func loadServiceSecrets() (map[string]models.ServiceSecrets, error) {
+	rows, err := DB.Query(`SELECT service_key, name, value FROM service_secrets`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]models.ServiceSecrets{}
+	for rows.Next() {
+		var key, name, value string
+		if err := rows.Scan(&key, &name, &value); err != nil {
+			return nil, err
+		}
+		sec := out[key]
+		switch {
+		case name == secretPassword:
+			sec.Password = value
+		case name == secretToken:
+			sec.Token = value
+		case strings.HasPrefix(name, secretHeaderPrefix):
+			if sec.Headers == nil {
+				sec.Headers = map[string]string{}
+			}
+			sec.Headers[strings.TrimPrefix(name, secretHeaderPrefix)] = value
+		}
+		out[key] = sec
+	}
+	return out, rows.Err()
+}
+
+// saveServiceSecrets replaces the stored secrets of a service
+func saveServiceSecrets(tx *Tx, key string, sec models.ServiceSecrets) error {
+	if _, err := tx.Exec(`DELETE FROM service_secrets WHERE service_key=?`, key); err != nil {
+		return err
+	}
+	put := func(name, value string) error {
+		if value == "" {
+			return nil
+		}
+		_, err := tx.Exec(`INSERT INTO service_secrets (service_key, name, value) VALUES (?, ?, ?)`, key, name, value)
+		return err
+	}
+	if err := put(secretPassword, sec.Password); err != nil {
+		return err
+	}
+	if err := put(secretToken, sec.Token); err != nil {
+		return err
+	}
+	for name, value := range sec.Headers {
+		if err := put(secretHeaderPrefix+name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveURLTokensToSecrets strips credentials such as X-Plex-Token from stored
+// service URLs and keeps them as secret headers instead, so they no longer
+// show up in the admin API or in logged request errors
+func moveURLTokensToSecrets() error {
+	rows, err := DB.Query(`SELECT service_key, url FROM services`)
+	if err != nil {
+		return err
+	}
+	type move struct {
+		key, url string
+		headers  map[string]string
+	}
+	var moves []move
+	for rows.Next() {
+		var key, raw string
+		if err := rows.Scan(&key, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		q := u.Query()
+		m := move{key: key, headers: map[string]string{}}
+		for _, param := range urlTokenParams {
+			if v := q.Get(param); v != "" {
+				m.headers[param] = v
+				q.Del(param)
+			}
+		}
+		if len(m.headers) == 0 {
+			continue
+		}
+		u.RawQuery = q.Encode()
+		m.url = u.String()
+		moves = append(moves, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range moves {
+		tx, err := DB.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE services SET url=? WHERE service_key=?`, m.url, m.key); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		for name, value := range m.headers {
+			if _, err := tx.Exec(`INSERT INTO service_secrets (service_key, name, value) VALUES (?, ?, ?)
+				ON CONFLICT(service_key, name) DO UPDATE SET value=excluded.value`,
+				m.key, secretHeaderPrefix+name, value); err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
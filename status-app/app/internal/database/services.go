@@ -0,0 +1,257 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"status/app/internal/models"
+	"time"
+)
+
+// ErrServiceNotFound is returned when a service key does not exist
+var ErrServiceNotFound = errors.New("service not found")
+
+// ErrInvalidOrder is returned when a reorder request does not list every service exactly once
+var ErrInvalidOrder = errors.New("reorder must list every service exactly once")
+
+// ListServices returns all configured services in display order,
+// including their persisted disabled state
+func ListServices() ([]*models.Service, error) {
+	rows, err := DB.Query(`
+		SELECT s.service_key, s.label, s.url, s.timeout_secs, s.interval_secs, s.jitter_secs, s.retries, s.retry_delay_secs, s.min_ok, s.max_ok, s.sort_order, s.source, s.assertions,
+			s.http_options, s.status_policy, COALESCE(st.disabled, 0)
+		FROM services s
+		LEFT JOIN service_state st ON st.service_key = s.service_key
+		ORDER BY s.sort_order ASC, s.service_key ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := []*models.Service{}
+	for rows.Next() {
+		var s models.Service
+		var timeoutSecs, intervalSecs, jitterSecs, retryDelaySecs, disabled int
+		var assertions, httpOpts, policy sql.NullString
+		if err := rows.Scan(&s.Key, &s.Label, &s.URL, &timeoutSecs, &intervalSecs, &jitterSecs, &s.Retries, &retryDelaySecs, &s.MinOK, &s.MaxOK, &s.SortOrder, &s.Source, &assertions, &httpOpts, &policy, &disabled); err != nil {
+			return nil, err
+		}
+		if assertions.Valid && assertions.String != "" {
+			if err := json.Unmarshal([]byte(assertions.String), &s.Assertions); err != nil {
+				return nil, err
+			}
+		}
+		if httpOpts.Valid && httpOpts.String != "" {
+			if err := json.Unmarshal([]byte(httpOpts.String), &s.HTTP); err != nil {
+				return nil, err
+			}
+		}
+		if policy.Valid && policy.String != "" {
+			if err := json.Unmarshal([]byte(policy.String), &s.Policy); err != nil {
+				return nil, err
+			}
+		}
+		s.Timeout = time.Duration(timeoutSecs) * time.Second
+		s.Interval = time.Duration(intervalSecs) * time.Second
+		s.Jitter = time.Duration(jitterSecs) * time.Second
+		s.RetryDelay = time.Duration(retryDelaySecs) * time.Second
+		s.Disabled = disabled != 0
+		services = append(services, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	secrets, err := loadServiceSecrets()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range services {
+		s.Secrets = secrets[s.Key]
+	}
+	return services, nil
+}
+
+// CountServices returns the number of configured services
+func CountServices() (int, error) {
+	var n int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM services`).Scan(&n)
+	return n, err
+}
+
+// SeedServices inserts the given services if the services table is empty.
+// It is used to migrate the env-based service list into the database on first run.
+func SeedServices(services []*models.Service) error {
+	n, err := CountServices()
+	if err != nil || n > 0 {
+		return err
+	}
+	for i, s := range services {
+		s.SortOrder = i
+		if err := InsertService(s); err != nil {
+			return err
+		}
+	}
+	return moveURLTokensToSecrets()
+}
+
+// InsertService creates a new service definition along with its secrets
+func InsertService(s *models.Service) error {
+	if s.Source == "" {
+		s.Source = models.ServiceSourceAPI
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = tx.Exec(`INSERT INTO services (service_key, label, url, timeout_secs, interval_secs, jitter_secs, retries, retry_delay_secs, min_ok, max_ok, sort_order, source, assertions, http_options, status_policy, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Key, s.Label, s.URL, int(s.Timeout.Seconds()), int(s.Interval.Seconds()), int(s.Jitter.Seconds()), s.Retries, int(s.RetryDelay.Seconds()), s.MinOK, s.MaxOK, s.SortOrder, s.Source,
+		assertionsJSON(s.Assertions), httpOptionsJSON(s.HTTP), policyJSON(s.Policy), now, now)
+	if err != nil {
+		return err
+	}
+	if err := saveServiceSecrets(tx, s.Key, s.Secrets); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateService updates an existing service definition and replaces its
+// secrets (the sort order is left untouched)
+func UpdateService(s *models.Service) error {
+	if s.Source == "" {
+		s.Source = models.ServiceSourceAPI
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(`UPDATE services SET label=?, url=?, timeout_secs=?, interval_secs=?, jitter_secs=?, retries=?, retry_delay_secs=?, min_ok=?, max_ok=?, source=?, assertions=?, http_options=?, status_policy=?, updated_at=?
+		WHERE service_key=?`,
+		s.Label, s.URL, int(s.Timeout.Seconds()), int(s.Interval.Seconds()), int(s.Jitter.Seconds()), s.Retries, int(s.RetryDelay.Seconds()), s.MinOK, s.MaxOK, s.Source, assertionsJSON(s.Assertions), httpOptionsJSON(s.HTTP), policyJSON(s.Policy),
+		time.Now().UTC().Format(time.RFC3339), s.Key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrServiceNotFound
+	}
+	if err := saveServiceSecrets(tx, s.Key, s.Secrets); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteService removes a service definition and its runtime state.
+// Historical samples are kept so uptime history survives re-adding the service.
+func DeleteService(key string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(`DELETE FROM services WHERE service_key=?`, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrServiceNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM service_state WHERE service_key=?`, key); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM service_status_history WHERE service_key=?`, key); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM service_secrets WHERE service_key=?`, key); err != nil {
+		return err
+	}
+	// Keep the incident history, but nothing will resolve an open incident anymore
+	if _, err := tx.Exec(`UPDATE incidents SET resolved_at=?, status=? WHERE service_key=? AND source=? AND resolved_at IS NULL`,
+		time.Now().UTC().Format(time.RFC3339), models.IncidentResolved, key, incidentAuto); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReorderServices sets the display order to match the given key order.
+// Every existing service must be listed exactly once.
+func ReorderServices(keys []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var total int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM services`).Scan(&total); err != nil {
+		return err
+	}
+	if total != len(keys) {
+		return ErrInvalidOrder
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	seen := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		if seen[key] {
+			return ErrInvalidOrder
+		}
+		seen[key] = true
+		res, err := tx.Exec(`UPDATE services SET sort_order=?, updated_at=? WHERE service_key=?`, i, now, key)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrServiceNotFound
+		}
+	}
+	return tx.Commit()
+}
+
+// NextServiceSortOrder returns the sort order for a newly appended service
+func NextServiceSortOrder() (int, error) {
+	var n int
+	err := DB.QueryRow(`SELECT COALESCE(MAX(sort_order) + 1, 0) FROM services`).Scan(&n)
+	return n, err
+}
+
+// assertionsJSON encodes assertions for storage; no assertions are stored as NULL
+func assertionsJSON(list []models.Assertion) any {
+	if len(list) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+// httpOptionsJSON encodes HTTP request options for storage; defaults are stored as NULL
+func httpOptionsJSON(o models.HTTPOptions) any {
+	b, err := json.Marshal(o)
+	if err != nil || string(b) == "{}" {
+		return nil
+	}
+	return string(b)
+}
+
+// policyJSON encodes a status policy for storage; the default policy is stored as NULL
+func policyJSON(p models.StatusPolicy) any {
+	if p == (models.StatusPolicy{}) {
+		return nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
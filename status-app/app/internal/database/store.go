@@ -0,0 +1,471 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"status/app/internal/models"
+	"time"
+)
+
+// Store is the storage backend for the data read and written on every
+// check and request: samples, alert and resources settings, the last
+// status of each service, disabled services, IP blocks and status alerts.
+// The package-level functions of the same names use the store opened by
+// Open.
+//
+// Services, incidents, maintenance windows, rollups, notification
+// channels, the outbox and alert rules are not part of Store; their
+// functions query DB directly. They use the same portable SQL, so they run
+// on both drivers, but a backend other than SQL would have to replace them.
+type Store interface {
+	InsertSample(smp models.Sample) error
+	DeleteFailedSamples(since time.Time) error
+	RecentFailures(since time.Time, limit int) ([]models.FailedSample, error)
+
+	LoadAlertConfig() (*models.AlertConfig, error)
+	SaveAlertConfig(config *models.AlertConfig) error
+	LoadResourcesUIConfig() (*models.ResourcesUIConfig, error)
+	SaveResourcesUIConfig(config *models.ResourcesUIConfig) error
+
+	LoadStatusHistory(key string) (*models.StatusHistory, error)
+	SaveStatusHistory(key string, h models.StatusHistory) error
+	GetServiceDisabledState(key string) (bool, error)
+	SetServiceDisabledState(key string, disabled bool) error
+
+	GetIPBlock(ip string) (*models.IPBlock, error)
+	RecordFailedLogin(ip string, maxAttempts int, ttl time.Duration) error
+	DeleteIPBlock(ip string) error
+	DeleteAllIPBlocks() (int64, error)
+	ListIPBlocks() ([]models.IPBlock, error)
+
+	ListStatusAlerts() ([]models.StatusAlert, error)
+	InsertStatusAlert(a *models.StatusAlert) error
+	DeleteStatusAlert(id string) error
+}
+
+// sqlStore implements Store with SQL that runs unchanged on SQLite and
+// PostgreSQL; Conn takes care of the placeholder style
+type sqlStore struct {
+	db *Conn
+}
+
+// NewSQLStore returns a Store backed by a SQLite or PostgreSQL connection
+func NewSQLStore(db *Conn) Store {
+	return &sqlStore{db: db}
+}
+
+// dbTime formats t the way the settings and IP block tables store times.
+// These tables were written with SQLite's datetime('now') before the SQL
+// was made portable, and their values are compared as strings.
+func dbTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// InsertSample records a service check sample along with the probe
+// message, metadata and HTTP latency phases, if any
+func (s *sqlStore) InsertSample(smp models.Sample) error {
+	var msVal, msgVal, metaVal, attemptErrsVal any
+	var dnsVal, connectVal, tlsVal, ttfbVal, transferVal any
+	if smp.MS != nil {
+		msVal = *smp.MS
+	}
+	if smp.Message != "" {
+		msgVal = smp.Message
+	}
+	if len(smp.Meta) > 0 {
+		if b, err := json.Marshal(smp.Meta); err == nil {
+			metaVal = string(b)
+		}
+	}
+	if p := smp.Phases; p != nil {
+		dnsVal, connectVal, tlsVal, ttfbVal, transferVal = p.DNS, p.Connect, p.TLS, p.TTFB, p.Transfer
+	}
+	if len(smp.AttemptErrors) > 0 {
+		if b, err := json.Marshal(smp.AttemptErrors); err == nil {
+			attemptErrsVal = string(b)
+		}
+	}
+	attempts := max(smp.Attempts, 1)
+
+	_, err := s.db.Exec(`INSERT INTO samples (taken_at,service_key,ok,http_status,latency_ms,error,check_status,meta,
+		dns_ms,connect_ms,tls_ms,ttfb_ms,transfer_ms,attempts,attempt_errors,maintenance)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		smp.TakenAt.UTC().Format(time.RFC3339), smp.ServiceKey, boolInt(smp.OK), smp.HTTPStatus, msVal, msgVal, smp.CheckStatus, metaVal,
+		dnsVal, connectVal, tlsVal, ttfbVal, transferVal, attempts, attemptErrsVal, boolInt(smp.Maintenance))
+	return err
+}
+
+// DeleteFailedSamples removes the failed samples taken since the given time
+func (s *sqlStore) DeleteFailedSamples(since time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM samples WHERE ok=0 AND taken_at >= ?`, since.UTC().Format(time.RFC3339))
+	return err
+}
+
+// RecentFailures returns the latest failed samples taken outside
+// maintenance since the given time, newest first
+func (s *sqlStore) RecentFailures(since time.Time, limit int) ([]models.FailedSample, error) {
+	rows, err := s.db.Query(`SELECT taken_at, service_key, http_status
+		FROM samples
+		WHERE ok=0 AND maintenance=0 AND taken_at >= ?
+		ORDER BY taken_at DESC LIMIT ?`, since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.FailedSample{}
+	for rows.Next() {
+		var f models.FailedSample
+		var st sql.NullInt64
+		if err := rows.Scan(&f.TakenAt, &f.ServiceKey, &st); err != nil {
+			return nil, err
+		}
+		f.HTTPStatus = int(st.Int64)
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// LoadAlertConfig loads email alert configuration from database
+func (s *sqlStore) LoadAlertConfig() (*models.AlertConfig, error) {
+	var config models.AlertConfig
+	err := s.db.QueryRow(`SELECT enabled, smtp_host, smtp_port, smtp_user, smtp_password, alert_email, from_email, alert_on_down, alert_on_degraded, alert_on_up, reminder_minutes
+		FROM alert_config WHERE id = 1`).Scan(
+		&config.Enabled, &config.SMTPHost, &config.SMTPPort, &config.SMTPUser,
+		&config.SMTPPassword, &config.AlertEmail, &config.FromEmail,
+		&config.AlertOnDown, &config.AlertOnDegraded, &config.AlertOnUp, &config.ReminderMinutes)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// SaveAlertConfig saves email alert configuration to database
+func (s *sqlStore) SaveAlertConfig(config *models.AlertConfig) error {
+	_, err := s.db.Exec(`INSERT INTO alert_config (id, enabled, smtp_host, smtp_port, smtp_user, smtp_password, alert_email, from_email, alert_on_down, alert_on_degraded, alert_on_up, reminder_minutes, updated_at)
+		VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			enabled=excluded.enabled, smtp_host=excluded.smtp_host, smtp_port=excluded.smtp_port, smtp_user=excluded.smtp_user,
+			smtp_password=excluded.smtp_password, alert_email=excluded.alert_email, from_email=excluded.from_email,
+			alert_on_down=excluded.alert_on_down, alert_on_degraded=excluded.alert_on_degraded, alert_on_up=excluded.alert_on_up,
+			reminder_minutes=excluded.reminder_minutes, updated_at=excluded.updated_at`,
+		boolInt(config.Enabled), config.SMTPHost, config.SMTPPort, config.SMTPUser, config.SMTPPassword,
+		config.AlertEmail, config.FromEmail, boolInt(config.AlertOnDown), boolInt(config.AlertOnDegraded), boolInt(config.AlertOnUp),
+		config.ReminderMinutes, dbTime(time.Now()))
+	return err
+}
+
+// LoadResourcesUIConfig loads resources UI configuration from database
+func (s *sqlStore) LoadResourcesUIConfig() (*models.ResourcesUIConfig, error) {
+	var config models.ResourcesUIConfig
+	err := s.db.QueryRow(`SELECT enabled, cpu, memory, network, temp, storage
+		FROM resources_ui_config WHERE id = 1`).Scan(
+		&config.Enabled, &config.CPU, &config.Memory, &config.Network, &config.Temp, &config.Storage,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// SaveResourcesUIConfig saves resources UI configuration to database
+func (s *sqlStore) SaveResourcesUIConfig(config *models.ResourcesUIConfig) error {
+	_, err := s.db.Exec(`INSERT INTO resources_ui_config (id, enabled, cpu, memory, network, temp, storage, updated_at)
+		VALUES (1, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			enabled=excluded.enabled, cpu=excluded.cpu, memory=excluded.memory, network=excluded.network,
+			temp=excluded.temp, storage=excluded.storage, updated_at=excluded.updated_at`,
+		boolInt(config.Enabled), boolInt(config.CPU), boolInt(config.Memory), boolInt(config.Network),
+		boolInt(config.Temp), boolInt(config.Storage), dbTime(time.Now()),
+	)
+	return err
+}
+
+// LoadStatusHistory returns the last recorded status of a service, or nil
+// if none was recorded yet
+func (s *sqlStore) LoadStatusHistory(key string) (*models.StatusHistory, error) {
+	var ok, degraded int
+	var since sql.NullString
+	err := s.db.QueryRow(`SELECT ok, degraded, since FROM service_status_history WHERE service_key = ?`, key).Scan(&ok, &degraded, &since)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := &models.StatusHistory{OK: ok == 1, Degraded: degraded == 1}
+	if since.Valid {
+		h.Since, _ = time.Parse(time.RFC3339, since.String)
+	}
+	return h, nil
+}
+
+// SaveStatusHistory records the current status of a service
+func (s *sqlStore) SaveStatusHistory(key string, h models.StatusHistory) error {
+	var since any
+	if !h.Since.IsZero() {
+		since = h.Since.UTC().Format(time.RFC3339)
+	}
+	_, err := s.db.Exec(`INSERT INTO service_status_history (service_key, ok, degraded, since, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(service_key) DO UPDATE SET ok=excluded.ok, degraded=excluded.degraded, since=excluded.since, updated_at=excluded.updated_at`,
+		key, boolInt(h.OK), boolInt(h.Degraded), since, dbTime(time.Now()))
+	return err
+}
+
+// GetServiceDisabledState loads service disabled state from database
+func (s *sqlStore) GetServiceDisabledState(key string) (bool, error) {
+	var disabled int
+	err := s.db.QueryRow(`SELECT disabled FROM service_state WHERE service_key = ?`, key).Scan(&disabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return disabled != 0, nil
+}
+
+// SetServiceDisabledState updates service disabled state in database
+func (s *sqlStore) SetServiceDisabledState(key string, disabled bool) error {
+	_, err := s.db.Exec(`
+		INSERT INTO service_state (service_key, disabled, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(service_key) DO UPDATE SET disabled=excluded.disabled, updated_at=excluded.updated_at`,
+		key, boolInt(disabled), dbTime(time.Now()))
+	return err
+}
+
+// GetIPBlock returns the active block of an IP, or nil if it is not blocked
+func (s *sqlStore) GetIPBlock(ip string) (*models.IPBlock, error) {
+	var b models.IPBlock
+	var blockedAt, reason sql.NullString
+	err := s.db.QueryRow(`SELECT ip_address, blocked_at, attempts, expires_at, reason
+		FROM ip_blocks
+		WHERE ip_address = ? AND blocked_at IS NOT NULL AND expires_at > ?`, ip, dbTime(time.Now())).
+		Scan(&b.IP, &blockedAt, &b.Attempts, &b.ExpiresAt, &reason)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.BlockedAt, b.Reason = blockedAt.String, reason.String
+	return &b, nil
+}
+
+// RecordFailedLogin counts a failed login from an IP and blocks it once
+// more than maxAttempts failures were seen within ttl of each other
+func (s *sqlStore) RecordFailedLogin(ip string, maxAttempts int, ttl time.Duration) error {
+	now := time.Now()
+	if _, err := s.db.Exec(`DELETE FROM ip_blocks WHERE ip_address = ? AND expires_at <= ?`, ip, dbTime(now)); err != nil {
+		return err
+	}
+
+	var attempts int
+	err := s.db.QueryRow(`SELECT attempts FROM ip_blocks WHERE ip_address = ?`, ip).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		_, err = s.db.Exec(`INSERT INTO ip_blocks (ip_address, blocked_at, attempts, expires_at, reason)
+			VALUES (?, NULL, 1, ?, 'Failed login attempts')`, ip, dbTime(now.Add(ttl)))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	attempts++
+	var blockedAt any
+	if attempts > maxAttempts {
+		blockedAt = dbTime(now)
+	}
+	_, err = s.db.Exec(`UPDATE ip_blocks
+		SET attempts = ?, blocked_at = ?, expires_at = ?, reason = 'Failed login attempts'
+		WHERE ip_address = ?`, attempts, blockedAt, dbTime(now.Add(ttl)), ip)
+	return err
+}
+
+// DeleteIPBlock removes the block record of an IP
+func (s *sqlStore) DeleteIPBlock(ip string) error {
+	_, err := s.db.Exec(`DELETE FROM ip_blocks WHERE ip_address = ?`, ip)
+	return err
+}
+
+// DeleteAllIPBlocks removes all block records and returns how many there were
+func (s *sqlStore) DeleteAllIPBlocks() (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM ip_blocks`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListIPBlocks returns the unexpired block records, most recently blocked
+// first, including addresses with failed logins that are not blocked yet
+func (s *sqlStore) ListIPBlocks() ([]models.IPBlock, error) {
+	rows, err := s.db.Query(`
+		SELECT ip_address, blocked_at, attempts, expires_at, reason
+		FROM ip_blocks
+		WHERE expires_at > ?
+		ORDER BY blocked_at DESC NULLS LAST`, dbTime(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.IPBlock{}
+	for rows.Next() {
+		var b models.IPBlock
+		var blockedAt, reason sql.NullString
+		if err := rows.Scan(&b.IP, &blockedAt, &b.Attempts, &b.ExpiresAt, &reason); err != nil {
+			return nil, err
+		}
+		b.BlockedAt, b.Reason = blockedAt.String, reason.String
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// ListStatusAlerts returns all status alerts, newest first
+func (s *sqlStore) ListStatusAlerts() ([]models.StatusAlert, error) {
+	rows, err := s.db.Query(`SELECT id, service_key, message, level, created_at FROM status_alerts ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []models.StatusAlert{}
+	for rows.Next() {
+		var a models.StatusAlert
+		var serviceKey sql.NullString
+		if err := rows.Scan(&a.ID, &serviceKey, &a.Message, &a.Level, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.ServiceKey = serviceKey.String
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// InsertStatusAlert stores a status alert; an empty service key makes it
+// site-wide
+func (s *sqlStore) InsertStatusAlert(a *models.StatusAlert) error {
+	_, err := s.db.Exec(`INSERT INTO status_alerts (id, service_key, message, level, created_at) VALUES (?, ?, ?, ?, ?)`,
+		a.ID, nullString(a.ServiceKey), a.Message, a.Level, a.CreatedAt)
+	return err
+}
+
+// DeleteStatusAlert removes a status alert
+func (s *sqlStore) DeleteStatusAlert(id string) error {
+	_, err := s.db.Exec(`DELETE FROM status_alerts WHERE id = ?`, id)
+	return err
+}
+
+// InsertSample records a service check sample. Failures are dropped: a
+// missing sample only leaves a gap in the history.
+func InsertSample(smp models.Sample) {
+	_ = store.InsertSample(smp)
+}
+
+// DeleteFailedSamples removes the failed samples taken since the given time
+func DeleteFailedSamples(since time.Time) error {
+	return store.DeleteFailedSamples(since)
+}
+
+// RecentFailures returns the latest failed samples since the given time
+func RecentFailures(since time.Time, limit int) ([]models.FailedSample, error) {
+	return store.RecentFailures(since, limit)
+}
+
+// LoadAlertConfig loads email alert configuration from database
+func LoadAlertConfig() (*models.AlertConfig, error) {
+	return store.LoadAlertConfig()
+}
+
+// SaveAlertConfig saves email alert configuration to database
+func SaveAlertConfig(config *models.AlertConfig) error {
+	return store.SaveAlertConfig(config)
+}
+
+// LoadResourcesUIConfig loads resources UI configuration from database
+func LoadResourcesUIConfig() (*models.ResourcesUIConfig, error) {
+	return store.LoadResourcesUIConfig()
+}
+
+// SaveResourcesUIConfig saves resources UI configuration to database
+func SaveResourcesUIConfig(config *models.ResourcesUIConfig) error {
+	return store.SaveResourcesUIConfig(config)
+}
+
+// LoadStatusHistory returns the last recorded status of a service, or nil
+func LoadStatusHistory(key string) (*models.StatusHistory, error) {
+	return store.LoadStatusHistory(key)
+}
+
+// SaveStatusHistory records the current status of a service
+func SaveStatusHistory(key string, h models.StatusHistory) error {
+	return store.SaveStatusHistory(key, h)
+}
+
+// GetServiceDisabledState loads service disabled state from database
+func GetServiceDisabledState(key string) (bool, error) {
+	return store.GetServiceDisabledState(key)
+}
+
+// SetServiceDisabledState updates service disabled state in database
+func SetServiceDisabledState(key string, disabled bool) error {
+	return store.SetServiceDisabledState(key, disabled)
+}
+
+// GetIPBlock returns the active block of an IP, or nil if it is not blocked
+func GetIPBlock(ip string) (*models.IPBlock, error) {
+	return store.GetIPBlock(ip)
+}
+
+// RecordFailedLogin counts a failed login and blocks the IP past maxAttempts
+func RecordFailedLogin(ip string, maxAttempts int, ttl time.Duration) error {
+	return store.RecordFailedLogin(ip, maxAttempts, ttl)
+}
+
+// DeleteIPBlock removes the block record of an IP
+func DeleteIPBlock(ip string) error {
+	return store.DeleteIPBlock(ip)
+}
+
+// DeleteAllIPBlocks removes all block records
+func DeleteAllIPBlocks() (int64, error) {
+	return store.DeleteAllIPBlocks()
+}
+
+// ListIPBlocks returns the unexpired block records
+func ListIPBlocks() ([]models.IPBlock, error) {
+	return store.ListIPBlocks()
+}
+
+// ListStatusAlerts returns all status alerts, newest first
+func ListStatusAlerts() ([]models.StatusAlert, error) {
+	return store.ListStatusAlerts()
+}
+
+// InsertStatusAlert stores a status alert
+func InsertStatusAlert(a *models.StatusAlert) error {
+	return store.InsertStatusAlert(a)
+}
+
+// DeleteStatusAlert removes a status alert
+func DeleteStatusAlert(id string) error {
+	return store.DeleteStatusAlert(id)
+}
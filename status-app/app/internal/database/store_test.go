@@ -0,0 +1,215 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"status/app/internal/models"
+	"testing"
+	"time"
+)
+
+// testStores opens a migrated SQLite database in a temporary directory and,
+// when TEST_DATABASE_URL is set, the PostgreSQL database it points to. The
+// tables the store uses are emptied first, so the PostgreSQL database should
+// be a scratch one.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	stores := map[string]Store{
+		DriverSQLite: openTestStore(t, DriverSQLite, filepath.Join(t.TempDir(), "test.db")),
+	}
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		stores[DriverPostgres] = openTestStore(t, DriverPostgres, dsn)
+	}
+	return stores
+}
+
+func openTestStore(t *testing.T, driver, source string) Store {
+	t.Helper()
+	if err := Open(driver, source); err != nil {
+		t.Fatalf("open %s: %v", driver, err)
+	}
+	conn := DB
+	t.Cleanup(func() { conn.Close() })
+	if _, err := Migrate(); err != nil {
+		t.Fatalf("migrate %s: %v", driver, err)
+	}
+	for _, table := range []string{"samples", "alert_config", "resources_ui_config", "service_status_history", "service_state", "ip_blocks", "status_alerts",
+		"samples_hourly", "samples_daily", "rollup_state", "incident_updates", "incident_services", "incidents",
+		"notification_attempts", "notification_outbox"} {
+		if _, err := conn.Exec(`DELETE FROM ` + table); err != nil {
+			t.Fatalf("empty %s: %v", table, err)
+		}
+	}
+	return NewSQLStore(conn)
+}
+
+func TestStoreSettings(t *testing.T) {
+	for driver, s := range testStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			if c, err := s.LoadAlertConfig(); err != nil || c != nil {
+				t.Fatalf("LoadAlertConfig before save = %v, %v", c, err)
+			}
+			alerts := &models.AlertConfig{
+				Enabled: true, SMTPHost: "smtp.example.com", SMTPPort: 587, SMTPUser: "user", SMTPPassword: "pw",
+				AlertEmail: "to@example.com", FromEmail: "from@example.com", AlertOnDown: true, AlertOnUp: true,
+				ReminderMinutes: 30,
+			}
+			for range 2 {
+				if err := s.SaveAlertConfig(alerts); err != nil {
+					t.Fatalf("SaveAlertConfig: %v", err)
+				}
+			}
+			got, err := s.LoadAlertConfig()
+			if err != nil || got == nil || *got != *alerts {
+				t.Errorf("LoadAlertConfig = %+v, %v, want %+v", got, err, alerts)
+			}
+
+			resources := &models.ResourcesUIConfig{Enabled: true, CPU: true, Temp: true}
+			if err := s.SaveResourcesUIConfig(resources); err != nil {
+				t.Fatalf("SaveResourcesUIConfig: %v", err)
+			}
+			if got, err := s.LoadResourcesUIConfig(); err != nil || got == nil || *got != *resources {
+				t.Errorf("LoadResourcesUIConfig = %+v, %v, want %+v", got, err, resources)
+			}
+		})
+	}
+}
+
+func TestStoreServiceState(t *testing.T) {
+	for driver, s := range testStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			if h, err := s.LoadStatusHistory("plex"); err != nil || h != nil {
+				t.Fatalf("LoadStatusHistory before save = %v, %v", h, err)
+			}
+			since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			if err := s.SaveStatusHistory("plex", models.StatusHistory{OK: true, Since: since}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SaveStatusHistory("plex", models.StatusHistory{OK: false, Degraded: true, Since: since.Add(time.Minute)}); err != nil {
+				t.Fatal(err)
+			}
+			h, err := s.LoadStatusHistory("plex")
+			if err != nil || h == nil || h.OK || !h.Degraded || !h.Since.Equal(since.Add(time.Minute)) {
+				t.Errorf("LoadStatusHistory = %+v, %v", h, err)
+			}
+
+			if disabled, err := s.GetServiceDisabledState("plex"); err != nil || disabled {
+				t.Fatalf("GetServiceDisabledState before set = %v, %v", disabled, err)
+			}
+			for _, want := range []bool{true, false, true} {
+				if err := s.SetServiceDisabledState("plex", want); err != nil {
+					t.Fatal(err)
+				}
+				if disabled, err := s.GetServiceDisabledState("plex"); err != nil || disabled != want {
+					t.Errorf("GetServiceDisabledState = %v, %v, want %v", disabled, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreIPBlocks(t *testing.T) {
+	for driver, s := range testStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			for i := range 3 {
+				if b, err := s.GetIPBlock("10.0.0.1"); err != nil || b != nil {
+					t.Fatalf("blocked after %d failures: %+v, %v", i, b, err)
+				}
+				if err := s.RecordFailedLogin("10.0.0.1", 2, time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := s.GetIPBlock("10.0.0.1")
+			if err != nil || b == nil || b.Attempts != 3 || b.BlockedAt == "" || b.Reason == "" {
+				t.Fatalf("GetIPBlock = %+v, %v", b, err)
+			}
+
+			// Failures that are not a block yet are listed after the blocks
+			if err := s.RecordFailedLogin("10.0.0.2", 2, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			blocks, err := s.ListIPBlocks()
+			if err != nil || len(blocks) != 2 || blocks[0].IP != "10.0.0.1" || blocks[1].IP != "10.0.0.2" || blocks[1].BlockedAt != "" {
+				t.Errorf("ListIPBlocks = %+v, %v", blocks, err)
+			}
+
+			if err := s.DeleteIPBlock("10.0.0.1"); err != nil {
+				t.Fatal(err)
+			}
+			if b, err := s.GetIPBlock("10.0.0.1"); err != nil || b != nil {
+				t.Errorf("GetIPBlock after delete = %+v, %v", b, err)
+			}
+			if n, err := s.DeleteAllIPBlocks(); err != nil || n != 1 {
+				t.Errorf("DeleteAllIPBlocks = %d, %v, want 1", n, err)
+			}
+		})
+	}
+}
+
+func TestStoreStatusAlerts(t *testing.T) {
+	for driver, s := range testStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			older := &models.StatusAlert{ID: "a1", Message: "Site-wide", Level: "info", CreatedAt: "2026-01-02T03:00:00Z"}
+			newer := &models.StatusAlert{ID: "a2", ServiceKey: "plex", Message: "Plex upgrade", Level: "warning", CreatedAt: "2026-01-02T04:00:00Z"}
+			for _, a := range []*models.StatusAlert{older, newer} {
+				if err := s.InsertStatusAlert(a); err != nil {
+					t.Fatal(err)
+				}
+			}
+			list, err := s.ListStatusAlerts()
+			if err != nil || len(list) != 2 || list[0] != *newer || list[1] != *older {
+				t.Fatalf("ListStatusAlerts = %+v, %v", list, err)
+			}
+			if err := s.DeleteStatusAlert("a2"); err != nil {
+				t.Fatal(err)
+			}
+			if list, err := s.ListStatusAlerts(); err != nil || len(list) != 1 || list[0].ID != "a1" {
+				t.Errorf("ListStatusAlerts after delete = %+v, %v", list, err)
+			}
+		})
+	}
+}
+
+func TestStoreSamples(t *testing.T) {
+	for driver, s := range testStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			start := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+			ms := 120
+			samples := []models.Sample{
+				{TakenAt: start, ServiceKey: "plex", OK: true, CheckStatus: "up", HTTPStatus: 200, MS: &ms,
+					Meta: map[string]any{"status": 200}, Phases: &models.LatencyPhases{Connect: 12.5}},
+				{TakenAt: start.Add(time.Minute), ServiceKey: "plex", CheckStatus: "down", HTTPStatus: 502, Message: "bad gateway",
+					Attempts: 2, AttemptErrors: []string{"timeout", "bad gateway"}},
+				{TakenAt: start.Add(2 * time.Minute), ServiceKey: "plex", CheckStatus: "down", Maintenance: true},
+				{TakenAt: start.Add(3 * time.Minute), ServiceKey: "nas", CheckStatus: "down"},
+			}
+			for _, smp := range samples {
+				if err := s.InsertSample(smp); err != nil {
+					t.Fatalf("InsertSample: %v", err)
+				}
+			}
+
+			// Failures in maintenance are left out, newest first
+			failures, err := s.RecentFailures(start, 10)
+			if err != nil || len(failures) != 2 {
+				t.Fatalf("RecentFailures = %+v, %v", failures, err)
+			}
+			if f := failures[0]; f.ServiceKey != "nas" || f.HTTPStatus != 0 {
+				t.Errorf("first failure = %+v", f)
+			}
+			if f := failures[1]; f.ServiceKey != "plex" || f.HTTPStatus != 502 || f.TakenAt != start.Add(time.Minute).Format(time.RFC3339) {
+				t.Errorf("second failure = %+v", f)
+			}
+			if failures, err := s.RecentFailures(start, 1); err != nil || len(failures) != 1 {
+				t.Errorf("RecentFailures with limit 1 = %+v, %v", failures, err)
+			}
+
+			if err := s.DeleteFailedSamples(start.Add(2 * time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if failures, err := s.RecentFailures(start, 10); err != nil || len(failures) != 1 || failures[0].ServiceKey != "plex" {
+				t.Errorf("RecentFailures after delete = %+v, %v", failures, err)
+			}
+		})
+	}
+}
@@ -1,50 +1,100 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"status/app/internal/alerts"
 	"status/app/internal/checker"
 	"status/app/internal/database"
+	"status/app/internal/maintenance"
 	"status/app/internal/models"
+	"status/app/internal/registry"
 	"status/app/internal/security"
+	"status/app/internal/state"
+	"sync"
 	"time"
 )
 
-// HandleIngestNow forces an immediate check of all services
-func HandleIngestNow(services []*models.Service) http.HandlerFunc {
+// Forced checks run concurrently and must answer within the server's 15s
+// write timeout; probes still retrying at the deadline are dropped and left
+// to the scheduler
+const (
+	probeTimeout     = 10 * time.Second
+	probeConcurrency = 8
+)
+
+// HandleIngestNow forces an immediate check of all services and responds
+// with the fresh results in the same shape as /api/check
+func HandleIngestNow(reg *registry.Registry, store *state.Store, alertMgr *alerts.Manager, maint *maintenance.Schedule) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+		defer cancel()
+
 		now := time.Now().UTC()
-		for _, s := range services {
+		sem := make(chan struct{}, probeConcurrency)
+		var wg sync.WaitGroup
+		for _, s := range reg.List() {
 			// Skip disabled services
 			if s.Disabled {
 				continue
 			}
-			checkOK, code, ms, _ := checker.HTTPCheck(s.URL, s.Timeout, s.MinOK, s.MaxOK)
-
-			// Update consecutive failure count
-			if checkOK {
-				s.ConsecutiveFailures = 0
-			} else {
-				s.ConsecutiveFailures++
-			}
-
-			// Service is only DOWN after 2 consecutive failures
-			ok := checkOK || s.ConsecutiveFailures < 2
-			database.InsertSample(now, s.Key, ok, code, ms)
-		}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				probeNow(ctx, store, alertMgr, maint, s, now)
+			}()
+		}
+		wg.Wait()
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"saved": true, "t": now})
+		_ = json.NewEncoder(w).Encode(livePayload(reg, store, maint, time.Now().UTC()))
 	}
 }
 
-// HandleResetRecent clears recent failure incidents
+// probeNow checks a service outside the schedule and records the result
+// like a scheduled check. It counts as one check toward the failure and
+// recovery thresholds of the service's status policy, so forced checks can
+// take a service down or bring it back up ahead of the schedule. A probe cut
+// short by ctx, e.g. because the admin disconnected, says nothing about the
+// service and is not recorded; ok is false then.
+func probeNow(ctx context.Context, store *state.Store, alertMgr *alerts.Manager, maint *maintenance.Schedule, s *models.Service, now time.Time) (st state.ServiceState, ok bool) {
+	res := checker.Run(ctx, s)
+	if ctx.Err() != nil {
+		return st, false
+	}
+	st = store.Record(s, res, now)
+	inMaint := maint.Active(s.Key, now) != nil
+	smp := res.Sample(now, s.Key, st.OK)
+	smp.Maintenance = inMaint
+	database.InsertSample(smp)
+	if cert := res.Cert(); cert != nil {
+		_ = database.SaveCertInfo(s.Key, cert)
+	}
+	if !inMaint {
+		alertMgr.CheckAndSendAlerts(s.Key, s.Label, st)
+	}
+	return st, true
+}
+
+// HandleResetRecent clears recent failure incidents. Ongoing incidents are
+// kept as they reflect the current status.
 func HandleResetRecent() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		_, err := database.DB.Exec(`DELETE FROM samples WHERE ok=0 AND taken_at >= datetime('now','-24 hours')`)
-		if err != nil {
+		since := time.Now().Add(-24 * time.Hour)
+		if err := database.DeleteFailedSamples(since); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		// Roll the affected hours and days up again without the deleted samples
+		if err := database.RewindRollups(since); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := database.DeleteResolvedIncidents(since); err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
 		}
@@ -54,7 +104,7 @@ func HandleResetRecent() http.HandlerFunc {
 }
 
 // HandleAdminCheck performs a forced check on a specific service
-func HandleAdminCheck(services []*models.Service) http.HandlerFunc {
+func HandleAdminCheck(reg *registry.Registry, store *state.Store, alertMgr *alerts.Manager, maint *maintenance.Schedule) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			Service string `json:"service"`
@@ -64,7 +114,7 @@ func HandleAdminCheck(services []*models.Service) http.HandlerFunc {
 			return
 		}
 
-		s := checker.FindServiceByKey(services, req.Service)
+		s := reg.Get(req.Service)
 		if s == nil {
 			http.Error(w, "unknown service", http.StatusNotFound)
 			return
@@ -75,28 +125,24 @@ func HandleAdminCheck(services []*models.Service) http.HandlerFunc {
 			return
 		}
 
+		ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+		defer cancel()
 		now := time.Now().UTC()
-		checkOK, code, ms, _ := checker.HTTPCheck(s.URL, s.Timeout, s.MinOK, s.MaxOK)
-
-		// Update consecutive failure count
-		if checkOK {
-			s.ConsecutiveFailures = 0
-		} else {
-			s.ConsecutiveFailures++
+		st, ok := probeNow(ctx, store, alertMgr, maint, s, now)
+		if !ok {
+			http.Error(w, "check timed out", http.StatusGatewayTimeout)
+			return
 		}
+		res := liveResult(s, st, now)
+		res.Maintenance = maint.Active(s.Key, now) != nil
 
-		// Service is only DOWN after 2 consecutive failures
-		ok := checkOK || s.ConsecutiveFailures < 2
-		database.InsertSample(now, s.Key, ok, code, ms)
-
-		degraded := ok && ms != nil && *ms > 200
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(models.LiveResult{Label: s.Label, OK: ok, Status: code, MS: ms, Degraded: degraded})
+		_ = json.NewEncoder(w).Encode(res)
 	}
 }
 
 // HandleToggleMonitoring enables or disables monitoring for a service
-func HandleToggleMonitoring(services []*models.Service) http.HandlerFunc {
+func HandleToggleMonitoring(reg *registry.Registry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			Service string `json:"service"`
@@ -107,22 +153,19 @@ func HandleToggleMonitoring(services []*models.Service) http.HandlerFunc {
 			return
 		}
 
-		s := checker.FindServiceByKey(services, req.Service)
-		if s == nil {
-			http.Error(w, "unknown service", http.StatusNotFound)
-			return
-		}
-
-		s.Disabled = !req.Enable
-		if err := database.SetServiceDisabledState(req.Service, s.Disabled); err != nil {
+		if err := reg.SetDisabled(req.Service, !req.Enable); err != nil {
+			if errors.Is(err, database.ErrServiceNotFound) {
+				http.Error(w, "unknown service", http.StatusNotFound)
+				return
+			}
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{
-			"service": s.Key,
-			"enabled": !s.Disabled,
+			"service": req.Service,
+			"enabled": req.Enable,
 		})
 	}
 }
@@ -192,14 +235,7 @@ func HandleGetAlertsConfig(alertMgr *alerts.Manager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		config := alertMgr.GetConfig()
 		if config == nil {
-			// Return default config
-			config = &models.AlertConfig{
-				Enabled:         false,
-				SMTPPort:        587,
-				AlertOnDown:     true,
-				AlertOnDegraded: true,
-				AlertOnUp:       false,
-			}
+			config = models.DefaultAlertConfig()
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -215,6 +251,10 @@ func HandleSaveAlertsConfig(alertMgr *alerts.Manager) http.HandlerFunc {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
+		if config.ReminderMinutes < 0 || config.ReminderMinutes > 7*24*60 {
+			http.Error(w, "reminder_minutes must be between 0 and 7 days", http.StatusBadRequest)
+			return
+		}
 
 		if err := database.SaveAlertConfig(&config); err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
@@ -249,6 +289,7 @@ func HandleTestEmail(alertMgr *alerts.Manager) http.HandlerFunc {
 			"test",
 			"This is a test email from your Servicarr monitoring system. If you received this, your email configuration is working correctly!",
 			alertMgr.GetStatusPageURL(),
+			"",
 		)
 
 		err := alertMgr.SendEmail(subject, body)
@@ -273,25 +314,11 @@ func HandleTestEmail(alertMgr *alerts.Manager) http.HandlerFunc {
 // HandleGetStatusAlerts returns all status alerts
 func HandleGetStatusAlerts() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := database.DB.Query(`SELECT id, service_key, message, level, created_at FROM status_alerts ORDER BY created_at DESC`)
+		alerts, err := database.ListStatusAlerts()
 		if err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
 		}
-		defer rows.Close()
-
-		alerts := []models.StatusAlert{}
-		for rows.Next() {
-			var a models.StatusAlert
-			var serviceKey sql.NullString
-			if err := rows.Scan(&a.ID, &serviceKey, &a.Message, &a.Level, &a.CreatedAt); err != nil {
-				continue
-			}
-			if serviceKey.Valid {
-				a.ServiceKey = serviceKey.String
-			}
-			alerts = append(alerts, a)
-		}
 
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(alerts)
@@ -319,15 +346,13 @@ func HandleCreateStatusAlert() http.HandlerFunc {
 		}
 
 		id := fmt.Sprintf("alert_%d", time.Now().UnixNano())
-		now := time.Now().UTC().Format(time.RFC3339)
-
-		var serviceKey interface{}
-		if req.ServiceKey != "" {
-			serviceKey = req.ServiceKey
-		}
-
-		_, err := database.DB.Exec(`INSERT INTO status_alerts (id, service_key, message, level, created_at) VALUES (?, ?, ?, ?, ?)`,
-			id, serviceKey, req.Message, req.Level, now)
+		err := database.InsertStatusAlert(&models.StatusAlert{
+			ID:         id,
+			ServiceKey: req.ServiceKey,
+			Message:    req.Message,
+			Level:      req.Level,
+			CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		})
 		if err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
@@ -347,8 +372,7 @@ func HandleDeleteStatusAlert() http.HandlerFunc {
 			return
 		}
 
-		_, err := database.DB.Exec(`DELETE FROM status_alerts WHERE id = ?`, id)
-		if err != nil {
+		if err := database.DeleteStatusAlert(id); err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
 		}
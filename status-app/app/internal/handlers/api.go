@@ -1,65 +1,91 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"status/app/internal/checker"
+	"status/app/internal/config"
 	"status/app/internal/database"
+	"status/app/internal/maintenance"
 	"status/app/internal/models"
+	"status/app/internal/registry"
+	"status/app/internal/state"
 	"strconv"
 	"time"
 )
 
-// HandleCheck returns current status of all services
-func HandleCheck(services []*models.Service) http.HandlerFunc {
+// HandleCheck returns current status of all services. It serves the last
+// result recorded by the scheduler rather than probing, so the page is cheap
+// to load and every visitor sees the same state.
+func HandleCheck(reg *registry.Registry, store *state.Store, maint *maintenance.Schedule) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		now := time.Now().UTC()
-		out := models.LivePayload{T: now, Status: map[string]models.LiveResult{}}
-
-		for _, s := range services {
-			if s.Disabled {
-				// Include disabled services in response
-				out.Status[s.Key] = models.LiveResult{
-					Label:    s.Label,
-					OK:       false,
-					Status:   0,
-					MS:       nil,
-					Disabled: true,
-					Degraded: false,
-				}
-				continue
-			}
-			checkOK, code, ms, _ := checker.HTTPCheck(s.URL, s.Timeout, s.MinOK, s.MaxOK)
-
-			// Update consecutive failure count
-			if checkOK {
-				s.ConsecutiveFailures = 0
-			} else {
-				s.ConsecutiveFailures++
-			}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(livePayload(reg, store, maint, time.Now().UTC()))
+	}
+}
 
-			// Service is only DOWN after 2 consecutive failures
-			ok := checkOK || s.ConsecutiveFailures < 2
-			degraded := ok && ms != nil && *ms > 200
-			out.Status[s.Key] = models.LiveResult{
-				Label:    s.Label,
-				OK:       ok,
-				Status:   code,
-				MS:       ms,
-				Disabled: false,
-				Degraded: degraded,
-			}
+// livePayload builds the /api/check response from the state store
+func livePayload(reg *registry.Registry, store *state.Store, maint *maintenance.Schedule, now time.Time) models.LivePayload {
+	out := models.LivePayload{T: now, Order: []string{}, Status: map[string]models.LiveResult{}}
+	for _, s := range reg.List() {
+		out.Order = append(out.Order, s.Key)
+		if s.Disabled {
+			// Include disabled services in response
+			out.Status[s.Key] = models.LiveResult{Label: s.Label, Disabled: true}
+			continue
+		}
+		res := models.LiveResult{Label: s.Label, OK: true, Pending: true}
+		if st, ok := store.Get(s.Key); ok {
+			res = liveResult(s, st, now)
 		}
+		res.Maintenance = maint.Active(s.Key, now) != nil
+		out.Status[s.Key] = res
+	}
+	return out
+}
+
+// liveResult converts the state of an enabled service into its API form
+func liveResult(s *models.Service, st state.ServiceState, now time.Time) models.LiveResult {
+	res := st.Result
+	msg := res.Message
+	if msg == "" && st.Degraded {
+		msg = st.Reason
+	}
+	checkedAt := st.CheckedAt.UTC()
+	age := max(int(now.Sub(checkedAt).Seconds()), 0)
+	return models.LiveResult{
+		Label:         s.Label,
+		OK:            st.OK,
+		Status:        res.Code,
+		MS:            res.MS,
+		Degraded:      st.Degraded,
+		Message:       msg,
+		Meta:          res.Meta,
+		Phases:        res.Phases,
+		Attempts:      res.Attempts,
+		AttemptErrors: res.AttemptErrors,
+		CheckedAt:     &checkedAt,
+		AgeSeconds:    &age,
+	}
+}
 
+// HandleBranding returns the public status page branding
+func HandleBranding(branding func() *config.Branding) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(out)
+		_ = json.NewEncoder(w).Encode(branding())
 	}
 }
 
-// HandleMetrics returns historical uptime metrics
-func HandleMetrics() http.HandlerFunc {
+// HandleMetrics returns historical uptime metrics. Windows are capped at
+// the retention of the tier they are read from.
+func HandleMetrics(ret database.Retention) http.HandlerFunc {
+	maxDays, maxHours := 365, 24*365
+	if ret.Daily > 0 {
+		maxDays = min(maxDays, int(ret.Daily/(24*time.Hour)))
+	}
+	if ret.Hourly > 0 {
+		maxHours = min(maxHours, int(ret.Hourly/time.Hour))
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		days := 7
 		hours := 0
@@ -70,8 +96,8 @@ func HandleMetrics() http.HandlerFunc {
 				if n < 1 {
 					n = 1
 				}
-				if n > 365 {
-					n = 365
+				if n > maxDays {
+					n = maxDays
 				}
 				days = n
 				hours = days * 24
@@ -81,8 +107,8 @@ func HandleMetrics() http.HandlerFunc {
 				if n < 1 {
 					n = 1
 				}
-				if n > 24*365 {
-					n = 24 * 365
+				if n > maxHours {
+					n = maxHours
 				}
 				hours = n
 				days = 0
@@ -91,95 +117,72 @@ func HandleMetrics() http.HandlerFunc {
 			hours = 24
 		}
 
-		var since string
-		var groupBy string
-		var timeField string
-
+		// Daily windows read the daily rollups and hourly windows the hourly
+		// ones; buckets not rolled up yet are aggregated from raw samples
+		tier, timeField := database.TierHourly, "hour"
+		since := time.Now().UTC().Add(-time.Duration(hours) * time.Hour)
 		if days > 0 {
-			// Use daily aggregation
-			since = time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour).Format(time.RFC3339)
-			groupBy = "substr(taken_at,1,10)"
-			timeField = "day"
-		} else {
-			// Use hourly aggregation
-			since = time.Now().UTC().Add(-time.Duration(hours) * time.Hour).Format(time.RFC3339)
-			groupBy = "substr(taken_at,1,13) || ':00:00Z'"
-			timeField = "hour"
+			tier, timeField = database.TierDaily, "day"
+			since = time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour)
 		}
-
-		// #nosec G201 -- groupBy is derived from fixed string constants, not user input
-		query := fmt.Sprintf(`
-WITH aggregated AS (
-  SELECT service_key,
-         %s AS time_bin,
-         SUM(ok) AS up_count,
-         COUNT(*) AS total_count,
-         AVG(latency_ms) AS avg_ms
-  FROM samples
-  WHERE taken_at >= ?
-  GROUP BY service_key, time_bin
-)
-SELECT service_key, time_bin, up_count, total_count, avg_ms
-FROM aggregated ORDER BY time_bin ASC`, groupBy)
-
-		rows, err := database.DB.Query(query, since)
+		buckets, err := database.MetricBuckets(tier, since)
 		if err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
 		}
-		defer rows.Close()
 
+		// Samples taken during maintenance windows don't count towards uptime
 		series := map[string][]map[string]any{}
-		for rows.Next() {
-			var key, tb string
-			var up, total int
-			var avgMs sql.NullFloat64
-			_ = rows.Scan(&key, &tb, &up, &total, &avgMs)
-			u := 0
-			if total > 0 {
-				u = int((float64(up)/float64(total))*100 + 0.5)
+		up, total := map[string]int{}, map[string]int{}
+		for _, b := range buckets {
+			var u any
+			if b.Total > 0 {
+				u = int((float64(b.Up)/float64(b.Total))*100 + 0.5)
+			} else if b.Maintenance == 0 {
+				u = 0
 			}
-			point := map[string]any{timeField: tb, "uptime": u}
-			if avgMs.Valid {
-				point["avg_ms"] = avgMs.Float64
+			// uptime is null for bins spent entirely in maintenance
+			point := map[string]any{timeField: b.Bucket, "uptime": u}
+			if b.Maintenance > 0 {
+				point["maintenance_samples"] = b.Maintenance
 			}
-			series[key] = append(series[key], point)
+			if b.AvgMS != nil {
+				point["avg_ms"] = *b.AvgMS
+				point["min_ms"] = *b.MinMS
+				point["max_ms"] = *b.MaxMS
+				point["p95_ms"] = *b.P95MS
+			}
+			// Average HTTP latency phases, for stacked latency bars
+			if b.Phases != nil {
+				point["phases"] = *b.Phases
+			}
+			series[b.ServiceKey] = append(series[b.ServiceKey], point)
+			up[b.ServiceKey] += b.Up
+			total[b.ServiceKey] += b.Total
 		}
 
 		overall := map[string]float64{}
-		rows2, err := database.DB.Query(`SELECT service_key, SUM(ok), COUNT(*) FROM samples WHERE taken_at >= ? GROUP BY service_key`, since)
-		if err == nil {
-			defer rows2.Close()
-			for rows2.Next() {
-				var key string
-				var up, total sql.NullInt64
-				_ = rows2.Scan(&key, &up, &total)
-				if total.Valid && total.Int64 > 0 {
-					overall[key] = float64(up.Int64) * 100.0 / float64(total.Int64)
-				}
+		for key, n := range total {
+			if n > 0 {
+				overall[key] = float64(up[key]) * 100.0 / float64(n)
 			}
 		}
 
-		downs := []map[string]any{}
-		downsSince := time.Now().UTC().Add(-24 * time.Hour).Format(time.RFC3339)
-		rows3, err := database.DB.Query(`SELECT taken_at, service_key, http_status
-                             FROM samples
-                             WHERE ok=0 AND taken_at >= ?
-                             ORDER BY taken_at DESC LIMIT 50`, downsSince)
-		if err == nil {
-			defer rows3.Close()
-			for rows3.Next() {
-				var ts, key string
-				var st sql.NullInt64
-				_ = rows3.Scan(&ts, &key, &st)
-				downs = append(downs, map[string]any{"taken_at": ts, "service_key": key, "http_status": st.Int64})
-			}
+		downs, err := database.RecentFailures(time.Now().Add(-24*time.Hour), 50)
+		if err != nil {
+			downs = []models.FailedSample{}
+		}
+
+		certs, err := database.LoadCertInfos()
+		if err != nil {
+			certs = map[string]*models.CertInfo{}
 		}
 
 		response := map[string]any{
 			"series":  series,
 			"overall": overall,
 			"downs":   downs,
+			"certs":   certs,
 		}
 
 		if days > 0 {
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"slices"
+	"status/app/internal/alerts"
+	"status/app/internal/auth"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/registry"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listIncidents loads the incidents of the window given by ?days= (default
+// and at most 90) and fills in the service labels
+func listIncidents(reg *registry.Registry, r *http.Request) ([]models.Incident, error) {
+	days := 90
+	if q := r.URL.Query().Get("days"); q != "" {
+		if n, err := strconv.Atoi(q); err == nil {
+			days = min(max(n, 1), 90)
+		}
+	}
+	incidents, err := database.ListIncidents(time.Now().UTC().AddDate(0, 0, -days))
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	for _, s := range reg.List() {
+		labels[s.Key] = s.Label
+	}
+	for i := range incidents {
+		for j, svc := range incidents[i].Services {
+			if label := labels[svc.Key]; label != "" {
+				incidents[i].Services[j].Label = label
+			} else {
+				// The service has since been removed
+				incidents[i].Services[j].Label = svc.Key
+			}
+		}
+	}
+	return incidents, nil
+}
+
+// HandleIncidents returns the public incident history. Root errors are left
+// out as they can reveal internal hosts and addresses, and acknowledgements
+// as they name admins.
+func HandleIncidents(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		incidents, err := listIncidents(reg, r)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		for i := range incidents {
+			incidents[i].RootError = ""
+			incidents[i].AcknowledgedAt = nil
+			incidents[i].AcknowledgedBy = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"incidents": incidents})
+	}
+}
+
+// HandleAdminIncidents returns the incident history including root errors
+func HandleAdminIncidents(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		incidents, err := listIncidents(reg, r)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"incidents": incidents})
+	}
+}
+
+// incidentUpdateRequest is an incident update posted by an admin. When
+// creating an incident it also carries the title.
+type incidentUpdateRequest struct {
+	IncidentID int64    `json:"incident_id"`
+	Title      string   `json:"title"`
+	Status     string   `json:"status"`
+	Message    string   `json:"message"`
+	Impact     string   `json:"impact"`
+	Services   []string `json:"services"` // affected service keys; omit to keep them on update
+}
+
+// validate checks the request; impact and services are optional so an
+// update can leave them unchanged
+func (req *incidentUpdateRequest) validate(reg *registry.Registry) error {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Message = strings.TrimSpace(req.Message)
+	if !slices.Contains(models.IncidentStatuses, req.Status) {
+		return errors.New("status must be one of " + strings.Join(models.IncidentStatuses, ", "))
+	}
+	if req.Impact != "" && !slices.Contains(models.IncidentImpacts, req.Impact) {
+		return errors.New("impact must be one of " + strings.Join(models.IncidentImpacts, ", "))
+	}
+	if req.Message == "" {
+		return errors.New("message required")
+	}
+	for _, key := range req.Services {
+		if reg.Get(key) == nil {
+			return errors.New("unknown service " + key)
+		}
+	}
+	return nil
+}
+
+// HandleCreateIncident opens an incident posted by an admin
+func HandleCreateIncident(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req incidentUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := req.validate(reg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Title == "" {
+			http.Error(w, "title required", http.StatusBadRequest)
+			return
+		}
+		if req.Impact == "" {
+			req.Impact = models.ImpactMinor
+		}
+
+		inc := &models.Incident{Title: req.Title, Status: req.Status, Impact: req.Impact}
+		for _, key := range req.Services {
+			inc.Services = append(inc.Services, models.IncidentService{Key: key})
+		}
+		id, err := database.CreateIncident(inc, req.Message, time.Now())
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "success": true})
+	}
+}
+
+// HandleAddIncidentUpdate posts a status update to an incident
+func HandleAddIncidentUpdate(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req incidentUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IncidentID == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := req.validate(reg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := database.AddIncidentUpdate(req.IncidentID, req.Status, req.Message, req.Impact, req.Services, time.Now())
+		if err != nil {
+			if errors.Is(err, database.ErrIncidentNotFound) {
+				http.Error(w, "unknown incident", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, database.ErrIncidentReopen) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	}
+}
+
+// HandleDeleteIncident removes an incident (?id=), e.g. one caused by a
+// monitoring misconfiguration
+func HandleDeleteIncident() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := database.DeleteIncident(id); err != nil {
+			if errors.Is(err, database.ErrIncidentNotFound) {
+				http.Error(w, "unknown incident", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"deleted": id})
+	}
+}
+
+// HandleAcknowledgeIncident acknowledges an incident (?id=) as the signed-in
+// admin, which stops its reminders
+func HandleAcknowledgeIncident(authMgr *auth.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		by := "admin"
+		if s, err := authMgr.ParseSession(r); err == nil {
+			by = s.U
+		}
+		acknowledged, err := database.AcknowledgeIncident(id, by, time.Now())
+		if err != nil {
+			if errors.Is(err, database.ErrIncidentNotFound) {
+				http.Error(w, "unknown incident", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "acknowledged": acknowledged})
+	}
+}
+
+// HandleAckLink acknowledges an incident from the signed link in an alert
+// email (id, exp and sig). A GET only shows a confirmation page, so link
+// scanners and prefetchers following the link do not stop reminders; the
+// page's button POSTs the same signed values back.
+func HandleAckLink(alertMgr *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err1 := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		exp, err2 := strconv.ParseInt(r.FormValue("exp"), 10, 64)
+		sig := r.FormValue("sig")
+		if err1 != nil || err2 != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if !alertMgr.VerifyAck(id, exp, sig) {
+			http.Error(w, "invalid or expired link", http.StatusForbidden)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			writeAckPage(w, fmt.Sprintf(`<p>Acknowledge incident #%d and stop its reminders?</p>
+<form method="post" action="/api/incidents/ack">
+<input type="hidden" name="id" value="%d"><input type="hidden" name="exp" value="%d"><input type="hidden" name="sig" value="%s">
+<button type="submit">Acknowledge</button>
+</form>`, id, id, exp, html.EscapeString(sig)))
+			return
+		}
+
+		acknowledged, err := database.AcknowledgeIncident(id, "email link", time.Now())
+		if err != nil {
+			if errors.Is(err, database.ErrIncidentNotFound) {
+				http.Error(w, "unknown incident", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		msg := "Incident acknowledged. Reminders for it have stopped."
+		if !acknowledged {
+			msg = "This incident was already acknowledged."
+		}
+		writeAckPage(w, "<p>"+html.EscapeString(msg)+"</p>")
+	}
+}
+
+// writeAckPage answers an acknowledge link with a minimal page
+func writeAckPage(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"><meta name="viewport" content="width=device-width, initial-scale=1.0"><meta name="robots" content="noindex"><title>Servicarr</title></head>
+<body>%s
+<p><a href="/">View Status Dashboard</a></p></body>
+</html>
+`, body)
+}
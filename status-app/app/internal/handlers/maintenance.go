@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"status/app/internal/database"
+	"status/app/internal/maintenance"
+	"status/app/internal/models"
+	"status/app/internal/registry"
+	"strconv"
+	"time"
+)
+
+// maintenanceHorizon is how far ahead the status page announces maintenance
+const maintenanceHorizon = 7 * 24 * time.Hour
+
+// HandleMaintenance returns the ongoing maintenance and the maintenance
+// starting within the next week, for the notice on the status page
+func HandleMaintenance(reg *registry.Registry, maint *maintenance.Schedule) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		labels := map[string]string{}
+		for _, s := range reg.List() {
+			labels[s.Key] = s.Label
+		}
+		occurrences := maint.Occurrences(time.Now(), maintenanceHorizon)
+		for i := range occurrences {
+			for j, svc := range occurrences[i].Services {
+				if label := labels[svc.Key]; label != "" {
+					occurrences[i].Services[j].Label = label
+				} else {
+					occurrences[i].Services[j].Label = svc.Key
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"maintenance": occurrences})
+	}
+}
+
+// HandleListMaintenance returns all maintenance window definitions
+func HandleListMaintenance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		windows, err := database.ListMaintenanceWindows()
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"windows": windows})
+	}
+}
+
+// decodeMaintenance reads and validates a maintenance window from the request
+func decodeMaintenance(w http.ResponseWriter, r *http.Request, reg *registry.Registry) (*models.MaintenanceWindow, bool) {
+	var m models.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := maintenance.Validate(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	for _, key := range m.Services {
+		if reg.Get(key) == nil {
+			http.Error(w, "unknown service "+key, http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return &m, true
+}
+
+// HandleCreateMaintenance schedules a maintenance window
+func HandleCreateMaintenance(reg *registry.Registry, maint *maintenance.Schedule) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m, ok := decodeMaintenance(w, r, reg)
+		if !ok {
+			return
+		}
+		id, err := database.InsertMaintenanceWindow(m)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := maint.Reload(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "success": true})
+	}
+}
+
+// HandleUpdateMaintenance replaces a maintenance window, identified by the id in the body
+func HandleUpdateMaintenance(reg *registry.Registry, maint *maintenance.Schedule) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m, ok := decodeMaintenance(w, r, reg)
+		if !ok {
+			return
+		}
+		if m.ID == 0 {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		if err := database.UpdateMaintenanceWindow(m); err != nil {
+			if errors.Is(err, database.ErrMaintenanceNotFound) {
+				http.Error(w, "unknown maintenance window", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := maint.Reload(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	}
+}
+
+// HandleDeleteMaintenance removes a maintenance window (?id=)
+func HandleDeleteMaintenance(maint *maintenance.Schedule) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := database.DeleteMaintenanceWindow(id); err != nil {
+			if errors.Is(err, database.ErrMaintenanceNotFound) {
+				http.Error(w, "unknown maintenance window", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := maint.Reload(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"deleted": id})
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"status/app/internal/alerts"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/notify"
+	"strconv"
+)
+
+// maskChannel hides the token, signing secret and header values of a
+// channel and the URL of the types whose webhook URL is itself the credential
+func maskChannel(ch *models.NotificationChannel) *models.NotificationChannel {
+	out := *ch
+	if out.Settings.Token != "" {
+		out.Settings.Token = secretMask
+	}
+	if out.Settings.Secret != "" {
+		out.Settings.Secret = secretMask
+	}
+	if len(out.Settings.Headers) > 0 {
+		out.Settings.Headers = make(map[string]string, len(ch.Settings.Headers))
+		for name := range ch.Settings.Headers {
+			out.Settings.Headers[name] = secretMask
+		}
+	}
+	if (out.Type == models.ChannelDiscord || out.Type == models.ChannelSlack) && out.Settings.URL != "" {
+		out.Settings.URL = secretMask
+	}
+	return &out
+}
+
+// mergeChannelSecrets replaces masked values sent in a request with the
+// stored ones
+func mergeChannelSecrets(ch, stored *models.NotificationChannel) {
+	if ch.Settings.Token == secretMask {
+		ch.Settings.Token = stored.Settings.Token
+	}
+	if ch.Settings.URL == secretMask {
+		ch.Settings.URL = stored.Settings.URL
+	}
+	if ch.Settings.Secret == secretMask {
+		ch.Settings.Secret = stored.Settings.Secret
+	}
+	for name, value := range ch.Settings.Headers {
+		if value == secretMask {
+			if stored, ok := stored.Settings.Headers[name]; ok {
+				ch.Settings.Headers[name] = stored
+			} else {
+				delete(ch.Settings.Headers, name)
+			}
+		}
+	}
+}
+
+// HandleListChannels returns all notification channels with their secrets masked
+func HandleListChannels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channels, err := database.ListNotificationChannels()
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		out := make([]*models.NotificationChannel, 0, len(channels))
+		for _, ch := range channels {
+			out = append(out, maskChannel(ch))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"channels": out, "types": models.ChannelTypes})
+	}
+}
+
+// HandleCreateChannel adds a notification channel
+func HandleCreateChannel(alertMgr *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ch models.NotificationChannel
+		if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		// There is nothing stored to keep yet
+		mergeChannelSecrets(&ch, &models.NotificationChannel{})
+		if err := notify.Validate(&ch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, err := database.InsertNotificationChannel(&ch)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := alertMgr.ReloadChannels(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "success": true})
+	}
+}
+
+// HandleUpdateChannel replaces a notification channel, identified by the id
+// in the body. Masked secrets keep their stored value.
+func HandleUpdateChannel(alertMgr *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ch models.NotificationChannel
+		if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		stored, err := database.GetNotificationChannel(ch.ID)
+		if err != nil {
+			if errors.Is(err, database.ErrChannelNotFound) {
+				http.Error(w, "unknown notification channel", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		mergeChannelSecrets(&ch, stored)
+		if err := notify.Validate(&ch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := database.UpdateNotificationChannel(&ch); err != nil {
+			if errors.Is(err, database.ErrChannelNotFound) {
+				http.Error(w, "unknown notification channel", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := alertMgr.ReloadChannels(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	}
+}
+
+// HandleDeleteChannel removes a notification channel (?id=)
+func HandleDeleteChannel(alertMgr *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := database.DeleteNotificationChannel(id); err != nil {
+			if errors.Is(err, database.ErrChannelNotFound) {
+				http.Error(w, "unknown notification channel", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := alertMgr.ReloadChannels(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"deleted": id})
+	}
+}
+
+// HandleTestChannel sends a test notification to a channel (?id=), whether
+// or not it is enabled
+func HandleTestChannel(alertMgr *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		ch, err := database.GetNotificationChannel(id)
+		if err != nil {
+			if errors.Is(err, database.ErrChannelNotFound) {
+				http.Error(w, "unknown notification channel", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := alertMgr.TestChannel(ch); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": fmt.Sprintf("Failed to send test notification: %v", err),
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Test notification sent successfully to " + ch.Name,
+		})
+	}
+}
+
+// HandleListNotificationAttempts returns the most recent notification
+// delivery attempts (?limit=, default 100; ?status=sent|failed)
+func HandleListNotificationAttempts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 100
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 1000 {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		status := r.URL.Query().Get("status")
+		if status != "" && status != models.DeliverySent && status != models.DeliveryFailed {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		attempts, err := database.ListNotificationAttempts(status, limit)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"attempts": attempts})
+	}
+}
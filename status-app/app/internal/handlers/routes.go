@@ -4,26 +4,77 @@ import (
 	"net/http"
 	"status/app/internal/alerts"
 	"status/app/internal/auth"
-	"status/app/internal/models"
+	"status/app/internal/config"
+	"status/app/internal/database"
+	"status/app/internal/maintenance"
+	"status/app/internal/registry"
 	"status/app/internal/resources"
 	"status/app/internal/security"
+	"status/app/internal/state"
 )
 
 // SetupRoutes configures all HTTP routes and middlewares
-func SetupRoutes(authMgr *auth.Auth, alertMgr *alerts.Manager, services []*models.Service, gl *resources.Client) http.Handler {
+func SetupRoutes(authMgr *auth.Auth, alertMgr *alerts.Manager, reg *registry.Registry, store *state.Store, maint *maintenance.Schedule, gl *resources.Client, ret database.Retention, branding func() *config.Branding) http.Handler {
 	// Public API routes (with rate limiting)
 	api := http.NewServeMux()
-	api.HandleFunc("/api/check", HandleCheck(services))
-	api.HandleFunc("/api/metrics", HandleMetrics())
+	api.HandleFunc("/api/check", HandleCheck(reg, store, maint))
+	api.HandleFunc("/api/metrics", HandleMetrics(ret))
 	api.HandleFunc("/api/resources", HandleResources(gl))
 	api.HandleFunc("/api/resources/config", HandleGetResourcesUIConfig())
+	api.HandleFunc("/api/branding", HandleBranding(branding))
+	api.HandleFunc("/api/incidents", HandleIncidents(reg))
+	api.HandleFunc("/api/incidents/ack", HandleAckLink(alertMgr))
+	api.HandleFunc("/api/maintenance", HandleMaintenance(reg, maint))
 
 	// Admin API routes (with authentication)
 	authAPI := http.NewServeMux()
-	authAPI.HandleFunc("/api/admin/ingest-now", authMgr.RequireAuth(HandleIngestNow(services)))
+	authAPI.HandleFunc("/api/admin/ingest-now", authMgr.RequireAuth(HandleIngestNow(reg, store, alertMgr, maint)))
 	authAPI.HandleFunc("/api/admin/reset-recent", authMgr.RequireAuth(HandleResetRecent()))
-	authAPI.HandleFunc("/api/admin/check", authMgr.RequireAuth(HandleAdminCheck(services)))
-	authAPI.HandleFunc("/api/admin/toggle-monitoring", authMgr.RequireAuth(HandleToggleMonitoring(services)))
+	authAPI.HandleFunc("/api/admin/check", authMgr.RequireAuth(HandleAdminCheck(reg, store, alertMgr, maint)))
+	authAPI.HandleFunc("/api/admin/toggle-monitoring", authMgr.RequireAuth(HandleToggleMonitoring(reg)))
+	authAPI.HandleFunc("/api/admin/services", authMgr.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			HandleListServices(reg)(w, r)
+		case http.MethodPost:
+			HandleCreateService(reg)(w, r)
+		case http.MethodPut:
+			HandleUpdateService(reg)(w, r)
+		case http.MethodDelete:
+			HandleDeleteService(reg)(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	authAPI.HandleFunc("/api/admin/services/reorder", authMgr.RequireAuth(HandleReorderServices(reg)))
+	authAPI.HandleFunc("/api/admin/incidents", authMgr.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			HandleAdminIncidents(reg)(w, r)
+		case http.MethodPost:
+			HandleCreateIncident(reg)(w, r)
+		case http.MethodDelete:
+			HandleDeleteIncident()(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	authAPI.HandleFunc("/api/admin/incidents/updates", authMgr.RequireAuth(HandleAddIncidentUpdate(reg)))
+	authAPI.HandleFunc("/api/admin/incidents/ack", authMgr.RequireAuth(HandleAcknowledgeIncident(authMgr)))
+	authAPI.HandleFunc("/api/admin/maintenance", authMgr.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			HandleListMaintenance()(w, r)
+		case http.MethodPost:
+			HandleCreateMaintenance(reg, maint)(w, r)
+		case http.MethodPut:
+			HandleUpdateMaintenance(reg, maint)(w, r)
+		case http.MethodDelete:
+			HandleDeleteMaintenance(maint)(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
 	authAPI.HandleFunc("/api/admin/blocks", authMgr.RequireAuth(HandleListBlocks()))
 	authAPI.HandleFunc("/api/admin/unblock", authMgr.RequireAuth(HandleUnblockIP()))
 	authAPI.HandleFunc("/api/admin/clear-blocks", authMgr.RequireAuth(HandleClearAllBlocks()))
@@ -46,6 +97,36 @@ func SetupRoutes(authMgr *auth.Auth, alertMgr *alerts.Manager, services []*model
 		}
 	}))
 	authAPI.HandleFunc("/api/admin/alerts/test", authMgr.RequireAuth(HandleTestEmail(alertMgr)))
+	authAPI.HandleFunc("/api/admin/alerts/rules", authMgr.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			HandleListAlertRules()(w, r)
+		case http.MethodPost:
+			HandleCreateAlertRule(reg, alertMgr)(w, r)
+		case http.MethodPut:
+			HandleUpdateAlertRule(reg, alertMgr)(w, r)
+		case http.MethodDelete:
+			HandleDeleteAlertRule(alertMgr)(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	authAPI.HandleFunc("/api/admin/notifications/channels", authMgr.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			HandleListChannels()(w, r)
+		case http.MethodPost:
+			HandleCreateChannel(alertMgr)(w, r)
+		case http.MethodPut:
+			HandleUpdateChannel(alertMgr)(w, r)
+		case http.MethodDelete:
+			HandleDeleteChannel(alertMgr)(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	authAPI.HandleFunc("/api/admin/notifications/test", authMgr.RequireAuth(HandleTestChannel(alertMgr)))
+	authAPI.HandleFunc("/api/admin/notifications/attempts", authMgr.RequireAuth(HandleListNotificationAttempts()))
 	authAPI.HandleFunc("/api/admin/status-alerts", authMgr.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
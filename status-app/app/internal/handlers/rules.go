@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"status/app/internal/alerts"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/registry"
+	"strconv"
+)
+
+// HandleListAlertRules returns all alert routing rules
+func HandleListAlertRules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := database.ListAlertRules()
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"rules": rules})
+	}
+}
+
+// decodeAlertRule reads and validates an alert rule from the request
+func decodeAlertRule(w http.ResponseWriter, r *http.Request, reg *registry.Registry) (*models.AlertRule, bool) {
+	var rule models.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	for _, key := range rule.Services {
+		if reg.Get(key) == nil {
+			http.Error(w, "unknown service "+key, http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	channels, err := database.ListNotificationChannels()
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	known := map[int64]bool{0: true} // email
+	for _, ch := range channels {
+		known[ch.ID] = true
+	}
+	for _, step := range rule.Steps {
+		for _, id := range step.Channels {
+			if !known[id] {
+				http.Error(w, fmt.Sprintf("unknown notification channel %d", id), http.StatusBadRequest)
+				return nil, false
+			}
+		}
+	}
+	return &rule, true
+}
+
+// HandleCreateAlertRule adds an alert routing rule
+func HandleCreateAlertRule(reg *registry.Registry, alertMgr *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := decodeAlertRule(w, r, reg)
+		if !ok {
+			return
+		}
+		id, err := database.InsertAlertRule(rule)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := alertMgr.ReloadRules(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "success": true})
+	}
+}
+
+// HandleUpdateAlertRule replaces an alert routing rule, identified by the id in the body
+func HandleUpdateAlertRule(reg *registry.Registry, alertMgr *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := decodeAlertRule(w, r, reg)
+		if !ok {
+			return
+		}
+		if rule.ID == 0 {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		if err := database.UpdateAlertRule(rule); err != nil {
+			if errors.Is(err, database.ErrRuleNotFound) {
+				http.Error(w, "unknown alert rule", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := alertMgr.ReloadRules(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	}
+}
+
+// HandleDeleteAlertRule removes an alert routing rule (?id=)
+func HandleDeleteAlertRule(alertMgr *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := database.DeleteAlertRule(id); err != nil {
+			if errors.Is(err, database.ErrRuleNotFound) {
+				http.Error(w, "unknown alert rule", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if err := alertMgr.ReloadRules(); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"deleted": id})
+	}
+}
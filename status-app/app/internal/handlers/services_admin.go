@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"status/app/internal/checker"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"status/app/internal/registry"
+	"strings"
+	"time"
+)
+
+// serviceView is the admin API representation of a service definition
+type serviceView struct {
+	Key            string              `json:"key"`
+	Label          string              `json:"label"`
+	URL            string              `json:"url"`
+	TimeoutSeconds int                 `json:"timeout_seconds"`
+	IntervalSecs   int                 `json:"interval_seconds"`
+	JitterSecs     int                 `json:"jitter_seconds"`
+	Retries        int                 `json:"retries"`
+	RetryDelaySecs int                 `json:"retry_delay_seconds"`
+	MinOK          int                 `json:"min_ok"`
+	MaxOK          int                 `json:"max_ok"`
+	SortOrder      int                 `json:"sort_order"`
+	Source         string              `json:"source"`
+	Disabled       bool                `json:"disabled"`
+	Assertions     []models.Assertion  `json:"assertions"`
+	HTTP           models.HTTPOptions  `json:"http"`
+	Policy         models.StatusPolicy `json:"policy"`
+	// Secrets are write-only: responses replace every value with
+	// secretMask, and sending secretMask back keeps the stored value
+	Secrets *models.ServiceSecrets `json:"secrets,omitempty"`
+}
+
+// secretMask stands in for stored secret values in admin API responses
+const secretMask = "********"
+
+func newServiceView(s *models.Service) serviceView {
+	return serviceView{
+		Key:            s.Key,
+		Label:          s.Label,
+		URL:            s.URL,
+		TimeoutSeconds: int(s.Timeout.Seconds()),
+		IntervalSecs:   int(s.Interval.Seconds()),
+		JitterSecs:     int(s.Jitter.Seconds()),
+		Retries:        s.Retries,
+		RetryDelaySecs: int(s.RetryDelay.Seconds()),
+		MinOK:          s.MinOK,
+		MaxOK:          s.MaxOK,
+		SortOrder:      s.SortOrder,
+		Source:         s.Source,
+		Disabled:       s.Disabled,
+		Assertions:     s.Assertions,
+		HTTP:           s.HTTP,
+		Policy:         s.Policy,
+		Secrets:        maskSecrets(s.Secrets),
+	}
+}
+
+// maskSecrets hides secret values while still showing which are set
+func maskSecrets(sec models.ServiceSecrets) *models.ServiceSecrets {
+	if sec.IsZero() {
+		return nil
+	}
+	out := &models.ServiceSecrets{}
+	if sec.Password != "" {
+		out.Password = secretMask
+	}
+	if sec.Token != "" {
+		out.Token = secretMask
+	}
+	if len(sec.Headers) > 0 {
+		out.Headers = make(map[string]string, len(sec.Headers))
+		for name := range sec.Headers {
+			out.Headers[name] = secretMask
+		}
+	}
+	return out
+}
+
+// mergeSecrets resolves the secrets sent in a request against the stored
+// ones. Omitting secrets keeps them all; masked values keep the stored value.
+func mergeSecrets(in *models.ServiceSecrets, stored models.ServiceSecrets) models.ServiceSecrets {
+	if in == nil {
+		return stored
+	}
+	out := models.ServiceSecrets{Password: in.Password, Token: in.Token}
+	if out.Password == secretMask {
+		out.Password = stored.Password
+	}
+	if out.Token == secretMask {
+		out.Token = stored.Token
+	}
+	for name, value := range in.Headers {
+		if value == secretMask {
+			value = stored.Headers[name]
+		}
+		if value == "" {
+			continue
+		}
+		if out.Headers == nil {
+			out.Headers = map[string]string{}
+		}
+		out.Headers[name] = value
+	}
+	return out
+}
+
+// toService converts the request into a validated service model, taking
+// secrets not resent by the client from the stored definition, if any
+func (v serviceView) toService(stored *models.Service) (*models.Service, error) {
+	var storedSecrets models.ServiceSecrets
+	if stored != nil {
+		storedSecrets = stored.Secrets
+	}
+	s := &models.Service{
+		Key:        v.Key,
+		Label:      v.Label,
+		URL:        v.URL,
+		Timeout:    time.Duration(v.TimeoutSeconds) * time.Second,
+		Interval:   time.Duration(v.IntervalSecs) * time.Second,
+		Jitter:     time.Duration(v.JitterSecs) * time.Second,
+		Retries:    v.Retries,
+		RetryDelay: time.Duration(v.RetryDelaySecs) * time.Second,
+		MinOK:      v.MinOK,
+		MaxOK:      v.MaxOK,
+		Assertions: v.Assertions,
+		HTTP:       v.HTTP,
+		Policy:     v.Policy,
+		Secrets:    mergeSecrets(v.Secrets, storedSecrets),
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checker.Validate(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// HandleListServices returns all service definitions
+func HandleListServices(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := []serviceView{}
+		for _, s := range reg.List() {
+			out = append(out, newServiceView(s))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"services": out})
+	}
+}
+
+// HandleCreateService adds a new service to the registry
+func HandleCreateService(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req serviceView
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		svc, err := req.toService(nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if reg.Get(svc.Key) != nil {
+			http.Error(w, "service already exists", http.StatusConflict)
+			return
+		}
+
+		order, err := database.NextServiceSortOrder()
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		svc.SortOrder = order
+		if err := database.InsertService(svc); err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		reloadRegistry(reg)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "service": newServiceView(svc)})
+	}
+}
+
+// HandleUpdateService replaces the definition of an existing service
+func HandleUpdateService(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req serviceView
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		svc, err := req.toService(reg.Get(strings.TrimSpace(req.Key)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rejectFileManaged(w, reg, svc.Key) {
+			return
+		}
+
+		if err := database.UpdateService(svc); err != nil {
+			if errors.Is(err, database.ErrServiceNotFound) {
+				http.Error(w, "unknown service", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		reloadRegistry(reg)
+
+		if updated := reg.Get(svc.Key); updated != nil {
+			svc = updated
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "service": newServiceView(svc)})
+	}
+}
+
+// HandleDeleteService removes a service by key
+func HandleDeleteService(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "key required", http.StatusBadRequest)
+			return
+		}
+		if rejectFileManaged(w, reg, key) {
+			return
+		}
+
+		if err := database.DeleteService(key); err != nil {
+			if errors.Is(err, database.ErrServiceNotFound) {
+				http.Error(w, "unknown service", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		reloadRegistry(reg)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true})
+	}
+}
+
+// HandleReorderServices sets the display order of all services
+func HandleReorderServices(reg *registry.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Keys []string `json:"keys"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Keys) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if err := database.ReorderServices(req.Keys); err != nil {
+			switch {
+			case errors.Is(err, database.ErrServiceNotFound):
+				http.Error(w, "unknown service", http.StatusNotFound)
+			case errors.Is(err, database.ErrInvalidOrder):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "server error", http.StatusInternalServerError)
+			}
+			return
+		}
+		reloadRegistry(reg)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "keys": req.Keys})
+	}
+}
+
+// rejectFileManaged refuses edits to services owned by the config file, since
+// the next reload of the file would silently undo them
+func rejectFileManaged(w http.ResponseWriter, reg *registry.Registry, key string) bool {
+	if s := reg.Get(key); s != nil && s.Source == models.ServiceSourceFile {
+		http.Error(w, "service is managed by the config file", http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+// reloadRegistry refreshes the in-memory registry after a write. The write has
+// already been committed, so a reload failure is logged rather than returned.
+func reloadRegistry(reg *registry.Registry) {
+	if err := reg.Reload(); err != nil {
+		log.Printf("services: reload registry: %v", err)
+	}
+}
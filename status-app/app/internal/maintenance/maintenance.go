@@ -0,0 +1,190 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+)
+
+// Schedule holds the maintenance windows and answers which of them are in
+// effect. It is shared by the scheduler, the alert paths and the HTTP
+// handlers, and reloaded when windows change through the admin API.
+//
+// Recurring windows are evaluated in the server's local time zone, so a
+// weekly Sunday 23:00 window stays at 23:00 across daylight saving changes.
+// Cron expressions can name another zone with a CRON_TZ= prefix.
+type Schedule struct {
+	mu      sync.RWMutex
+	windows []*window
+}
+
+// window is a maintenance window with its parsed recurrence
+type window struct {
+	*models.MaintenanceWindow
+	duration time.Duration
+	// next returns the start of the first occurrence strictly after t, or
+	// the zero time when there are no more occurrences
+	next func(t time.Time) time.Time
+}
+
+// New creates a schedule and loads the windows from the database
+func New() (*Schedule, error) {
+	s := &Schedule{}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the maintenance windows from the database. Windows whose
+// recurrence no longer parses are skipped.
+func (s *Schedule) Reload() error {
+	list, err := database.ListMaintenanceWindows()
+	if err != nil {
+		return err
+	}
+	windows := make([]*window, 0, len(list))
+	for _, m := range list {
+		w, err := compile(m)
+		if err != nil {
+			continue
+		}
+		windows = append(windows, w)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.windows = windows
+	return nil
+}
+
+// Validate checks a window definition including its recurrence rule
+func Validate(m *models.MaintenanceWindow) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	_, err := compile(m)
+	return err
+}
+
+func compile(m *models.MaintenanceWindow) (*window, error) {
+	w := &window{MaintenanceWindow: m, duration: time.Duration(m.DurationMinutes) * time.Minute}
+	start := m.StartsAt.In(time.Local)
+
+	switch {
+	case m.RRule != "":
+		opt, err := rrule.StrToROptionInLocation(m.RRule, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rrule: %v", err)
+		}
+		opt.Dtstart = start
+		r, err := rrule.NewRRule(*opt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rrule: %v", err)
+		}
+		w.next = func(t time.Time) time.Time { return r.After(t, false) }
+
+	case m.Cron != "":
+		sched, err := cron.ParseStandard(m.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %v", err)
+		}
+		w.next = func(t time.Time) time.Time {
+			// No occurrences before the window starts; Next rounds up to
+			// the second, so this lets the start itself match
+			if t.Before(start) {
+				t = start.Add(-time.Nanosecond)
+			}
+			return sched.Next(t.In(time.Local))
+		}
+
+	default:
+		w.next = func(t time.Time) time.Time {
+			if start.After(t) {
+				return start
+			}
+			return time.Time{}
+		}
+	}
+
+	// A rule without any occurrence from its start on is almost certainly
+	// a mistake, e.g. an UNTIL or cron date in the past
+	if w.next(start.Add(-time.Nanosecond)).IsZero() && m.RRule+m.Cron != "" {
+		return nil, errors.New("recurrence has no occurrences")
+	}
+	return w, nil
+}
+
+// current returns the occurrence of w in effect at t
+func (w *window) current(t time.Time) (start time.Time, ok bool) {
+	// The occurrence that started last within one duration before t
+	start = w.next(t.Add(-w.duration))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// Active returns the maintenance window in effect for a service at t, or
+// nil if there is none
+func (s *Schedule) Active(key string, t time.Time) *models.MaintenanceWindow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, w := range s.windows {
+		if !w.Covers(key) {
+			continue
+		}
+		if _, ok := w.current(t); ok {
+			return w.MaintenanceWindow
+		}
+	}
+	return nil
+}
+
+// Occurrences returns the ongoing occurrence of every window plus the next
+// one starting within horizon, earliest first. Service labels are left for
+// the caller to fill in.
+func (s *Schedule) Occurrences(now time.Time, horizon time.Duration) []models.MaintenanceOccurrence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := []models.MaintenanceOccurrence{}
+	for _, w := range s.windows {
+		add := func(start time.Time, ongoing bool) {
+			occ := models.MaintenanceOccurrence{
+				WindowID: w.ID,
+				Title:    w.Title,
+				Message:  w.Message,
+				Services: []models.IncidentService{},
+				StartsAt: start.UTC(),
+				EndsAt:   start.Add(w.duration).UTC(),
+				Ongoing:  ongoing,
+			}
+			for _, key := range w.Services {
+				occ.Services = append(occ.Services, models.IncidentService{Key: key})
+			}
+			out = append(out, occ)
+		}
+
+		after := now
+		if start, ok := w.current(now); ok {
+			add(start, true)
+			after = start.Add(w.duration)
+		}
+		if start := w.next(after); !start.IsZero() && start.Before(now.Add(horizon)) {
+			add(start, false)
+		}
+	}
+	slices.SortFunc(out, func(a, b models.MaintenanceOccurrence) int {
+		return a.StartsAt.Compare(b.StartsAt)
+	})
+	return out
+}
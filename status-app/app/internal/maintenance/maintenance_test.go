@@ -0,0 +1,120 @@
+package maintenance
+
+import (
+	"status/app/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useLocal evaluates windows in the named zone for the rest of the test
+func useLocal(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s: %v", name, err)
+	}
+	prev := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = prev })
+	return loc
+}
+
+func TestCompile(t *testing.T) {
+	ny := useLocal(t, "America/New_York")
+	start := time.Date(2026, 3, 1, 23, 0, 0, 0, ny)
+	tests := []struct {
+		name    string
+		rrule   string
+		cron    string
+		start   time.Time
+		wantErr string
+	}{
+		{name: "one-off", start: start},
+		{name: "one-off in the past", start: time.Date(2020, 1, 1, 0, 0, 0, 0, ny)},
+		{name: "weekly rrule", rrule: "FREQ=WEEKLY;BYDAY=SU", start: start},
+		{name: "rrule with UNTIL ahead", rrule: "FREQ=DAILY;UNTIL=20260310T000000Z", start: start},
+		{name: "rrule with UNTIL in the past", rrule: "FREQ=DAILY;UNTIL=20200101T000000Z", start: start, wantErr: "no occurrences"},
+		{name: "rrule with COUNT", rrule: "FREQ=MONTHLY;COUNT=3", start: start},
+		{name: "bad rrule frequency", rrule: "FREQ=SOMETIMES", start: start, wantErr: "invalid rrule"},
+		{name: "bad rrule part", rrule: "FREQ=DAILY;BYHOUR=25", start: start, wantErr: "invalid rrule"},
+		{name: "cron", cron: "0 23 * * 0", start: start},
+		{name: "cron with a zone", cron: "CRON_TZ=Europe/Berlin 0 3 * * *", start: start},
+		{name: "cron descriptor", cron: "@daily", start: start},
+		{name: "cron out of range", cron: "61 * * * *", start: start, wantErr: "invalid cron expression"},
+		{name: "cron with too few fields", cron: "0 23 *", start: start, wantErr: "invalid cron expression"},
+		{name: "cron with an unknown zone", cron: "CRON_TZ=Mars/Olympus 0 3 * * *", start: start, wantErr: "invalid cron expression"},
+		{name: "cron date that never comes", cron: "0 0 30 2 *", start: start, wantErr: "no occurrences"},
+	}
+	for _, tt := range tests {
+		_, err := compile(&models.MaintenanceWindow{StartsAt: tt.start, DurationMinutes: 60, RRule: tt.rrule, Cron: tt.cron})
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: compile: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: compile error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	ny := useLocal(t, "America/New_York")
+	local := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, ny)
+	}
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.UTC)
+	}
+	// Daylight saving time starts on 8 March 2026 and ends on 1 November in
+	// New York, moving local 23:00 from 04:00 to 03:00 UTC and back
+	weekly := &models.MaintenanceWindow{StartsAt: local(3, 1, 23, 0), DurationMinutes: 120, RRule: "FREQ=WEEKLY;BYDAY=SU"}
+	weeklyCron := &models.MaintenanceWindow{StartsAt: local(3, 1, 23, 0), DurationMinutes: 120, Cron: "0 23 * * 0"}
+
+	tests := []struct {
+		name      string
+		window    *models.MaintenanceWindow
+		at        time.Time
+		wantStart time.Time // zero when no occurrence is in effect
+	}{
+		{"one-off before", &models.MaintenanceWindow{StartsAt: local(3, 10, 12, 0), DurationMinutes: 60}, local(3, 10, 11, 59), time.Time{}},
+		{"one-off at its start", &models.MaintenanceWindow{StartsAt: local(3, 10, 12, 0), DurationMinutes: 60}, local(3, 10, 12, 0), local(3, 10, 12, 0)},
+		{"one-off ending", &models.MaintenanceWindow{StartsAt: local(3, 10, 12, 0), DurationMinutes: 60}, local(3, 10, 12, 59), local(3, 10, 12, 0)},
+		{"one-off ended", &models.MaintenanceWindow{StartsAt: local(3, 10, 12, 0), DurationMinutes: 60}, local(3, 10, 13, 0), time.Time{}},
+
+		{"rrule before its start", weekly, local(2, 22, 23, 30), time.Time{}},
+		{"rrule first occurrence", weekly, local(3, 1, 23, 0), local(3, 1, 23, 0)},
+		{"rrule past midnight", weekly, local(3, 2, 0, 59), local(3, 1, 23, 0)},
+		{"rrule between occurrences", weekly, local(3, 4, 23, 30), time.Time{}},
+		{"rrule in standard time", weekly, utc(3, 2, 4, 30), local(3, 1, 23, 0)},
+		{"rrule keeps local time after DST starts", weekly, utc(3, 9, 3, 30), utc(3, 9, 3, 0)},
+		{"rrule not an hour late after DST starts", weekly, utc(3, 9, 2, 30), time.Time{}},
+		{"rrule ends an hour earlier in UTC", weekly, utc(3, 9, 5, 0), time.Time{}},
+		{"rrule keeps local time after DST ends", weekly, utc(11, 2, 4, 30), utc(11, 2, 4, 0)},
+		{"rrule not an hour early after DST ends", weekly, utc(11, 2, 3, 30), time.Time{}},
+
+		{"rrule with UNTIL, last occurrence", &models.MaintenanceWindow{StartsAt: local(3, 1, 9, 0), DurationMinutes: 30, RRule: "FREQ=DAILY;UNTIL=20260305T235959Z"}, local(3, 5, 9, 10), local(3, 5, 9, 0)},
+		{"rrule with UNTIL, after it", &models.MaintenanceWindow{StartsAt: local(3, 1, 9, 0), DurationMinutes: 30, RRule: "FREQ=DAILY;UNTIL=20260305T235959Z"}, local(3, 6, 9, 10), time.Time{}},
+		{"rrule with COUNT, after the last", &models.MaintenanceWindow{StartsAt: local(3, 1, 9, 0), DurationMinutes: 30, RRule: "FREQ=DAILY;COUNT=2"}, local(3, 3, 9, 10), time.Time{}},
+
+		{"cron before its start", weeklyCron, local(2, 22, 23, 30), time.Time{}},
+		{"cron first occurrence", weeklyCron, local(3, 1, 23, 0), local(3, 1, 23, 0)},
+		{"cron between occurrences", weeklyCron, local(3, 4, 23, 30), time.Time{}},
+		{"cron keeps local time after DST starts", weeklyCron, utc(3, 9, 3, 30), utc(3, 9, 3, 0)},
+		{"cron not an hour late after DST starts", weeklyCron, utc(3, 9, 2, 30), time.Time{}},
+		{"cron keeps local time after DST ends", weeklyCron, utc(11, 2, 4, 30), utc(11, 2, 4, 0)},
+		{"cron in another zone", &models.MaintenanceWindow{StartsAt: local(3, 1, 0, 0), DurationMinutes: 60, Cron: "CRON_TZ=UTC 0 2 * * *"}, utc(3, 10, 2, 30), utc(3, 10, 2, 0)},
+		{"cron in another zone ignores local DST", &models.MaintenanceWindow{StartsAt: local(3, 1, 0, 0), DurationMinutes: 60, Cron: "CRON_TZ=UTC 0 2 * * *"}, utc(3, 5, 2, 30), utc(3, 5, 2, 0)},
+		{"cron starting mid-occurrence", &models.MaintenanceWindow{StartsAt: local(3, 1, 23, 30), DurationMinutes: 120, Cron: "0 23 * * 0"}, local(3, 1, 23, 45), time.Time{}},
+	}
+	for _, tt := range tests {
+		w, err := compile(tt.window)
+		if err != nil {
+			t.Errorf("%s: compile: %v", tt.name, err)
+			continue
+		}
+		start, ok := w.current(tt.at)
+		if ok != !tt.wantStart.IsZero() || !start.Equal(tt.wantStart) {
+			t.Errorf("%s: current(%v) = %v, %v, want %v", tt.name, tt.at, start, ok, tt.wantStart)
+		}
+	}
+}
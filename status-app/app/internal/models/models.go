@@ -1,32 +1,226 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
 
 // Service represents a monitored service
 type Service struct {
-	Key                 string
-	Label               string
-	URL                 string
-	Timeout             time.Duration
-	MinOK               int
-	MaxOK               int
-	Disabled            bool `json:"disabled"`
-	ConsecutiveFailures int  // Track consecutive check failures
+	Key        string
+	Label      string
+	URL        string
+	Timeout    time.Duration
+	Interval   time.Duration // time between checks; 0 uses the global poll interval
+	Jitter     time.Duration // maximum random delay added to each interval; 0 uses 10% of the interval
+	Retries    int           // extra attempts within a check before it counts as failed
+	RetryDelay time.Duration // pause between attempts; 0 uses the default
+	MinOK      int
+	MaxOK      int
+	SortOrder  int
+	Source     string // ServiceSourceAPI or ServiceSourceFile
+	Assertions []Assertion
+	HTTP       HTTPOptions
+	Secrets    ServiceSecrets
+	Policy     StatusPolicy
+	Disabled   bool `json:"disabled"`
 }
 
+// Assertion is a check applied to an HTTP response body, e.g.
+// {Type: "jsonpath", Value: "$.commitTag != null"}
+type Assertion struct {
+	Type  string `json:"type" yaml:"type"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// HTTPOptions customizes the request made by http(s):// checks
+type HTTPOptions struct {
+	Method    string            `json:"method,omitempty" yaml:"method"`       // defaults to GET
+	Headers   map[string]string `json:"headers,omitempty" yaml:"headers"`     // non-secret request headers
+	Body      string            `json:"body,omitempty" yaml:"body"`           // request body, e.g. for POST health endpoints
+	Auth      string            `json:"auth,omitempty" yaml:"auth"`           // "", "basic" or "bearer"
+	Username  string            `json:"username,omitempty" yaml:"username"`   // basic auth user; the password is a secret
+	Redirects string            `json:"redirects,omitempty" yaml:"redirects"` // "follow" (default) or "none"
+}
+
+// ServiceSecrets holds the credentials used by a check. They are stored
+// apart from the service definition and never returned by the admin API.
+type ServiceSecrets struct {
+	Password string            `json:"password,omitempty" yaml:"password"` // basic auth password
+	Token    string            `json:"token,omitempty" yaml:"token"`       // bearer token
+	Headers  map[string]string `json:"headers,omitempty" yaml:"headers"`   // secret headers such as X-Api-Key or X-Plex-Token
+}
+
+// IsZero reports whether no secrets are set
+func (s ServiceSecrets) IsZero() bool {
+	return s.Password == "" && s.Token == "" && len(s.Headers) == 0
+}
+
+// DefaultInterval is the check interval of services without their own.
+// The configuration sets it from POLL_SECONDS.
+var DefaultInterval = 60 * time.Second
+
+// Status policy defaults
+const (
+	DefaultFailureThreshold  = 2
+	DefaultRecoveryThreshold = 1
+	DefaultDegradedMS        = 200
+)
+
+// StatusPolicy decides how check results turn into the reported status of a
+// service. Zero values use the defaults, except for an explicit DegradedMS
+// of 0, which turns the response time check off.
+type StatusPolicy struct {
+	FailureThreshold  int  `json:"failure_threshold,omitempty" yaml:"failure_threshold"`   // failed checks in a row before the service is down
+	RecoveryThreshold int  `json:"recovery_threshold,omitempty" yaml:"recovery_threshold"` // passing checks in a row before a down service is up again
+	DegradedMS        *int `json:"degraded_ms,omitempty" yaml:"degraded_ms"`               // response time above which the service is degraded
+	// The service is also degraded when at least DegradedFailPct percent of
+	// the last DegradedWindow checks failed; both unset disables this
+	DegradedFailPct int `json:"degraded_fail_pct,omitempty" yaml:"degraded_fail_pct"`
+	DegradedWindow  int `json:"degraded_window,omitempty" yaml:"degraded_window"`
+}
+
+// WithDefaults returns the policy with unset values filled in
+func (p StatusPolicy) WithDefaults() StatusPolicy {
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = DefaultFailureThreshold
+	}
+	if p.RecoveryThreshold <= 0 {
+		p.RecoveryThreshold = DefaultRecoveryThreshold
+	}
+	if p.DegradedMS == nil {
+		ms := DefaultDegradedMS
+		p.DegradedMS = &ms
+	}
+	return p
+}
+
+// Equal reports whether two policies have the same settings
+func (p StatusPolicy) Equal(q StatusPolicy) bool {
+	sameMS := p.DegradedMS == q.DegradedMS || (p.DegradedMS != nil && q.DegradedMS != nil && *p.DegradedMS == *q.DegradedMS)
+	p.DegradedMS, q.DegradedMS = nil, nil
+	return sameMS && p == q
+}
+
+func (p StatusPolicy) validate() error {
+	if p.FailureThreshold < 0 || p.FailureThreshold > 100 || p.RecoveryThreshold < 0 || p.RecoveryThreshold > 100 {
+		return errors.New("failure and recovery thresholds must be between 1 and 100, or 0 for the default")
+	}
+	if p.DegradedMS != nil && (*p.DegradedMS < 0 || *p.DegradedMS > 60000) {
+		return errors.New("degraded_ms must be between 1 and 60000, or 0 to disable it")
+	}
+	if (p.DegradedFailPct == 0) != (p.DegradedWindow == 0) {
+		return errors.New("degraded_fail_pct and degraded_window must be set together")
+	}
+	if p.DegradedWindow != 0 {
+		if p.DegradedFailPct < 1 || p.DegradedFailPct > 100 {
+			return errors.New("degraded_fail_pct must be between 1 and 100")
+		}
+		if p.DegradedWindow < 1 || p.DegradedWindow > 1000 {
+			return errors.New("degraded_window must be between 1 and 1000 checks")
+		}
+	}
+	return nil
+}
+
+// Service definition sources
+const (
+	ServiceSourceAPI  = "api"  // created through the admin API (or seeded from env)
+	ServiceSourceFile = "file" // managed by the config file
+)
+
 // LiveResult represents the current status of a service
 type LiveResult struct {
-	Label    string `json:"label"`
-	OK       bool   `json:"ok"`
-	Status   int    `json:"status"`
-	MS       *int   `json:"ms,omitempty"`
-	Disabled bool   `json:"disabled"`
-	Degraded bool   `json:"degraded"`
+	Label    string         `json:"label"`
+	OK       bool           `json:"ok"`
+	Status   int            `json:"status"`
+	MS       *int           `json:"ms,omitempty"`
+	Disabled bool           `json:"disabled"`
+	Degraded bool           `json:"degraded"`
+	Message  string         `json:"message,omitempty"`
+	Meta     map[string]any `json:"meta,omitempty"`
+	Phases   *LatencyPhases `json:"phases,omitempty"`
+
+	Attempts      int      `json:"attempts,omitempty"`
+	AttemptErrors []string `json:"attempt_errors,omitempty"`
+
+	Pending     bool       `json:"pending,omitempty"`     // not checked since startup
+	CheckedAt   *time.Time `json:"checked_at,omitempty"`  // when the result was taken
+	AgeSeconds  *int       `json:"age_seconds,omitempty"` // seconds since CheckedAt
+	Maintenance bool       `json:"maintenance,omitempty"` // in a maintenance window
+}
+
+// Sample is a single recorded check of a service
+type Sample struct {
+	TakenAt     time.Time
+	ServiceKey  string
+	OK          bool   // service status after failure tolerance
+	CheckStatus string // raw probe outcome: up, degraded or down
+	HTTPStatus  int
+	MS          *int
+	Message     string
+	Meta        map[string]any // probe-specific details, stored as JSON
+	Phases      *LatencyPhases // HTTP latency breakdown, nil for other probes
+	// Attempts is how many tries the check took; with a passing CheckStatus
+	// and more than one attempt the service only passed after a retry
+	Attempts      int
+	AttemptErrors []string // why each failed attempt failed
+	Maintenance   bool     // taken during a maintenance window, left out of uptime
+}
+
+// MetricBucket aggregates the samples of one service over an hour or a day
+type MetricBucket struct {
+	ServiceKey  string
+	Bucket      string // UTC start: 2006-01-02T15:00:00Z for hours, 2006-01-02 for days
+	Up          int    // passing samples outside maintenance
+	Total       int    // samples outside maintenance
+	Maintenance int    // samples taken during maintenance
+	// Latency of the samples that have one, in milliseconds; nil when none do
+	AvgMS, MinMS, MaxMS, P95MS *float64
+	Phases                     *LatencyPhases // average HTTP latency phases
+}
+
+// LatencyPhases breaks down the latency of an HTTP check in milliseconds.
+// DNS, connect and TLS cover setting up the connection, TTFB runs from the
+// connection being ready to the first response byte (server time) and
+// transfer from there to the end of the body.
+type LatencyPhases struct {
+	DNS      float64 `json:"dns_ms"`
+	Connect  float64 `json:"connect_ms"`
+	TLS      float64 `json:"tls_ms"`
+	TTFB     float64 `json:"ttfb_ms"`
+	Transfer float64 `json:"transfer_ms"`
+}
+
+// PingStats summarizes the echo replies of an icmp:// check
+type PingStats struct {
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+	LossPct  float64 `json:"loss_pct"`
+	MinMS    float64 `json:"min_ms"`
+	AvgMS    float64 `json:"avg_ms"`
+	MaxMS    float64 `json:"max_ms"`
+}
+
+// CertInfo describes the certificate presented by a tls:// service
+type CertInfo struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	DNSNames  []string  `json:"dns_names"`
+	ExpiresAt time.Time `json:"expires_at"`
+	DaysLeft  int       `json:"days_left"`
 }
 
 // LivePayload represents a collection of service statuses
 type LivePayload struct {
 	T      time.Time             `json:"t"`
+	Order  []string              `json:"order"`
 	Status map[string]LiveResult `json:"status"`
 }
 
@@ -42,6 +236,300 @@ type AlertConfig struct {
 	AlertOnDown     bool   `json:"alert_on_down"`
 	AlertOnDegraded bool   `json:"alert_on_degraded"`
 	AlertOnUp       bool   `json:"alert_on_up"`
+	ReminderMinutes int    `json:"reminder_minutes"` // repeat the down alert this often until acknowledged, 0 for never
+}
+
+// DefaultAlertConfig is the alert configuration used until one is saved
+func DefaultAlertConfig() *AlertConfig {
+	return &AlertConfig{SMTPPort: 587, AlertOnDown: true, AlertOnDegraded: true}
+}
+
+// Notification channel types
+const (
+	ChannelWebhook  = "webhook"
+	ChannelDiscord  = "discord"
+	ChannelSlack    = "slack"
+	ChannelTelegram = "telegram"
+	ChannelNtfy     = "ntfy"
+	ChannelGotify   = "gotify"
+	ChannelPushover = "pushover"
+	ChannelMatrix   = "matrix"
+)
+
+// ChannelTypes lists the valid notification channel types. Email is
+// configured separately through AlertConfig.
+var ChannelTypes = []string{ChannelWebhook, ChannelDiscord, ChannelSlack, ChannelTelegram, ChannelNtfy, ChannelGotify, ChannelPushover, ChannelMatrix}
+
+// NotificationChannel is a destination alerts are sent to besides email
+type NotificationChannel struct {
+	ID       int64           `json:"id"`
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Enabled  bool            `json:"enabled"`
+	Settings ChannelSettings `json:"settings"`
+}
+
+// ChannelSettings holds the settings of all channel types; each type uses
+// only some of them
+type ChannelSettings struct {
+	URL    string `json:"url,omitempty"`     // webhook, Discord and Slack URL to post to
+	Server string `json:"server,omitempty"`  // API base URL of Telegram, ntfy, Gotify, Pushover or Matrix
+	Token  string `json:"token,omitempty"`   // bot, application or access token
+	User   string `json:"user,omitempty"`    // Pushover user key
+	ChatID string `json:"chat_id,omitempty"` // Telegram chat
+	Topic  string `json:"topic,omitempty"`   // ntfy topic
+	RoomID string `json:"room_id,omitempty"` // Matrix room
+
+	// Generic webhooks only
+	Secret   string            `json:"secret,omitempty"`   // HMAC-SHA256 key signing the body
+	Headers  map[string]string `json:"headers,omitempty"`  // extra request headers
+	Template string            `json:"template,omitempty"` // text/template replacing the JSON event body
+}
+
+// Alert severities routing rules can match, named after the status a
+// service changed to
+const (
+	SeverityDown     = "down"
+	SeverityDegraded = "degraded"
+	SeverityUp       = "up"
+)
+
+// Severities lists the valid alert severities
+var Severities = []string{SeverityDown, SeverityDegraded, SeverityUp}
+
+// AlertRule routes the alerts of some services and severities to specific
+// channels and recipients instead of everywhere, and escalates them while
+// the service stays down or degraded
+type AlertRule struct {
+	ID         int64            `json:"id"`
+	Name       string           `json:"name"`
+	Enabled    bool             `json:"enabled"`
+	Services   []string         `json:"services"`   // service keys, empty for all services
+	Severities []string         `json:"severities"` // empty for all severities
+	Steps      []EscalationStep `json:"steps"`
+}
+
+// EscalationStep is who a rule notifies and when
+type EscalationStep struct {
+	AfterMinutes int      `json:"after_minutes"` // 0 on the change, otherwise once the status has lasted this long
+	Channels     []int64  `json:"channels"`      // notification channel ids, 0 for email
+	Recipients   []string `json:"recipients"`    // email addresses replacing the alert email
+}
+
+// Matches reports whether the rule applies to an alert
+func (r *AlertRule) Matches(serviceKey, severity string) bool {
+	return r.Enabled &&
+		(len(r.Services) == 0 || slices.Contains(r.Services, serviceKey)) &&
+		(len(r.Severities) == 0 || slices.Contains(r.Severities, severity))
+}
+
+// Validate checks the fields of a rule. That its services and channels exist
+// is checked by the caller.
+func (r *AlertRule) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("name required")
+	}
+	for _, key := range r.Services {
+		if !serviceKeyRe.MatchString(key) {
+			return errors.New("invalid service key " + key)
+		}
+	}
+	for _, sev := range r.Severities {
+		if !slices.Contains(Severities, sev) {
+			return errors.New("unknown severity " + sev)
+		}
+	}
+	if len(r.Steps) == 0 || len(r.Steps) > 10 {
+		return errors.New("a rule needs 1 to 10 steps")
+	}
+	onlyUp := len(r.Severities) > 0 && !slices.Contains(r.Severities, SeverityDown) && !slices.Contains(r.Severities, SeverityDegraded)
+	for i := range r.Steps {
+		step := &r.Steps[i]
+		if step.AfterMinutes < 0 || step.AfterMinutes > 7*24*60 {
+			return errors.New("after_minutes must be between 0 and 7 days")
+		}
+		if step.AfterMinutes > 0 && onlyUp {
+			return errors.New("only down and degraded alerts can escalate")
+		}
+		if len(step.Channels) == 0 && len(step.Recipients) == 0 {
+			return errors.New("each step needs a channel or recipient")
+		}
+		for j, addr := range step.Recipients {
+			a, err := mail.ParseAddress(strings.TrimSpace(addr))
+			if err != nil {
+				return errors.New("invalid recipient " + addr)
+			}
+			step.Recipients[j] = a.Address
+		}
+	}
+	return nil
+}
+
+// Notification delivery statuses
+const (
+	DeliveryPending = "pending" // queued or waiting for a retry
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed" // given up
+)
+
+// OutboxEntry is a notification queued for delivery to one channel
+type OutboxEntry struct {
+	ID          int64
+	ChannelID   int64    // 0 for email
+	Channel     string   // channel name when queued
+	Recipients  []string // email addresses replacing the alert email, if any
+	Event       string
+	ServiceKey  string
+	Payload     string // the notification, as JSON
+	Status      string
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+	CreatedAt   time.Time
+}
+
+// NotificationAttempt is one attempt to deliver a queued notification
+type NotificationAttempt struct {
+	ID          int64     `json:"id"`
+	OutboxID    int64     `json:"outbox_id"` // 0 for notifications sent directly, such as tests
+	Channel     string    `json:"channel"`
+	Event       string    `json:"event"`
+	ServiceKey  string    `json:"service_key"`
+	Attempt     int       `json:"attempt"`         // 1 for the first try
+	Status      string    `json:"status"`          // sent or failed
+	Error       string    `json:"error,omitempty"` // why the attempt failed
+	AttemptedAt time.Time `json:"attempted_at"`
+	Delivery    string    `json:"delivery"` // current status of the notification: pending, sent or failed
+}
+
+// Validate checks the name and type of a channel. The settings each type
+// needs are checked by the notify package.
+func (c *NotificationChannel) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("name required")
+	}
+	if !slices.Contains(ChannelTypes, c.Type) {
+		return errors.New("unknown channel type " + c.Type)
+	}
+	return nil
+}
+
+// Incident statuses, in the order an incident usually moves through them
+const (
+	IncidentInvestigating = "investigating"
+	IncidentIdentified    = "identified"
+	IncidentMonitoring    = "monitoring"
+	IncidentResolved      = "resolved"
+)
+
+// IncidentStatuses lists the valid incident statuses
+var IncidentStatuses = []string{IncidentInvestigating, IncidentIdentified, IncidentMonitoring, IncidentResolved}
+
+// Incident impact levels
+const (
+	ImpactNone     = "none"
+	ImpactMinor    = "minor"
+	ImpactMajor    = "major"
+	ImpactCritical = "critical"
+)
+
+// IncidentImpacts lists the valid impact levels, least severe first
+var IncidentImpacts = []string{ImpactNone, ImpactMinor, ImpactMajor, ImpactCritical}
+
+// Incident is a period of disruption to one or more services. Automatic
+// incidents are opened and resolved as a service goes down and recovers;
+// admins can open incidents themselves and post updates to any incident.
+type Incident struct {
+	ID              int64             `json:"id"`
+	Title           string            `json:"title"`
+	Status          string            `json:"status"`
+	Impact          string            `json:"impact"`
+	Automatic       bool              `json:"automatic"`
+	ServiceKey      string            `json:"service_key,omitempty"` // the service whose outage opened an automatic incident
+	Services        []IncidentService `json:"services"`              // affected services
+	StartedAt       time.Time         `json:"started_at"`
+	ResolvedAt      *time.Time        `json:"resolved_at"`      // nil while ongoing
+	DurationSeconds int               `json:"duration_seconds"` // so far, for ongoing incidents
+	RootError       string            `json:"root_error,omitempty"`
+	AcknowledgedAt  *time.Time        `json:"acknowledged_at,omitempty"` // stops reminders
+	AcknowledgedBy  string            `json:"acknowledged_by,omitempty"`
+	Updates         []IncidentUpdate  `json:"updates"` // newest first
+}
+
+// IncidentService is a service affected by an incident or maintenance
+type IncidentService struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// IncidentUpdate is a timestamped status update posted to an incident
+type IncidentUpdate struct {
+	ID        int64     `json:"id"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MaintenanceWindow is planned work on some or all services. A one-off
+// window has a single occurrence at StartsAt; a recurring window repeats by
+// its RRULE or cron expression from StartsAt on. Every occurrence lasts
+// DurationMinutes.
+type MaintenanceWindow struct {
+	ID              int64     `json:"id"`
+	Title           string    `json:"title"`
+	Message         string    `json:"message,omitempty"`
+	Services        []string  `json:"services"` // service keys, empty for all services
+	StartsAt        time.Time `json:"starts_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	RRule           string    `json:"rrule,omitempty"` // e.g. FREQ=WEEKLY;BYDAY=SU
+	Cron            string    `json:"cron,omitempty"`  // e.g. 0 23 * * 0
+}
+
+// Covers reports whether the window applies to a service
+func (m *MaintenanceWindow) Covers(key string) bool {
+	return len(m.Services) == 0 || slices.Contains(m.Services, key)
+}
+
+// Validate checks the fields of a window. Recurrence rules are parsed by the
+// maintenance package.
+func (m *MaintenanceWindow) Validate() error {
+	m.Title = strings.TrimSpace(m.Title)
+	m.Message = strings.TrimSpace(m.Message)
+	m.RRule = strings.TrimSpace(m.RRule)
+	m.Cron = strings.TrimSpace(m.Cron)
+
+	if m.Title == "" {
+		return errors.New("title required")
+	}
+	if m.StartsAt.IsZero() {
+		return errors.New("starts_at required")
+	}
+	if m.DurationMinutes < 1 || m.DurationMinutes > 7*24*60 {
+		return errors.New("duration must be between 1 minute and 7 days")
+	}
+	if m.RRule != "" && m.Cron != "" {
+		return errors.New("use either rrule or cron, not both")
+	}
+	for _, key := range m.Services {
+		if !serviceKeyRe.MatchString(key) {
+			return errors.New("invalid service key " + key)
+		}
+	}
+	return nil
+}
+
+// MaintenanceOccurrence is an ongoing or upcoming occurrence of a
+// maintenance window
+type MaintenanceOccurrence struct {
+	WindowID int64             `json:"window_id"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message,omitempty"`
+	Services []IncidentService `json:"services"` // empty for all services
+	StartsAt time.Time         `json:"starts_at"`
+	EndsAt   time.Time         `json:"ends_at"`
+	Ongoing  bool              `json:"ongoing"`
 }
 
 // ResourcesUIConfig stores admin configuration for the Resources section/widgets
@@ -68,6 +556,31 @@ type BlockInfo struct {
 	ExpiresAt string
 }
 
+// IPBlock is a stored record of failed logins from an address, blocked
+// once BlockedAt is set
+type IPBlock struct {
+	IP        string
+	BlockedAt string // empty until the address is blocked
+	Attempts  int
+	ExpiresAt string
+	Reason    string
+}
+
+// StatusHistory is the last recorded status of a service, used to detect
+// transitions for alerting
+type StatusHistory struct {
+	OK       bool
+	Degraded bool
+	Since    time.Time // when the service entered this status, zero if unknown
+}
+
+// FailedSample is a failed check listed in the metrics response
+type FailedSample struct {
+	TakenAt    string `json:"taken_at"`
+	ServiceKey string `json:"service_key"`
+	HTTPStatus int    `json:"http_status"`
+}
+
 // StatusAlert represents a site-wide or service-specific alert banner
 type StatusAlert struct {
 	ID         string `json:"id"`
@@ -76,3 +589,59 @@ type StatusAlert struct {
 	Level      string `json:"level"`
 	CreatedAt  string `json:"created_at"`
 }
+
+var serviceKeyRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Validate checks a service definition and fills in the same defaults the
+// env-based configuration uses for any unset fields
+func (s *Service) Validate() error {
+	s.Key = strings.TrimSpace(s.Key)
+	s.Label = strings.TrimSpace(s.Label)
+	s.URL = strings.TrimSpace(s.URL)
+
+	if !serviceKeyRe.MatchString(s.Key) {
+		return errors.New("key must be lowercase letters, digits, '-' or '_'")
+	}
+	if s.Label == "" {
+		return errors.New("label required")
+	}
+	// The scheme itself is checked against the registered checkers by checker.Validate
+	if u, err := url.Parse(s.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("url must be an absolute URL such as https://host or tcp://host:port")
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = 5 * time.Second
+	}
+	if s.Timeout > time.Minute {
+		return errors.New("timeout must be at most 60 seconds")
+	}
+	if s.Interval < 0 || s.Interval > 24*time.Hour || (s.Interval > 0 && s.Interval < time.Second) {
+		return errors.New("interval must be between 1 second and 24 hours")
+	}
+	interval := s.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	if s.Jitter < 0 || s.Jitter > interval {
+		return fmt.Errorf("jitter must not exceed the interval of %s", interval)
+	}
+	if s.Retries < 0 || s.Retries > 5 {
+		return errors.New("retries must be between 0 and 5")
+	}
+	if s.RetryDelay < 0 || s.RetryDelay > time.Minute {
+		return errors.New("retry delay must be at most 60 seconds")
+	}
+	if err := s.Policy.validate(); err != nil {
+		return err
+	}
+	if s.MinOK == 0 {
+		s.MinOK = 200
+	}
+	if s.MaxOK == 0 {
+		s.MaxOK = 399
+	}
+	if s.MinOK > s.MaxOK {
+		return errors.New("min_ok must not exceed max_ok")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceValidateJitter(t *testing.T) {
+	defer func(d time.Duration) { DefaultInterval = d }(DefaultInterval)
+	DefaultInterval = 30 * time.Second
+
+	tests := []struct {
+		interval, jitter time.Duration
+		err              string
+	}{
+		{interval: time.Minute, jitter: time.Minute},
+		{interval: time.Minute, jitter: time.Minute + time.Second, err: "interval of 1m0s"},
+		{jitter: 30 * time.Second}, // bounded by the default interval
+		{jitter: 45 * time.Second, err: "interval of 30s"},
+		{interval: 2 * time.Minute, jitter: 45 * time.Second},
+		{jitter: -time.Second, err: "jitter must not exceed"},
+	}
+	for _, tt := range tests {
+		s := &Service{Key: "plex", Label: "Plex", URL: "http://plex.lan", Interval: tt.interval, Jitter: tt.jitter}
+		err := s.Validate()
+		if tt.err == "" && err != nil {
+			t.Errorf("interval %v, jitter %v: %v", tt.interval, tt.jitter, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("interval %v, jitter %v: error = %v, want %q", tt.interval, tt.jitter, err, tt.err)
+		}
+	}
+}
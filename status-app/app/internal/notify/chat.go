@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// discord posts notifications as an embed to a Discord webhook
+type discord struct {
+	url string
+}
+
+func (d *discord) Notify(ctx context.Context, n *Notification) error {
+	embed := map[string]any{
+		"title":       n.Title,
+		"description": n.Message,
+		"color":       color(n.Event),
+		"timestamp":   n.Time.UTC().Format(time.RFC3339),
+	}
+	if n.URL != "" {
+		embed["url"] = n.URL
+	}
+	return postJSON(ctx, http.MethodPost, d.url, nil, map[string]any{
+		"username": "Servicarr",
+		"embeds":   []any{embed},
+	})
+}
+
+// slack posts notifications to a Slack incoming webhook
+type slack struct {
+	url string
+}
+
+func (s *slack) Notify(ctx context.Context, n *Notification) error {
+	msg := "*" + slackEscape(n.Title) + "*\n" + slackEscape(n.Message)
+	if n.URL != "" {
+		msg += "\n<" + n.URL + "|Status page>"
+	}
+	return postJSON(ctx, http.MethodPost, s.url, nil, map[string]any{"text": msg})
+}
+
+// slackEscape escapes the characters Slack treats as markup
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+// telegram sends notifications through a Telegram bot
+type telegram struct {
+	server string
+	token  string
+	chatID string
+}
+
+func (t *telegram) Notify(ctx context.Context, n *Notification) error {
+	msg := "<b>" + html.EscapeString(n.Title) + "</b>\n" + html.EscapeString(n.Message)
+	if n.URL != "" {
+		msg += "\n" + html.EscapeString(n.URL)
+	}
+	return postJSON(ctx, http.MethodPost, t.server+"/bot"+t.token+"/sendMessage", nil, map[string]any{
+		"chat_id":                  t.chatID,
+		"text":                     msg,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	})
+}
+
+// matrix sends notifications to a Matrix room through the client API
+type matrix struct {
+	server string
+	token  string
+	roomID string
+}
+
+// matrixTxn makes the transaction ids of this process unique
+var matrixTxn atomic.Int64
+
+func (m *matrix) Notify(ctx context.Context, n *Notification) error {
+	formatted := "<strong>" + html.EscapeString(n.Title) + "</strong><br>" + html.EscapeString(n.Message)
+	if n.URL != "" {
+		formatted += `<br><a href="` + html.EscapeString(n.URL) + `">Status page</a>`
+	}
+	txn := fmt.Sprintf("servicarr-%d-%d", time.Now().UnixNano(), matrixTxn.Add(1))
+	target := m.server + "/_matrix/client/v3/rooms/" + url.PathEscape(m.roomID) + "/send/m.room.message/" + txn
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+m.token)
+	return postJSON(ctx, http.MethodPut, target, header, map[string]any{
+		"msgtype":        "m.text",
+		"body":           text(n),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formatted,
+	})
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"status/app/internal/models"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds a whole SMTP exchange when the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// Email sends notifications over SMTP using the alert configuration
+type Email struct {
+	Config *models.AlertConfig
+	To     []string // recipients, the alert email if empty
+}
+
+// Notify sends the HTML body of a notification to the recipients
+func (e *Email) Notify(ctx context.Context, n *Notification) error {
+	config := e.Config
+	to := e.To
+	if len(to) == 0 && config != nil && config.AlertEmail != "" {
+		to = []string{config.AlertEmail}
+	}
+	if config == nil || config.SMTPHost == "" || len(to) == 0 {
+		return errors.New("SMTP configuration incomplete")
+	}
+
+	from := config.FromEmail
+	if from == "" {
+		from = config.SMTPUser
+	}
+
+	body, contentType := n.HTML, "text/html; charset=UTF-8"
+	if body == "" {
+		body, contentType = text(n), "text/plain; charset=UTF-8"
+	}
+
+	// Create MIME message
+	headers := make(map[string]string)
+	headers["From"] = from
+	headers["To"] = strings.Join(to, ", ")
+	headers["Subject"] = n.Title
+	headers["MIME-Version"] = "1.0"
+	headers["Content-Type"] = contentType
+
+	var msg string
+	for k, v := range headers {
+		msg += fmt.Sprintf("%s: %s\r\n", k, v)
+	}
+	msg += "\r\n" + body
+
+	auth := smtp.PlainAuth("", config.SMTPUser, config.SMTPPassword, config.SMTPHost)
+	addr := fmt.Sprintf("%s:%d", config.SMTPHost, config.SMTPPort)
+
+	return sendMail(ctx, addr, config.SMTPHost, auth, from, to, []byte(msg))
+}
+
+// sendMail works like smtp.SendMail but gives up when ctx is done, so an
+// unresponsive server cannot hold up delivery forever
+func sendMail(ctx context.Context, addr, host string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,230 @@
+// Package notify delivers alert notifications to email and chat, push and
+// webhook services
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"status/app/internal/models"
+	"strings"
+	"time"
+)
+
+// Notification events
+const (
+	EventDown     = "down"
+	EventDegraded = "degraded"
+	EventUp       = "up"
+	EventTest     = "test"
+)
+
+// Notification is an alert about a service, rendered by each channel in its
+// own format. It is stored as JSON while queued for delivery.
+type Notification struct {
+	Event       string    `json:"event"`
+	ServiceKey  string    `json:"service_key"`
+	ServiceName string    `json:"service_name"`
+	Title       string    `json:"title"`   // one-line summary, e.g. "🔴 Service Down: Plex"
+	Message     string    `json:"message"` // plain-text details
+	HTML        string    `json:"html"`    // complete HTML body for email
+	URL         string    `json:"url"`     // status page, empty if not configured
+	Time        time.Time `json:"time"`    // when the change was detected
+
+	OldState   string `json:"old_state"` // up, degraded or down; empty for tests
+	NewState   string `json:"new_state"`
+	LatencyMS  *int   `json:"latency_ms"`  // response time of the check, nil when there was no response
+	IncidentID int64  `json:"incident_id"` // incident opened or resolved by the change, 0 if none
+}
+
+// Service states reported in notifications
+const (
+	StateUp       = "up"
+	StateDegraded = "degraded"
+	StateDown     = "down"
+)
+
+// Notifier delivers notifications to one channel
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Default API servers of the hosted services
+const (
+	defaultTelegramServer = "https://api.telegram.org"
+	defaultNtfyServer     = "https://ntfy.sh"
+	defaultPushoverServer = "https://api.pushover.net"
+)
+
+// client is shared by all HTTP channels. It does not follow redirects so
+// tokens are never sent to another host.
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// New returns the notifier of a channel after checking its settings
+func New(ch *models.NotificationChannel) (Notifier, error) {
+	if err := Validate(ch); err != nil {
+		return nil, err
+	}
+	s := ch.Settings
+	switch ch.Type {
+	case models.ChannelWebhook:
+		return newWebhook(s)
+	case models.ChannelDiscord:
+		return &discord{url: s.URL}, nil
+	case models.ChannelSlack:
+		return &slack{url: s.URL}, nil
+	case models.ChannelTelegram:
+		return &telegram{server: server(s.Server, defaultTelegramServer), token: s.Token, chatID: s.ChatID}, nil
+	case models.ChannelNtfy:
+		return &ntfy{server: server(s.Server, defaultNtfyServer), topic: s.Topic, token: s.Token}, nil
+	case models.ChannelGotify:
+		return &gotify{server: server(s.Server, ""), token: s.Token}, nil
+	case models.ChannelPushover:
+		return &pushover{server: server(s.Server, defaultPushoverServer), token: s.Token, user: s.User}, nil
+	case models.ChannelMatrix:
+		return &matrix{server: server(s.Server, ""), token: s.Token, roomID: s.RoomID}, nil
+	}
+	return nil, errors.New("unknown channel type " + ch.Type)
+}
+
+// Validate checks that a channel has the settings its type needs
+func Validate(ch *models.NotificationChannel) error {
+	if err := ch.Validate(); err != nil {
+		return err
+	}
+	s := &ch.Settings
+	for _, f := range []*string{&s.URL, &s.Server, &s.Token, &s.User, &s.ChatID, &s.Topic, &s.RoomID} {
+		*f = strings.TrimSpace(*f)
+	}
+
+	switch ch.Type {
+	case models.ChannelWebhook:
+		if err := checkURL("url", s.URL, true); err != nil {
+			return err
+		}
+		_, err := newWebhook(*s)
+		return err
+	case models.ChannelDiscord, models.ChannelSlack:
+		return checkURL("url", s.URL, true)
+	case models.ChannelTelegram:
+		if s.Token == "" || s.ChatID == "" {
+			return errors.New("telegram needs a bot token and chat id")
+		}
+	case models.ChannelNtfy:
+		if s.Topic == "" || strings.Contains(s.Topic, "/") {
+			return errors.New("ntfy needs a topic")
+		}
+	case models.ChannelGotify:
+		if s.Server == "" || s.Token == "" {
+			return errors.New("gotify needs a server and application token")
+		}
+	case models.ChannelPushover:
+		if s.Token == "" || s.User == "" {
+			return errors.New("pushover needs an application token and user key")
+		}
+	case models.ChannelMatrix:
+		if s.Server == "" || s.Token == "" || s.RoomID == "" {
+			return errors.New("matrix needs a homeserver, access token and room id")
+		}
+	}
+	return checkURL("server", s.Server, false)
+}
+
+// checkURL requires an absolute http(s) URL
+func checkURL(field, raw string, required bool) error {
+	if raw == "" {
+		if required {
+			return errors.New(field + " required")
+		}
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New(field + " must be an http(s) URL")
+	}
+	return nil
+}
+
+func server(s, def string) string {
+	if s == "" {
+		s = def
+	}
+	return strings.TrimSuffix(s, "/")
+}
+
+// postJSON sends v as JSON and expects a 2xx response
+func postJSON(ctx context.Context, method, target string, header http.Header, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Type", "application/json")
+	return send(ctx, method, target, header, bytes.NewReader(body))
+}
+
+// send performs a request and expects a 2xx response. Transport errors are
+// returned without the URL, which may contain a token.
+func send(ctx context.Context, method, target string, header http.Header, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return errors.New("invalid request URL")
+	}
+	req.Header = header
+	req.Header.Set("User-Agent", "Servicarr")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		if s := strings.TrimSpace(string(msg)); s != "" {
+			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, s)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return nil
+}
+
+// text renders a notification as plain text with the status page link
+func text(n *Notification) string {
+	s := n.Title
+	if n.Message != "" {
+		s += "\n" + n.Message
+	}
+	if n.URL != "" {
+		s += "\n" + n.URL
+	}
+	return s
+}
+
+// color returns the RGB color of an event, matching the alert emails
+func color(event string) int {
+	switch event {
+	case EventDown:
+		return 0xef4444
+	case EventDegraded:
+		return 0xeab308
+	}
+	return 0x16a34a
+}
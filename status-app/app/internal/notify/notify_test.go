@@ -0,0 +1,307 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"status/app/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// request is what a stand-in server received
+type request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// standIn starts a local server that records every request and answers
+// with status and body
+func standIn(t *testing.T, status int, body string) (*httptest.Server, <-chan request) {
+	t.Helper()
+	got := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got <- request{Method: r.Method, Path: r.URL.EscapedPath(), Header: r.Header.Clone(), Body: b}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func testNotification() *Notification {
+	ms := 120
+	return &Notification{
+		Event:       EventDown,
+		ServiceKey:  "plex",
+		ServiceName: "Plex",
+		Title:       "🔴 Service Down: Plex",
+		Message:     "Plex is <unreachable> & down",
+		URL:         "https://status.example.com",
+		Time:        time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		OldState:    StateUp,
+		NewState:    StateDown,
+		LatencyMS:   &ms,
+		IncidentID:  42,
+	}
+}
+
+func notify(t *testing.T, ch *models.NotificationChannel) error {
+	t.Helper()
+	n, err := New(ch)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return n.Notify(ctx, testNotification())
+}
+
+func decodeJSON(t *testing.T, b []byte) map[string]any {
+	t.Helper()
+	var v map[string]any
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("body is not JSON: %v: %s", err, b)
+	}
+	return v
+}
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		typ      string
+		settings func(srv string) models.ChannelSettings
+		method   string
+		path     string
+		header   map[string]string
+		check    func(t *testing.T, r request)
+	}{
+		{
+			typ: models.ChannelDiscord,
+			settings: func(srv string) models.ChannelSettings {
+				return models.ChannelSettings{URL: srv + "/api/webhooks/1/abc"}
+			},
+			method: http.MethodPost,
+			path:   "/api/webhooks/1/abc",
+			header: map[string]string{"Content-Type": "application/json"},
+			check: func(t *testing.T, r request) {
+				body := decodeJSON(t, r.Body)
+				embeds, _ := body["embeds"].([]any)
+				if len(embeds) != 1 {
+					t.Fatalf("embeds = %v", body["embeds"])
+				}
+				embed := embeds[0].(map[string]any)
+				if embed["title"] != "🔴 Service Down: Plex" || embed["description"] != "Plex is <unreachable> & down" ||
+					embed["url"] != "https://status.example.com" || embed["color"] != float64(0xef4444) ||
+					embed["timestamp"] != "2026-01-02T03:04:05Z" {
+					t.Errorf("embed = %v", embed)
+				}
+			},
+		},
+		{
+			typ:      models.ChannelSlack,
+			settings: func(srv string) models.ChannelSettings { return models.ChannelSettings{URL: srv + "/services/T/B/x"} },
+			method:   http.MethodPost,
+			path:     "/services/T/B/x",
+			header:   map[string]string{"Content-Type": "application/json"},
+			check: func(t *testing.T, r request) {
+				want := "*🔴 Service Down: Plex*\nPlex is &lt;unreachable&gt; &amp; down\n<https://status.example.com|Status page>"
+				if got := decodeJSON(t, r.Body)["text"]; got != want {
+					t.Errorf("text = %q, want %q", got, want)
+				}
+			},
+		},
+		{
+			typ: models.ChannelTelegram,
+			settings: func(srv string) models.ChannelSettings {
+				return models.ChannelSettings{Server: srv, Token: "123:secret", ChatID: "-100"}
+			},
+			method: http.MethodPost,
+			path:   "/bot123:secret/sendMessage",
+			header: map[string]string{"Content-Type": "application/json"},
+			check: func(t *testing.T, r request) {
+				body := decodeJSON(t, r.Body)
+				want := "<b>🔴 Service Down: Plex</b>\nPlex is &lt;unreachable&gt; &amp; down\nhttps://status.example.com"
+				if body["chat_id"] != "-100" || body["parse_mode"] != "HTML" || body["text"] != want {
+					t.Errorf("body = %v", body)
+				}
+			},
+		},
+		{
+			typ: models.ChannelMatrix,
+			settings: func(srv string) models.ChannelSettings {
+				return models.ChannelSettings{Server: srv, Token: "syt_token", RoomID: "!room:example.com"}
+			},
+			method: http.MethodPut,
+			path:   "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/",
+			header: map[string]string{"Authorization": "Bearer syt_token", "Content-Type": "application/json"},
+			check: func(t *testing.T, r request) {
+				body := decodeJSON(t, r.Body)
+				if body["msgtype"] != "m.text" || body["format"] != "org.matrix.custom.html" ||
+					body["body"] != "🔴 Service Down: Plex\nPlex is <unreachable> & down\nhttps://status.example.com" ||
+					!strings.Contains(body["formatted_body"].(string), "Plex is &lt;unreachable&gt; &amp; down") {
+					t.Errorf("body = %v", body)
+				}
+			},
+		},
+		{
+			typ: models.ChannelNtfy,
+			settings: func(srv string) models.ChannelSettings {
+				return models.ChannelSettings{Server: srv, Topic: "alerts", Token: "tk_abc"}
+			},
+			method: http.MethodPost,
+			path:   "/",
+			header: map[string]string{"Authorization": "Bearer tk_abc", "Content-Type": "application/json"},
+			check: func(t *testing.T, r request) {
+				body := decodeJSON(t, r.Body)
+				if body["topic"] != "alerts" || body["title"] != "🔴 Service Down: Plex" || body["priority"] != float64(4) ||
+					body["click"] != "https://status.example.com" {
+					t.Errorf("body = %v", body)
+				}
+			},
+		},
+		{
+			typ: models.ChannelGotify,
+			settings: func(srv string) models.ChannelSettings {
+				return models.ChannelSettings{Server: srv + "/", Token: "Agotify"}
+			},
+			method: http.MethodPost,
+			path:   "/message",
+			header: map[string]string{"X-Gotify-Key": "Agotify", "Content-Type": "application/json"},
+			check: func(t *testing.T, r request) {
+				body := decodeJSON(t, r.Body)
+				if body["title"] != "🔴 Service Down: Plex" || body["message"] != "Plex is <unreachable> & down" || body["priority"] != float64(8) {
+					t.Errorf("body = %v", body)
+				}
+				if _, ok := body["extras"].(map[string]any)["client::notification"]; !ok {
+					t.Errorf("extras = %v", body["extras"])
+				}
+			},
+		},
+		{
+			typ: models.ChannelPushover,
+			settings: func(srv string) models.ChannelSettings {
+				return models.ChannelSettings{Server: srv, Token: "apptoken", User: "userkey"}
+			},
+			method: http.MethodPost,
+			path:   "/1/messages.json",
+			header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			check: func(t *testing.T, r request) {
+				form, err := url.ParseQuery(string(r.Body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := map[string]string{
+					"token": "apptoken", "user": "userkey", "title": "🔴 Service Down: Plex", "message": "Plex is <unreachable> & down",
+					"priority": "1", "timestamp": "1767323045", "url": "https://status.example.com",
+				}
+				for k, v := range want {
+					if form.Get(k) != v {
+						t.Errorf("%s = %q, want %q", k, form.Get(k), v)
+					}
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			srv, got := standIn(t, http.StatusOK, `{"ok":true}`)
+			ch := &models.NotificationChannel{Name: tt.typ, Type: tt.typ, Enabled: true, Settings: tt.settings(srv.URL)}
+			if err := notify(t, ch); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			r := <-got
+			if r.Method != tt.method {
+				t.Errorf("method = %s, want %s", r.Method, tt.method)
+			}
+			if !strings.HasPrefix(r.Path, tt.path) || (!strings.HasSuffix(tt.path, "/") && r.Path != tt.path) {
+				t.Errorf("path = %s, want %s", r.Path, tt.path)
+			}
+			for name, want := range tt.header {
+				if v := r.Header.Get(name); v != want {
+					t.Errorf("%s = %q, want %q", name, v, want)
+				}
+			}
+			tt.check(t, r)
+		})
+	}
+}
+
+func TestChannelErrors(t *testing.T) {
+	srv, _ := standIn(t, http.StatusUnauthorized, "invalid token\n")
+	for _, typ := range []string{models.ChannelWebhook, models.ChannelDiscord, models.ChannelSlack, models.ChannelTelegram,
+		models.ChannelMatrix, models.ChannelNtfy, models.ChannelGotify, models.ChannelPushover} {
+		t.Run(typ, func(t *testing.T) {
+			ch := &models.NotificationChannel{Name: typ, Type: typ, Settings: models.ChannelSettings{
+				URL: srv.URL + "/hook", Server: srv.URL, Token: "t0ken", User: "u", ChatID: "1", Topic: "alerts", RoomID: "!r:x",
+			}}
+			err := notify(t, ch)
+			if err == nil || err.Error() != "HTTP 401: invalid token" {
+				t.Fatalf("err = %v, want HTTP 401: invalid token", err)
+			}
+		})
+	}
+}
+
+func TestTransportErrorHidesToken(t *testing.T) {
+	srv, _ := standIn(t, http.StatusOK, "")
+	srv.Close()
+	ch := &models.NotificationChannel{Name: "tg", Type: models.ChannelTelegram, Settings: models.ChannelSettings{
+		Server: srv.URL, Token: "123:supersecret", ChatID: "1",
+	}}
+	err := notify(t, ch)
+	if err == nil {
+		t.Fatal("expected an error from a closed server")
+	}
+	if strings.Contains(err.Error(), "supersecret") {
+		t.Errorf("error leaks the token: %v", err)
+	}
+}
+
+func TestRedirectsAreNotFollowed(t *testing.T) {
+	target, got := standIn(t, http.StatusOK, "")
+	srv := httptest.NewServer(http.RedirectHandler(target.URL+"/stolen", http.StatusTemporaryRedirect))
+	defer srv.Close()
+
+	ch := &models.NotificationChannel{Name: "gotify", Type: models.ChannelGotify, Settings: models.ChannelSettings{Server: srv.URL, Token: "t"}}
+	if err := notify(t, ch); err == nil || !strings.HasPrefix(err.Error(), "HTTP 307") {
+		t.Errorf("err = %v, want HTTP 307", err)
+	}
+	select {
+	case r := <-got:
+		t.Errorf("redirect was followed to %s", r.Path)
+	default:
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		typ      string
+		settings models.ChannelSettings
+		err      string
+	}{
+		{models.ChannelWebhook, models.ChannelSettings{}, "url required"},
+		{models.ChannelWebhook, models.ChannelSettings{URL: "ftp://x"}, "url must be an http(s) URL"},
+		{models.ChannelTelegram, models.ChannelSettings{Token: "t"}, "telegram needs a bot token and chat id"},
+		{models.ChannelNtfy, models.ChannelSettings{Topic: "a/b"}, "ntfy needs a topic"},
+		{models.ChannelGotify, models.ChannelSettings{Token: "t"}, "gotify needs a server and application token"},
+		{models.ChannelPushover, models.ChannelSettings{Token: "t"}, "pushover needs an application token and user key"},
+		{models.ChannelMatrix, models.ChannelSettings{Server: "https://m", Token: "t"}, "matrix needs a homeserver, access token and room id"},
+		{models.ChannelNtfy, models.ChannelSettings{Topic: "a", Server: "ntfy.sh"}, "server must be an http(s) URL"},
+		{models.ChannelNtfy, models.ChannelSettings{Topic: " alerts "}, ""},
+	}
+	for _, tt := range tests {
+		ch := &models.NotificationChannel{Name: "c", Type: tt.typ, Settings: tt.settings}
+		err := Validate(ch)
+		if (err == nil) != (tt.err == "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("Validate(%s %+v) = %v, want %q", tt.typ, tt.settings, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ntfy publishes notifications to an ntfy topic
+type ntfy struct {
+	server string
+	topic  string
+	token  string // optional, for protected topics
+}
+
+func (p *ntfy) Notify(ctx context.Context, n *Notification) error {
+	priority := 3
+	if n.Event == EventDown {
+		priority = 4
+	}
+	msg := map[string]any{
+		"topic":    p.topic,
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": priority,
+	}
+	if n.URL != "" {
+		msg["click"] = n.URL
+	}
+	var header http.Header
+	if p.token != "" {
+		header = http.Header{}
+		header.Set("Authorization", "Bearer "+p.token)
+	}
+	// Publishing JSON to the server root keeps emoji out of headers
+	return postJSON(ctx, http.MethodPost, p.server+"/", header, msg)
+}
+
+// gotify sends notifications to a Gotify server
+type gotify struct {
+	server string
+	token  string
+}
+
+func (g *gotify) Notify(ctx context.Context, n *Notification) error {
+	priority := 5
+	if n.Event == EventDown {
+		priority = 8
+	}
+	header := http.Header{}
+	header.Set("X-Gotify-Key", g.token)
+	msg := map[string]any{
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": priority,
+	}
+	if n.URL != "" {
+		msg["extras"] = map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": n.URL}},
+		}
+	}
+	return postJSON(ctx, http.MethodPost, g.server+"/message", header, msg)
+}
+
+// pushover sends notifications through the Pushover API
+type pushover struct {
+	server string
+	token  string
+	user   string
+}
+
+func (p *pushover) Notify(ctx context.Context, n *Notification) error {
+	form := url.Values{}
+	form.Set("token", p.token)
+	form.Set("user", p.user)
+	form.Set("title", n.Title)
+	form.Set("message", n.Message)
+	form.Set("timestamp", strconv.FormatInt(n.Time.Unix(), 10))
+	if n.Event == EventDown {
+		form.Set("priority", "1")
+	}
+	if n.URL != "" {
+		form.Set("url", n.URL)
+		form.Set("url_title", "Status page")
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return send(ctx, http.MethodPost, p.server+"/1/messages.json", header, strings.NewReader(form.Encode()))
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"status/app/internal/models"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the webhook body, keyed with the
+// webhook's secret, as "sha256=" and the hex digest
+const SignatureHeader = "X-Servicarr-Signature"
+
+// Event is the JSON body posted to generic webhooks and the data passed to
+// their body templates
+type Event struct {
+	Event        string    `json:"event"` // down, degraded, up or test
+	ServiceKey   string    `json:"service_key"`
+	ServiceLabel string    `json:"service_label"`
+	OldState     string    `json:"old_state"`   // up, degraded or down; empty for tests
+	NewState     string    `json:"new_state"`   // up, degraded or down; empty for tests
+	LatencyMS    *int      `json:"latency_ms"`  // null when the check got no response
+	IncidentID   *int64    `json:"incident_id"` // incident opened or resolved, null if none
+	Title        string    `json:"title"`
+	Message      string    `json:"message"`
+	URL          string    `json:"url,omitempty"` // status page
+	Timestamp    time.Time `json:"timestamp"`     // when the change was detected, UTC
+}
+
+// NewEvent returns the webhook event of a notification
+func NewEvent(n *Notification) Event {
+	e := Event{
+		Event:        n.Event,
+		ServiceKey:   n.ServiceKey,
+		ServiceLabel: n.ServiceName,
+		OldState:     n.OldState,
+		NewState:     n.NewState,
+		LatencyMS:    n.LatencyMS,
+		Title:        n.Title,
+		Message:      n.Message,
+		URL:          n.URL,
+		Timestamp:    n.Time.UTC(),
+	}
+	if n.IncidentID != 0 {
+		id := n.IncidentID
+		e.IncidentID = &id
+	}
+	return e
+}
+
+// templateFuncs are available to webhook body templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value as JSON, e.g. "text": {{json .Title}}
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// webhook posts notifications as a JSON event, or a templated body, to any
+// URL, optionally signed
+type webhook struct {
+	url     string
+	secret  string
+	headers map[string]string
+	tmpl    *template.Template // nil for the JSON event
+}
+
+func newWebhook(s models.ChannelSettings) (*webhook, error) {
+	w := &webhook{url: s.URL, secret: s.Secret, headers: s.Headers}
+	for name, value := range s.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return nil, errors.New("invalid header " + name)
+		}
+		if http.CanonicalHeaderKey(name) == SignatureHeader {
+			return nil, errors.New(SignatureHeader + " is set by the signing secret")
+		}
+	}
+	if strings.TrimSpace(s.Template) != "" {
+		tmpl, err := template.New("body").Funcs(templateFuncs).Option("missingkey=error").Parse(s.Template)
+		if err != nil {
+			return nil, errors.New("invalid template: " + err.Error())
+		}
+		// Catch references to unknown fields now rather than on the first alert
+		sample := &Notification{Event: EventDown, OldState: StateUp, NewState: StateDown, Time: time.Now()}
+		if err := tmpl.Execute(&bytes.Buffer{}, NewEvent(sample)); err != nil {
+			return nil, errors.New("invalid template: " + err.Error())
+		}
+		w.tmpl = tmpl
+	}
+	return w, nil
+}
+
+// body renders the request body of a notification
+func (w *webhook) body(n *Notification) ([]byte, error) {
+	event := NewEvent(n)
+	if w.tmpl == nil {
+		return json.Marshal(event)
+	}
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, event); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Sign returns the signature header value of a webhook body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhook) Notify(ctx context.Context, n *Notification) error {
+	body, err := w.body(n)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Servicarr-Event", n.Event)
+	for name, value := range w.headers {
+		header.Set(name, value)
+	}
+	if w.secret != "" {
+		header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+	return send(ctx, http.MethodPost, w.url, header, bytes.NewReader(body))
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"status/app/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestWebhookEvent(t *testing.T) {
+	srv, got := standIn(t, http.StatusNoContent, "")
+	ch := &models.NotificationChannel{Name: "hook", Type: models.ChannelWebhook, Settings: models.ChannelSettings{
+		URL:     srv.URL + "/hook",
+		Secret:  "s3cret",
+		Headers: map[string]string{"Authorization": "Bearer abc", "X-Custom": "1"},
+	}}
+	if err := notify(t, ch); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	r := <-got
+
+	if r.Method != http.MethodPost || r.Path != "/hook" {
+		t.Errorf("%s %s", r.Method, r.Path)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":      "application/json",
+		"X-Servicarr-Event": "down",
+		"Authorization":     "Bearer abc",
+		"X-Custom":          "1",
+	} {
+		if v := r.Header.Get(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+
+	// The signature is the HMAC-SHA256 of the exact body
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(r.Body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(SignatureHeader) != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, r.Header.Get(SignatureHeader), want)
+	}
+
+	var event map[string]any
+	if err := json.Unmarshal(r.Body, &event); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"event":         "down",
+		"service_key":   "plex",
+		"service_label": "Plex",
+		"old_state":     "up",
+		"new_state":     "down",
+		"latency_ms":    float64(120),
+		"incident_id":   float64(42),
+		"title":         "🔴 Service Down: Plex",
+		"message":       "Plex is <unreachable> & down",
+		"url":           "https://status.example.com",
+		"timestamp":     "2026-01-02T03:04:05Z",
+	}
+	for k, v := range want {
+		if event[k] != v {
+			t.Errorf("%s = %v, want %v", k, event[k], v)
+		}
+	}
+	if len(event) != len(want) {
+		t.Errorf("event has %d fields, want %d: %v", len(event), len(want), event)
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	srv, got := standIn(t, http.StatusOK, "")
+	ch := &models.NotificationChannel{Name: "hook", Type: models.ChannelWebhook, Settings: models.ChannelSettings{URL: srv.URL}}
+	if err := notify(t, ch); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if r := <-got; r.Header.Get(SignatureHeader) != "" {
+		t.Errorf("unsigned webhook sent %s", SignatureHeader)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	srv, got := standIn(t, http.StatusOK, "")
+	ch := &models.NotificationChannel{Name: "hook", Type: models.ChannelWebhook, Settings: models.ChannelSettings{
+		URL:      srv.URL,
+		Secret:   "s3cret",
+		Headers:  map[string]string{"Content-Type": "text/plain"},
+		Template: `{"text": {{json .Title}}, "service": "{{.ServiceKey}}", "incident": {{json .IncidentID}}}`,
+	}}
+	if err := notify(t, ch); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	r := <-got
+	if want := `{"text": "🔴 Service Down: Plex", "service": "plex", "incident": 42}`; string(r.Body) != want {
+		t.Errorf("body = %s, want %s", r.Body, want)
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("Content-Type = %q, want the configured text/plain", ct)
+	}
+	if r.Header.Get(SignatureHeader) != Sign("s3cret", r.Body) {
+		t.Errorf("templated body is not signed")
+	}
+}
+
+func TestWebhookSettingsRejected(t *testing.T) {
+	tests := []struct {
+		settings models.ChannelSettings
+		err      string
+	}{
+		{models.ChannelSettings{Template: "{{.Nope}}"}, "invalid template"},
+		{models.ChannelSettings{Template: "{{"}, "invalid template"},
+		{models.ChannelSettings{Headers: map[string]string{"Bad Header": "x"}}, "invalid header"},
+		{models.ChannelSettings{Headers: map[string]string{"x-servicarr-signature": "x"}}, "is set by the signing secret"},
+	}
+	for _, tt := range tests {
+		tt.settings.URL = "http://127.0.0.1/hook"
+		_, err := New(&models.NotificationChannel{Name: "hook", Type: models.ChannelWebhook, Settings: tt.settings})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("New(%+v) = %v, want an error containing %q", tt.settings, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package registry
+
+import (
+	"maps"
+	"slices"
+	"status/app/internal/database"
+	"status/app/internal/models"
+	"sync"
+)
+
+// Registry holds the live set of monitored services. It is shared by the
+// scheduler and the HTTP handlers so that services added, edited or removed
+// through the admin API take effect without restarting the process.
+//
+// The services it hands out are shared and must be treated as read-only;
+// changes go through the database followed by Reload, or SetDisabled.
+// Runtime state such as failure counters lives in the state store.
+type Registry struct {
+	mu       sync.RWMutex
+	services []*models.Service
+	subs     []chan struct{}
+}
+
+// New creates a registry and loads the services from the database
+func New() (*Registry, error) {
+	r := &Registry{}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads service definitions from the database
+func (r *Registry) Reload() error {
+	list, err := database.ListServices()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.services = list
+	r.notify()
+	return nil
+}
+
+// notify signals subscribers; the caller must hold r.mu
+func (r *Registry) notify() {
+	for _, ch := range r.subs {
+		select {
+		case ch <- struct{}{}:
+		default: // a notification is already pending
+		}
+	}
+}
+
+// SetDisabled persists whether monitoring is disabled for a service and
+// swaps in an updated copy of its definition
+func (r *Registry) SetDisabled(key string, disabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.services {
+		if s.Key != key {
+			continue
+		}
+		if err := database.SetServiceDisabledState(key, disabled); err != nil {
+			return err
+		}
+		updated := *s
+		updated.Disabled = disabled
+		r.services[i] = &updated
+		r.notify()
+		return nil
+	}
+	return database.ErrServiceNotFound
+}
+
+// Subscribe returns a channel that is signalled whenever the services are
+// reloaded. Notifications are coalesced, so a slow reader sees that
+// something changed rather than every individual change.
+func (r *Registry) Subscribe() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	r.subs = append(r.subs, ch)
+	return ch
+}
+
+// List returns the current services in display order
+func (r *Registry) List() []*models.Service {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]*models.Service, len(r.services))
+	copy(out, r.services)
+	return out
+}
+
+// Get finds a service by its key
+func (r *Registry) Get(key string) *models.Service {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.services {
+		if s.Key == key {
+			return s
+		}
+	}
+	return nil
+}
+
+// ApplyFileServices reconciles the services defined in the config file with
+// the database: new keys are added, changed definitions are updated and
+// file-managed services no longer in the file are removed. Services created
+// through the admin API are only touched when the file claims their key.
+func (r *Registry) ApplyFileServices(defs []*models.Service) (added, updated, removed []string, err error) {
+	current, err := database.ListServices()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	existing := make(map[string]*models.Service, len(current))
+	for _, s := range current {
+		existing[s.Key] = s
+	}
+
+	wanted := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		wanted[def.Key] = true
+		old, ok := existing[def.Key]
+		if !ok {
+			if def.SortOrder, err = database.NextServiceSortOrder(); err != nil {
+				return added, updated, removed, err
+			}
+			if err = database.InsertService(def); err != nil {
+				return added, updated, removed, err
+			}
+			added = append(added, def.Key)
+			continue
+		}
+		if sameDefinition(old, def) {
+			continue
+		}
+		if err = database.UpdateService(def); err != nil {
+			return added, updated, removed, err
+		}
+		updated = append(updated, def.Key)
+	}
+
+	for _, s := range current {
+		if s.Source == models.ServiceSourceFile && !wanted[s.Key] {
+			if err = database.DeleteService(s.Key); err != nil {
+				return added, updated, removed, err
+			}
+			removed = append(removed, s.Key)
+		}
+	}
+
+	return added, updated, removed, r.Reload()
+}
+
+func sameDefinition(a, b *models.Service) bool {
+	return a.Label == b.Label && a.URL == b.URL && a.Timeout == b.Timeout &&
+		a.Interval == b.Interval && a.Jitter == b.Jitter && a.Policy.Equal(b.Policy) &&
+		a.Retries == b.Retries && a.RetryDelay == b.RetryDelay &&
+		a.MinOK == b.MinOK && a.MaxOK == b.MaxOK && a.Source == b.Source &&
+		slices.Equal(a.Assertions, b.Assertions) && sameHTTPOptions(a.HTTP, b.HTTP) &&
+		a.Secrets.Password == b.Secrets.Password && a.Secrets.Token == b.Secrets.Token &&
+		maps.Equal(a.Secrets.Headers, b.Secrets.Headers)
+}
+
+func sameHTTPOptions(a, b models.HTTPOptions) bool {
+	return a.Method == b.Method && a.Body == b.Body && a.Auth == b.Auth &&
+		a.Username == b.Username && a.Redirects == b.Redirects && maps.Equal(a.Headers, b.Headers)
+}
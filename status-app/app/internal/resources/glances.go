@@ -99,8 +99,20 @@ func (c *Client) SetCacheTTL(d time.Duration) {
 	c.cacheFor = d
 }
 
+// SetBaseURL points the client at a different Glances API and drops the cached snapshot
+func (c *Client) SetBaseURL(baseURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.BaseURL = baseURL
+	c.cachedAt = time.Time{}
+}
+
 func (c *Client) getJSON(ctx context.Context, path string, out any) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	c.mu.Lock()
+	base := c.BaseURL
+	c.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
 	if err != nil {
 		return err
 	}
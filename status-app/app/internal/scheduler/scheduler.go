@@ -0,0 +1,209 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"status/app/internal/alerts"
+	"status/app/internal/checker"
+	"status/app/internal/database"
+	"status/app/internal/maintenance"
+	"status/app/internal/models"
+	"status/app/internal/registry"
+	"status/app/internal/state"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduler runs every service in its own worker on the service's own
+// interval, with random jitter so checks don't line up, while a global limit
+// bounds how many checks run at the same time
+type Scheduler struct {
+	reg             *registry.Registry
+	alertMgr        *alerts.Manager
+	store           *state.Store
+	maint           *maintenance.Schedule
+	defaultInterval time.Duration
+	sem             chan struct{}
+
+	mu      sync.Mutex
+	workers map[string]*worker
+	wg      sync.WaitGroup
+}
+
+// worker is the goroutine checking one service
+type worker struct {
+	cancel   context.CancelFunc
+	interval time.Duration
+	jitter   time.Duration
+}
+
+// New creates a scheduler. defaultInterval applies to services without
+// their own interval and maxConcurrent caps the number of checks in flight.
+func New(reg *registry.Registry, alertMgr *alerts.Manager, store *state.Store, maint *maintenance.Schedule, defaultInterval time.Duration, maxConcurrent int) *Scheduler {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &Scheduler{
+		reg:             reg,
+		alertMgr:        alertMgr,
+		store:           store,
+		maint:           maint,
+		defaultInterval: defaultInterval,
+		sem:             make(chan struct{}, maxConcurrent),
+		workers:         map[string]*worker{},
+	}
+}
+
+// Run starts a worker per service and keeps the workers in sync with the
+// registry until ctx is cancelled. In-flight checks are cancelled with it,
+// and Run returns once every worker has stopped.
+func (s *Scheduler) Run(ctx context.Context) {
+	changes := s.reg.Subscribe()
+	s.sync(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-changes:
+			s.sync(ctx)
+		}
+	}
+}
+
+// timing returns the effective interval and jitter of a service
+func (s *Scheduler) timing(svc *models.Service) (interval, jitter time.Duration) {
+	interval = svc.Interval
+	if interval <= 0 {
+		interval = s.defaultInterval
+	}
+	jitter = svc.Jitter
+	if jitter <= 0 {
+		jitter = interval / 10
+	}
+	return interval, jitter
+}
+
+// sync starts workers for new services, stops workers of removed services
+// and restarts workers whose schedule changed. Other definition changes are
+// picked up by the running worker on its next check.
+func (s *Scheduler) sync(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := map[string]bool{}
+	for _, svc := range s.reg.List() {
+		seen[svc.Key] = true
+		interval, jitter := s.timing(svc)
+		if w, ok := s.workers[svc.Key]; ok {
+			if w.interval == interval && w.jitter == jitter {
+				continue
+			}
+			w.cancel()
+		}
+
+		wctx, cancel := context.WithCancel(ctx)
+		s.workers[svc.Key] = &worker{cancel: cancel, interval: interval, jitter: jitter}
+		s.wg.Add(1)
+		go func(key string) {
+			defer s.wg.Done()
+			s.runWorker(wctx, key, interval, jitter)
+		}(svc.Key)
+	}
+
+	for key, w := range s.workers {
+		if !seen[key] {
+			w.cancel()
+			delete(s.workers, key)
+			s.store.Delete(key)
+		}
+	}
+}
+
+// runWorker checks one service until ctx is cancelled. The first check runs
+// after a random share of the jitter so a restart doesn't probe everything at
+// once; later checks are scheduled from the start of the previous one so a
+// slow check doesn't push the schedule back.
+func (s *Scheduler) runWorker(ctx context.Context, key string, interval, jitter time.Duration) {
+	next := time.Now().Add(randDuration(jitter))
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if ctx.Err() != nil {
+				return // select picks randomly when both are ready
+			}
+		}
+
+		start := time.Now()
+		svc := s.reg.Get(key)
+		if svc == nil {
+			return
+		}
+		if !svc.Disabled {
+			select {
+			case s.sem <- struct{}{}:
+				s.check(ctx, svc)
+				<-s.sem
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		next = start.Add(interval + randDuration(jitter))
+		timer.Reset(max(time.Until(next), 0))
+	}
+}
+
+// check probes a service, records the sample and sends alerts on changes.
+// During maintenance the sample is tagged and no alerts are sent.
+func (s *Scheduler) check(ctx context.Context, svc *models.Service) {
+	res := checker.Run(ctx, svc)
+	if ctx.Err() != nil {
+		// Shutting down or the service changed; the probe was cut short and
+		// its result says nothing about the service
+		return
+	}
+	now := time.Now()
+	st := s.store.Record(svc, res, now)
+	maint := s.maint.Active(svc.Key, now)
+
+	// Record sample in database (use the adjusted ok status)
+	smp := res.Sample(now, svc.Key, st.OK)
+	smp.Maintenance = maint != nil
+	database.InsertSample(smp)
+	if cert := res.Cert(); cert != nil {
+		_ = database.SaveCertInfo(svc.Key, cert)
+	}
+
+	// Track incidents and send alerts if status changed (based on adjusted ok status)
+	if maint == nil {
+		s.alertMgr.CheckAndSendAlerts(svc.Key, svc.Label, st)
+	}
+
+	// Log if there was an error
+	if res.Retried() {
+		log.Printf("Check %s passed on attempt %d after: %s", svc.Key, res.Attempts, strings.Join(res.AttemptErrors, "; "))
+	}
+	if res.Message != "" {
+		if maint != nil {
+			log.Printf("Check %s: %s (maintenance: %s)", svc.Key, res.Message, maint.Title)
+		} else {
+			log.Printf("Check %s: %s (failures: %d/%d)", svc.Key, res.Message, st.ConsecutiveFailures, svc.Policy.WithDefaults().FailureThreshold)
+		}
+	}
+}
+
+// randDuration returns a random duration in [0, d)
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"status/app/internal/alerts"
+	"status/app/internal/checker"
+	"status/app/internal/database"
+	"status/app/internal/maintenance"
+	"status/app/internal/models"
+	"status/app/internal/registry"
+	"status/app/internal/state"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkFunc is a checker for the fake:// scheme used by these tests
+type checkFunc func(ctx context.Context, s *models.Service) checker.Result
+
+func (f checkFunc) Check(ctx context.Context, s *models.Service) checker.Result { return f(ctx, s) }
+
+// startScheduler runs a scheduler over the given services, checked by fn,
+// until the test ends
+func startScheduler(t *testing.T, services []*models.Service, maxConcurrent int, fn checkFunc) {
+	t.Helper()
+	if err := database.Init(database.DriverSQLite, filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	conn := database.DB
+	t.Cleanup(func() { conn.Close() })
+	for _, s := range services {
+		if err := database.InsertService(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checker.Register("fake", fn)
+
+	reg, err := registry.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	maint, err := maintenance.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched := New(reg, alerts.NewManager("", nil), state.New(), maint, time.Hour, maxConcurrent)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sched.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestTiming(t *testing.T) {
+	s := &Scheduler{defaultInterval: time.Minute}
+	tests := []struct {
+		interval, jitter         time.Duration
+		wantInterval, wantJitter time.Duration
+	}{
+		{0, 0, time.Minute, 6 * time.Second},
+		{0, 2 * time.Second, time.Minute, 2 * time.Second},
+		{10 * time.Second, 0, 10 * time.Second, time.Second},
+		{10 * time.Second, 5 * time.Second, 10 * time.Second, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		interval, jitter := s.timing(&models.Service{Interval: tt.interval, Jitter: tt.jitter})
+		if interval != tt.wantInterval || jitter != tt.wantJitter {
+			t.Errorf("timing(%v, %v) = %v, %v, want %v, %v", tt.interval, tt.jitter, interval, jitter, tt.wantInterval, tt.wantJitter)
+		}
+	}
+}
+
+func TestSchedulerIntervals(t *testing.T) {
+	var mu sync.Mutex
+	checks := map[string][]time.Time{}
+	startScheduler(t, []*models.Service{
+		{Key: "fast", Label: "Fast", URL: "fake://fast", Interval: time.Second},
+		{Key: "slow", Label: "Slow", URL: "fake://slow", Interval: 2 * time.Second},
+	}, 8, func(ctx context.Context, s *models.Service) checker.Result {
+		mu.Lock()
+		checks[s.Key] = append(checks[s.Key], time.Now())
+		mu.Unlock()
+		return checker.Result{Status: checker.StatusUp}
+	})
+	time.Sleep(2500 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Each check follows the previous one by the service's interval plus up
+	// to its default jitter of a tenth of the interval
+	for key, want := range map[string]struct {
+		n        int
+		interval time.Duration
+	}{"fast": {3, time.Second}, "slow": {2, 2 * time.Second}} {
+		times := checks[key]
+		if len(times) != want.n {
+			t.Errorf("%s checked %d times, want %d", key, len(times), want.n)
+			continue
+		}
+		for i := 1; i < len(times); i++ {
+			if gap := times[i].Sub(times[i-1]); gap < want.interval || gap > want.interval*11/10+50*time.Millisecond {
+				t.Errorf("%s: check %d came %v after the previous one, want %v plus jitter", key, i+1, gap, want.interval)
+			}
+		}
+	}
+}
+
+func TestSchedulerLimitsConcurrentChecks(t *testing.T) {
+	const services, limit = 5, 2
+	var mu sync.Mutex
+	inFlight, maxInFlight, checked := 0, 0, 0
+	started := make(chan string, services)
+	release := make(chan struct{})
+
+	var list []*models.Service
+	for i := range services {
+		key := fmt.Sprintf("svc%d", i)
+		list = append(list, &models.Service{Key: key, Label: key, URL: "fake://" + key, Interval: time.Hour, Jitter: time.Second})
+	}
+	startScheduler(t, list, limit, func(ctx context.Context, s *models.Service) checker.Result {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		started <- s.Key
+
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		mu.Lock()
+		inFlight--
+		checked++
+		mu.Unlock()
+		return checker.Result{Status: checker.StatusUp}
+	})
+
+	for range limit {
+		select {
+		case <-started:
+		case <-time.After(3 * time.Second):
+			t.Fatal("checks did not start")
+		}
+	}
+	// Every first check is due within the 1s jitter; the others must wait
+	select {
+	case key := <-started:
+		t.Fatalf("%s started while %d checks were running", key, limit)
+	case <-time.After(1200 * time.Millisecond):
+	}
+	close(release)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		mu.Lock()
+		n, peak := checked, maxInFlight
+		mu.Unlock()
+		if n == services {
+			if peak != limit {
+				t.Errorf("at most %d checks ran at once, want %d", peak, limit)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d services checked", n, services)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,7 +1,6 @@
 package security
 
 import (
-	"database/sql"
 	"encoding/json"
 	"log"
 	"net"
@@ -11,6 +10,7 @@ import (
 	"status/app/internal/models"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -33,7 +33,10 @@ type rlEntry struct {
 	last   time.Time
 }
 
-var rl = map[string]*rlEntry{}
+var (
+	rlMu sync.Mutex
+	rl   = map[string]*rlEntry{}
+)
 
 // RateLimit implements token bucket rate limiting
 func RateLimit(next http.Handler) http.Handler {
@@ -61,6 +64,7 @@ func RateLimit(next http.Handler) http.Handler {
 			log.Printf("error checking IP block: %v", err)
 		}
 
+		rlMu.Lock()
 		e := rl[ip]
 		now := time.Now()
 		if e == nil {
@@ -75,11 +79,15 @@ func RateLimit(next http.Handler) http.Handler {
 			}
 			e.last = now
 		}
-		if e.tokens <= 0 {
+		allowed := e.tokens > 0
+		if allowed {
+			e.tokens--
+		}
+		rlMu.Unlock()
+		if !allowed {
 			http.Error(w, "too many requests", http.StatusTooManyRequests)
 			return
 		}
-		e.tokens--
 		next.ServeHTTP(w, r)
 	})
 }
@@ -128,111 +136,70 @@ func ClientIP(r *http.Request) string {
 	return r.RemoteAddr
 }
 
+// Failed logins from an address are counted for a day; more than
+// maxFailedLogins of them block it until a day after the last one
+const (
+	maxFailedLogins = 3
+	failedLoginTTL  = 24 * time.Hour
+)
+
 // GetIPBlock retrieves an IP block record if it exists and is active
 func GetIPBlock(ip string) (*models.BlockInfo, error) {
-	var block models.BlockInfo
-	err := database.DB.QueryRow(`SELECT ip_address, attempts, expires_at 
-		FROM ip_blocks 
-		WHERE ip_address = ? AND blocked_at IS NOT NULL AND expires_at > datetime('now')`, ip).
-		Scan(&block.IP, &block.Attempts, &block.ExpiresAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
+	b, err := database.GetIPBlock(ip)
+	if err != nil || b == nil {
 		return nil, err
 	}
-	return &block, nil
+	return &models.BlockInfo{IP: b.IP, Attempts: b.Attempts, ExpiresAt: b.ExpiresAt}, nil
 }
 
 // IsIPBlocked checks if an IP is currently blocked
 func IsIPBlocked(ip string) bool {
-	var blockedAt sql.NullString
-	err := database.DB.QueryRow(`SELECT blocked_at FROM ip_blocks 
-		WHERE ip_address = ? AND expires_at > datetime('now')`, ip).Scan(&blockedAt)
-
-	return err == nil && blockedAt.Valid // Only blocked if blocked_at is set
+	b, err := database.GetIPBlock(ip)
+	return err == nil && b != nil
 }
 
 // LogFailedLoginAttempt records a failed login attempt and blocks if threshold reached
 func LogFailedLoginAttempt(ip string) {
-	// First, delete any expired blocks for this IP
-	_, _ = database.DB.Exec(`DELETE FROM ip_blocks WHERE ip_address = ? AND expires_at <= datetime('now')`, ip)
-
-	// Check if there's an existing non-expired record
-	var attempts int
-	var blockedAt sql.NullString
-	err := database.DB.QueryRow(`SELECT attempts, blocked_at FROM ip_blocks 
-		WHERE ip_address = ?`, ip).Scan(&attempts, &blockedAt)
-
-	if err == nil {
-		newAttempts := attempts + 1
-		// Update existing record, block after 3 attempts
-		_, _ = database.DB.Exec(`UPDATE ip_blocks 
-			SET attempts = ?,
-				blocked_at = CASE WHEN ? > 3 THEN datetime('now') ELSE NULL END,
-				expires_at = datetime('now', '+24 hours'),
-				reason = 'Failed login attempts'
-			WHERE ip_address = ?`, newAttempts, newAttempts, ip)
-	} else {
-		// Create completely new record
-		_, _ = database.DB.Exec(`INSERT INTO ip_blocks (ip_address, blocked_at, attempts, expires_at, reason)
-			VALUES (?, NULL, 1, datetime('now', '+24 hours'), 'Failed login attempts')`,
-			ip)
+	if err := database.RecordFailedLogin(ip, maxFailedLogins, failedLoginTTL); err != nil {
+		log.Printf("security: record failed login from %s: %v", ip, err)
 	}
 }
 
 // ClearIPBlock removes an IP block
 func ClearIPBlock(ip string) error {
-	_, err := database.DB.Exec(`DELETE FROM ip_blocks WHERE ip_address = ?`, ip)
-	return err
+	return database.DeleteIPBlock(ip)
 }
 
 // ClearAllIPBlocks removes all IP blocks
 func ClearAllIPBlocks() (int64, error) {
-	result, err := database.DB.Exec("DELETE FROM ip_blocks")
-	if err != nil {
-		return 0, err
-	}
-	return result.RowsAffected()
+	return database.DeleteAllIPBlocks()
 }
 
 // ListBlockedIPs returns all currently blocked IPs
 func ListBlockedIPs() ([]map[string]interface{}, error) {
-	rows, err := database.DB.Query(`
-		SELECT ip_address, blocked_at, attempts, expires_at, reason 
-		FROM ip_blocks 
-		WHERE expires_at > datetime('now')
-		ORDER BY blocked_at DESC`)
+	blocks, err := database.ListIPBlocks()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	results := make([]map[string]interface{}, 0)
-	for rows.Next() {
-		var ip, expiresAt string
-		var blockedAt, reason sql.NullString
-		var attempts int
-		if err := rows.Scan(&ip, &blockedAt, &attempts, &expiresAt, &reason); err != nil {
-			continue
-		}
 
-		blockedAtStr := blockedAt.String
-		if !blockedAt.Valid {
-			blockedAtStr = expiresAt // fallback to expires_at if blocked_at is NULL
+	results := make([]map[string]interface{}, 0, len(blocks))
+	for _, b := range blocks {
+		blockedAt := b.BlockedAt
+		if blockedAt == "" {
+			blockedAt = b.ExpiresAt // fallback to expires_at if not blocked yet
 		}
 
-		reasonStr := reason.String
-		if !reason.Valid {
-			reasonStr = "Too many failed login attempts"
+		reason := b.Reason
+		if reason == "" {
+			reason = "Too many failed login attempts"
 		}
 
 		results = append(results, map[string]interface{}{
-			"ip":         ip,
-			"blocked_at": blockedAtStr,
-			"attempts":   attempts,
-			"expires_at": expiresAt,
-			"reason":     reasonStr,
+			"ip":         b.IP,
+			"blocked_at": blockedAt,
+			"attempts":   b.Attempts,
+			"expires_at": b.ExpiresAt,
+			"reason":     reason,
 		})
 	}
 	return results, nil
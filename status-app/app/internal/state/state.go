@@ -0,0 +1,144 @@
+package state
+
+import (
+	"fmt"
+	"status/app/internal/checker"
+	"status/app/internal/models"
+	"sync"
+	"time"
+)
+
+// ServiceState is the current state of a service as seen by its checks
+type ServiceState struct {
+	OK                   bool   // status after the service's status policy is applied
+	Degraded             bool   // only set while OK
+	Reason               string // why the service is degraded
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	CheckedAt            time.Time
+	Result               checker.Result // the last probe result
+}
+
+// entry is a service state plus the recent raw outcomes used by the
+// failure-rate rule
+type entry struct {
+	ServiceState
+	recent []bool
+}
+
+// Store owns the runtime state of every service. The scheduler and the HTTP
+// handlers record and read check results through it, so the service
+// definitions shared through the registry are never mutated.
+type Store struct {
+	mu     sync.RWMutex
+	states map[string]*entry
+	down   map[string]bool // down before a restart and not checked since
+}
+
+// New creates an empty state store
+func New() *Store {
+	return &Store{states: map[string]*entry{}, down: map[string]bool{}}
+}
+
+// MarkDown starts a service that was down before a restart from down
+// instead of up, so failing checks below the failure threshold don't
+// report it recovered
+func (s *Store) MarkDown(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, checked := s.states[key]; !checked {
+		s.down[key] = true
+	}
+}
+
+// Record applies a check result to the state of a service according to the
+// service's status policy and returns the new state. A service goes down
+// after FailureThreshold failed checks in a row and comes back up after
+// RecoveryThreshold passing checks in a row.
+func (s *Store) Record(svc *models.Service, res checker.Result, at time.Time) ServiceState {
+	p := svc.Policy.WithDefaults()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.states[svc.Key]
+	if e == nil {
+		e = &entry{ServiceState: ServiceState{OK: !s.down[svc.Key]}}
+		s.states[svc.Key] = e
+		delete(s.down, svc.Key)
+	}
+	if res.OK() {
+		e.ConsecutiveSuccesses++
+		e.ConsecutiveFailures = 0
+	} else {
+		e.ConsecutiveFailures++
+		e.ConsecutiveSuccesses = 0
+	}
+	switch {
+	case e.OK && e.ConsecutiveFailures >= p.FailureThreshold:
+		e.OK = false
+	case !e.OK && e.ConsecutiveSuccesses >= p.RecoveryThreshold:
+		e.OK = true
+	}
+
+	e.recent = append(e.recent, res.OK())
+	if n := len(e.recent) - p.DegradedWindow; n > 0 {
+		e.recent = append(e.recent[:0], e.recent[n:]...)
+	}
+
+	e.Reason = ""
+	if e.OK {
+		e.Reason = degradedReason(p, res, e.recent)
+	}
+	e.Degraded = e.Reason != ""
+	e.CheckedAt = at
+	e.Result = res
+	return e.ServiceState
+}
+
+// degradedReason explains why a passing service counts as degraded, or
+// returns "" if it doesn't
+func degradedReason(p models.StatusPolicy, res checker.Result, recent []bool) string {
+	if res.Degraded() {
+		if res.Message != "" {
+			return res.Message
+		}
+		return "the check passed with a warning"
+	}
+	if limit := *p.DegradedMS; limit > 0 && res.MS != nil && *res.MS > limit {
+		return fmt.Sprintf("response time %dms is over %dms", *res.MS, limit)
+	}
+	if p.DegradedWindow > 0 {
+		failed := 0
+		for _, ok := range recent {
+			if !ok {
+				failed++
+			}
+		}
+		// Checks not yet taken count as passed, so a fresh start isn't degraded
+		if failed*100 >= p.DegradedFailPct*p.DegradedWindow {
+			return fmt.Sprintf("%d of the last %d checks failed", failed, p.DegradedWindow)
+		}
+	}
+	return ""
+}
+
+// Get returns the current state of a service; ok is false if it hasn't
+// been checked yet
+func (s *Store) Get(key string) (st ServiceState, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.states[key]
+	if !ok {
+		return ServiceState{}, false
+	}
+	return e.ServiceState, true
+}
+
+// Delete forgets the state of a removed service
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	delete(s.down, key)
+}
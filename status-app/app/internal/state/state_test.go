@@ -0,0 +1,210 @@
+package state
+
+import (
+	"status/app/internal/checker"
+	"status/app/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	pass = checker.Result{Status: checker.StatusUp}
+	fail = checker.Result{Status: checker.StatusDown, Message: "connection refused"}
+)
+
+func withMS(res checker.Result, ms int) checker.Result {
+	res.MS = &ms
+	return res
+}
+
+func intPtr(n int) *int { return &n }
+
+// record feeds results to a store and returns the OK flag after each
+func record(st *Store, svc *models.Service, results ...checker.Result) []bool {
+	var out []bool
+	for _, res := range results {
+		out = append(out, st.Record(svc, res, time.Now()).OK)
+	}
+	return out
+}
+
+func TestRecordThresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  models.StatusPolicy
+		down    bool // marked down before the first check
+		results []checker.Result
+		want    []bool
+	}{
+		{
+			name:    "defaults: down after 2 failures, up after 1 pass",
+			results: []checker.Result{fail, fail, fail, pass, fail},
+			want:    []bool{true, false, false, true, true},
+		},
+		{
+			name:    "a pass resets the failure count",
+			policy:  models.StatusPolicy{FailureThreshold: 3},
+			results: []checker.Result{fail, fail, pass, fail, fail, fail},
+			want:    []bool{true, true, true, true, true, false},
+		},
+		{
+			name:    "recovery needs passes in a row",
+			policy:  models.StatusPolicy{FailureThreshold: 1, RecoveryThreshold: 3},
+			results: []checker.Result{fail, pass, pass, fail, pass, pass, pass, pass},
+			want:    []bool{false, false, false, false, false, false, true, true},
+		},
+		{
+			name:    "degraded checks count as passes",
+			policy:  models.StatusPolicy{FailureThreshold: 1, RecoveryThreshold: 2},
+			results: []checker.Result{fail, {Status: checker.StatusDegraded}, {Status: checker.StatusDegraded}},
+			want:    []bool{false, false, true},
+		},
+		{
+			name:    "down before a restart stays down below the recovery threshold",
+			policy:  models.StatusPolicy{RecoveryThreshold: 2},
+			down:    true,
+			results: []checker.Result{fail, pass, pass},
+			want:    []bool{false, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := New()
+			if tt.down {
+				st.MarkDown("plex")
+			}
+			got := record(st, &models.Service{Key: "plex", Policy: tt.policy}, tt.results...)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("OK after each check = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkDownOnlyBeforeTheFirstCheck(t *testing.T) {
+	st := New()
+	svc := &models.Service{Key: "plex"}
+	st.Record(svc, pass, time.Now())
+	st.MarkDown("plex")
+	if s := st.Record(svc, pass, time.Now()); !s.OK {
+		t.Error("MarkDown after a check took effect")
+	}
+
+	st.MarkDown("nas")
+	st.Delete("nas")
+	if s := st.Record(&models.Service{Key: "nas"}, pass, time.Now()); !s.OK {
+		t.Error("MarkDown survived Delete")
+	}
+}
+
+func TestRecordCounters(t *testing.T) {
+	st := New()
+	svc := &models.Service{Key: "plex"}
+	if _, ok := st.Get("plex"); ok {
+		t.Fatal("state before the first check")
+	}
+	at := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, res := range []checker.Result{pass, pass, fail, fail, fail} {
+		st.Record(svc, res, at)
+	}
+	s, ok := st.Get("plex")
+	if !ok || s.ConsecutiveFailures != 3 || s.ConsecutiveSuccesses != 0 || !s.CheckedAt.Equal(at) || s.Result.Message != "connection refused" {
+		t.Errorf("state = %+v, %v", s, ok)
+	}
+	st.Delete("plex")
+	if _, ok := st.Get("plex"); ok {
+		t.Error("state after Delete")
+	}
+}
+
+func TestDegradedResponseTime(t *testing.T) {
+	tests := []struct {
+		degradedMS *int
+		ms         int
+		reason     string
+	}{
+		{nil, 200, ""},
+		{nil, 201, "response time 201ms is over 200ms"}, // DefaultDegradedMS
+		{intPtr(500), 450, ""},
+		{intPtr(500), 600, "response time 600ms is over 500ms"},
+		{intPtr(0), 60000, ""}, // 0 turns the check off
+	}
+	for _, tt := range tests {
+		svc := &models.Service{Key: "plex", Policy: models.StatusPolicy{DegradedMS: tt.degradedMS}}
+		s := New().Record(svc, withMS(pass, tt.ms), time.Now())
+		if s.Reason != tt.reason || s.Degraded != (tt.reason != "") {
+			t.Errorf("degraded_ms %v, %dms: degraded %v %q, want %q", tt.degradedMS, tt.ms, s.Degraded, s.Reason, tt.reason)
+		}
+	}
+
+	// No response time, no response time rule
+	if s := New().Record(&models.Service{Key: "plex"}, pass, time.Now()); s.Degraded {
+		t.Errorf("degraded without a response time: %q", s.Reason)
+	}
+}
+
+func TestDegradedByTheCheck(t *testing.T) {
+	svc := &models.Service{Key: "cert", Policy: models.StatusPolicy{DegradedMS: intPtr(100)}}
+	for _, tt := range []struct {
+		res    checker.Result
+		reason string
+	}{
+		// The check's own warning wins over the response time
+		{withMS(checker.Result{Status: checker.StatusDegraded, Message: "certificate expires in 3 days"}, 500), "certificate expires in 3 days"},
+		{checker.Result{Status: checker.StatusDegraded}, "the check passed with a warning"},
+	} {
+		if s := New().Record(svc, tt.res, time.Now()); !s.OK || !s.Degraded || s.Reason != tt.reason {
+			t.Errorf("%+v: OK %v, degraded %v %q, want %q", tt.res, s.OK, s.Degraded, s.Reason, tt.reason)
+		}
+	}
+}
+
+func TestDegradedFailureRate(t *testing.T) {
+	// Degraded once half of the last 4 checks failed; failures alone never
+	// take it down here
+	svc := &models.Service{Key: "plex", Policy: models.StatusPolicy{FailureThreshold: 100, DegradedFailPct: 50, DegradedWindow: 4}}
+	st := New()
+	steps := []struct {
+		res    checker.Result
+		reason string
+	}{
+		{pass, ""},
+		{fail, ""},
+		{pass, ""},
+		{fail, "2 of the last 4 checks failed"},
+		{pass, "2 of the last 4 checks failed"},
+		{pass, ""}, // the first failure leaves the window
+		{pass, ""},
+		{fail, ""},
+		{fail, "2 of the last 4 checks failed"},
+		{fail, "3 of the last 4 checks failed"},
+	}
+	for i, step := range steps {
+		s := st.Record(svc, step.res, time.Now())
+		if s.Reason != step.reason {
+			t.Errorf("check %d: reason %q, want %q", i+1, s.Reason, step.reason)
+		}
+	}
+
+	// A fresh start counts the checks not yet taken as passed
+	st = New()
+	s := st.Record(&models.Service{Key: "nas", Policy: models.StatusPolicy{FailureThreshold: 5, DegradedFailPct: 30, DegradedWindow: 10}}, fail, time.Now())
+	if !s.OK || s.Degraded {
+		t.Errorf("first check failed: OK %v, degraded %q", s.OK, s.Reason)
+	}
+}
+
+func TestDegradedOnlyWhileUp(t *testing.T) {
+	svc := &models.Service{Key: "plex", Policy: models.StatusPolicy{FailureThreshold: 1, DegradedFailPct: 10, DegradedWindow: 5}}
+	st := New()
+	if s := st.Record(svc, fail, time.Now()); s.OK || s.Degraded || s.Reason != "" {
+		t.Errorf("down service reported degraded: %+v", s)
+	}
+	// Back up, but 1 of the last 5 checks failed
+	if s := st.Record(svc, pass, time.Now()); !s.OK || !strings.HasPrefix(s.Reason, "1 of the last 5") {
+		t.Errorf("after recovery: OK %v, reason %q", s.OK, s.Reason)
+	}
+}
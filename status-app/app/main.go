@@ -1,22 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"status/app/internal/alerts"
 	"status/app/internal/auth"
-	"status/app/internal/checker"
 	"status/app/internal/config"
 	"status/app/internal/database"
 	"status/app/internal/handlers"
+	"status/app/internal/maintenance"
 	"status/app/internal/models"
+	"status/app/internal/registry"
 	"status/app/internal/resources"
+	"status/app/internal/scheduler"
 	"status/app/internal/security"
+	"status/app/internal/state"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	// Load configuration from environment
 	cfg, err := config.Load()
 	if err != nil {
@@ -24,7 +39,7 @@ func main() {
 	}
 
 	// Initialize database
-	if err := database.Init(cfg.DBPath); err != nil {
+	if err := database.Init(cfg.DBDriver, cfg.DBSource); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
@@ -38,37 +53,98 @@ func main() {
 	)
 
 	// Create alert manager (loads config from database)
-	alertMgr := alerts.NewManager(cfg.StatusPageURL)
+	alertMgr := alerts.NewManager(cfg.StatusPageURL, cfg.HmacSecret)
 
-	// Convert service configs to service models
-	services := make([]*models.Service, 0, len(cfg.ServiceConfigs))
+	// Seed the service registry from env config on first run, unless the
+	// config file is managing services
+	seed := make([]*models.Service, 0, len(cfg.ServiceConfigs))
 	for _, sc := range cfg.ServiceConfigs {
-		svc := &models.Service{
+		seed = append(seed, &models.Service{
 			Key:     sc.Key,
 			Label:   sc.Label,
 			URL:     sc.URL,
 			Timeout: sc.Timeout,
 			MinOK:   sc.MinOK,
 			MaxOK:   sc.MaxOK,
+			Secrets: sc.Secrets,
+		})
+	}
+	if cfg.File == nil || cfg.File.Services == nil {
+		if err := database.SeedServices(seed); err != nil {
+			log.Fatalf("Failed to seed services: %v", err)
 		}
+	}
+
+	// Load services (and their disabled state) from the database
+	reg, err := registry.New()
+	if err != nil {
+		log.Fatalf("Failed to load services: %v", err)
+	}
+
+	gl := resources.NewClient(cfg.GlancesBaseURL)
+	var branding atomic.Pointer[config.Branding]
+	branding.Store(&config.Branding{Title: "Service Status", StatusPageURL: cfg.StatusPageURL})
+
+	// Stop the scheduler and server gracefully on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Apply the config file on top of env/database settings and watch it for changes
+	if cfg.File != nil {
+		applyConfigFile(cfg.File, reg, alertMgr, gl, &branding)
+		go config.WatchFile(ctx, cfg.ConfigFile, cfg.ConfigWatchPeriod, func(fc *config.FileConfig) {
+			applyConfigFile(fc, reg, alertMgr, gl, &branding)
+		})
+		log.Printf("Watching config file %s", cfg.ConfigFile)
+	}
 
-		// Load disabled state from database
-		if disabled, err := database.GetServiceDisabledState(sc.Key); err == nil {
-			svc.Disabled = disabled
+	// Live service state shared by the scheduler and the handlers. Services
+	// that were down stay down until they pass again.
+	store := state.New()
+	for _, svc := range reg.List() {
+		if h, err := database.LoadStatusHistory(svc.Key); err != nil {
+			log.Printf("Failed to load status of %s: %v", svc.Key, err)
+		} else if h != nil && !h.OK {
+			store.MarkDown(svc.Key)
 		}
+	}
 
-		services = append(services, svc)
+	// Maintenance windows suppress alerts and uptime penalties
+	maint, err := maintenance.New()
+	if err != nil {
+		log.Fatalf("Failed to load maintenance windows: %v", err)
 	}
 
 	// Start health check scheduler
+	schedDone := make(chan struct{})
 	if cfg.EnableScheduler {
-		go runScheduler(services, alertMgr, cfg.PollInterval)
-		log.Printf("Scheduler started with %v interval", cfg.PollInterval)
+		sched := scheduler.New(reg, alertMgr, store, maint, cfg.PollInterval, cfg.MaxConcurrent)
+		go func() {
+			sched.Run(ctx)
+			close(schedDone)
+		}()
+		log.Printf("Scheduler started with %v default interval, up to %d concurrent checks", cfg.PollInterval, cfg.MaxConcurrent)
+	} else {
+		close(schedDone)
 	}
 
+	// Deliver queued notifications, retrying failures
+	outboxDone := make(chan struct{})
+	go func() {
+		alertMgr.RunOutbox(ctx)
+		close(outboxDone)
+	}()
+
+	// Roll samples up into hourly and daily tiers and prune old data
+	ret := database.Retention{
+		Raw:    cfg.RawRetention,
+		Hourly: cfg.HourlyRetention,
+		Daily:  cfg.DailyRetention,
+	}
+	go runCompaction(ctx, ret)
+
 	// Setup HTTP routes
-	gl := resources.NewClient(cfg.GlancesBaseURL)
-	mux := handlers.SetupRoutes(authMgr, alertMgr, services, gl)
+	mux := handlers.SetupRoutes(authMgr, alertMgr, reg, store, maint, gl, ret, branding.Load)
 
 	// Wrap with security middleware
 	handler := security.SecureHeaders(mux)
@@ -82,49 +158,96 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	go func() {
+		<-ctx.Done()
+		log.Printf("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
 	log.Printf("Server starting on port %s", cfg.Port)
-	if err := srv.ListenAndServe(); err != nil {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Server failed: %v", err)
 	}
+	<-schedDone
+	<-outboxDone
 }
 
-// runScheduler runs health checks on a regular interval
-func runScheduler(services []*models.Service, alertMgr *alerts.Manager, interval time.Duration) {
-	ticker := time.NewTicker(interval)
+// runCompaction compacts the samples at startup and then every hour until
+// ctx is cancelled
+func runCompaction(ctx context.Context, ret database.Retention) {
+	ticker := time.NewTicker(time.Hour)
 	defer ticker.Stop()
+	for {
+		stats, err := database.Compact(time.Now(), ret)
+		if err != nil {
+			log.Printf("compaction: %v", err)
+		} else if stats != (database.CompactStats{}) {
+			log.Printf("compaction: rolled up %d hourly and %d daily buckets, pruned %d raw samples, %d hourly and %d daily buckets",
+				stats.HourlyBuckets, stats.DailyBuckets, stats.RawPruned, stats.HourlyPruned, stats.DailyPruned)
+		}
 
-	for range ticker.C {
-		for _, svc := range services {
-			if svc.Disabled {
-				continue
-			}
-
-			// Perform health check
-			checkOK, code, msPtr, errMsg := checker.HTTPCheck(svc.URL, svc.Timeout, svc.MinOK, svc.MaxOK)
-
-			// Track consecutive failures - service is only DOWN after 2 consecutive failures
-			if checkOK {
-				svc.ConsecutiveFailures = 0
-			} else {
-				svc.ConsecutiveFailures++
-			}
-
-			// Service is considered OK if check passed OR if we haven't hit 2 consecutive failures yet
-			ok := checkOK || svc.ConsecutiveFailures < 2
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
 
-			// Record sample in database (use the adjusted ok status)
-			database.InsertSample(time.Now(), svc.Key, ok, code, msPtr)
+// applyConfigFile applies the sections present in the config file to the
+// running registry, alert manager, Glances client and branding
+func applyConfigFile(fc *config.FileConfig, reg *registry.Registry, alertMgr *alerts.Manager, gl *resources.Client, branding *atomic.Pointer[config.Branding]) {
+	if fc.Services != nil {
+		added, updated, removed, err := reg.ApplyFileServices(fc.ServiceModels())
+		if err != nil {
+			log.Printf("config: apply services: %v", err)
+		} else if len(added)+len(updated)+len(removed) > 0 {
+			log.Printf("config: services added=%v updated=%v removed=%v", added, updated, removed)
+		}
+	}
 
-			// Check if service is degraded (slow response)
-			degraded := ok && msPtr != nil && *msPtr > 200
+	if fc.Alerts != nil {
+		if err := applyFileAlerts(fc.Alerts, alertMgr); err != nil {
+			log.Printf("config: %v", err)
+		}
+	}
 
-			// Send alerts if status changed (based on adjusted ok status)
-			alertMgr.CheckAndSendAlerts(svc.Key, svc.Label, ok, degraded)
+	if fc.Resources != nil && fc.Resources.GlancesURL != "" {
+		gl.SetBaseURL(strings.TrimSuffix(fc.Resources.GlancesURL, "/"))
+	}
 
-			// Log if there was an error
-			if errMsg != "" {
-				log.Printf("Check %s: %s (failures: %d/2)", svc.Key, errMsg, svc.ConsecutiveFailures)
-			}
+	if fc.Branding != nil {
+		b := *branding.Load()
+		if fc.Branding.Title != "" {
+			b.Title = fc.Branding.Title
 		}
+		if fc.Branding.StatusPageURL != "" {
+			b.StatusPageURL = fc.Branding.StatusPageURL
+			alertMgr.SetStatusPageURL(b.StatusPageURL)
+		}
+		branding.Store(&b)
+	}
+}
+
+// applyFileAlerts saves the alert settings with the keys present in the
+// alerts section applied, keeping the others as set in the admin panel
+func applyFileAlerts(a *config.FileAlerts, alertMgr *alerts.Manager) error {
+	current, err := database.LoadAlertConfig()
+	if err != nil {
+		return fmt.Errorf("load alert config: %w", err)
+	}
+	if current == nil {
+		current = models.DefaultAlertConfig()
+	}
+	ac, err := a.MergeAlertConfig(current)
+	if err != nil {
+		return err
+	}
+	if err := database.SaveAlertConfig(ac); err != nil {
+		return fmt.Errorf("save alert config: %w", err)
 	}
+	alertMgr.SetConfig(ac)
+	return nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"status/app/internal/config"
+	"status/app/internal/database"
+)
+
+const migrateUsage = `usage: status migrate [status|up]
+
+  status  show applied and pending migrations (default)
+  up      apply pending migrations
+`
+
+// runMigrate implements the migrate subcommand and returns the exit code
+func runMigrate(args []string) int {
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	if len(args) > 1 || (cmd != "status" && cmd != "up") {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err := database.Open(config.Database()); err != nil {
+		fmt.Fprintf(os.Stderr, "open database: %v\n", err)
+		return 1
+	}
+
+	if cmd == "up" {
+		applied, err := database.Migrate()
+		for _, m := range applied {
+			fmt.Printf("applied %d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return 0
+	}
+
+	all, err := database.Migrations()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	version, pending := 0, 0
+	for _, m := range all {
+		state := "pending"
+		if !m.AppliedAt.IsZero() {
+			state = "applied " + m.AppliedAt.Local().Format(time.DateTime)
+			version = m.Version
+		} else {
+			pending++
+		}
+		fmt.Printf("%4d  %-24s %s\n", m.Version, m.Name, state)
+	}
+	fmt.Printf("\nschema version %d, %d pending\n", version, pending)
+	return 0
+}
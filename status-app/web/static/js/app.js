@@ -1,6 +1,10 @@
 const REFRESH_MS = 15000;
 let DAYS = 30;
 let resourcesConfig = null; // Cache the config
+let SERVICE_KEYS = ['server', 'plex', 'overseerr']; // Updated from /api/check
+let SERVICE_LABELS = {}; // key -> label, updated from /api/check
+const INCIDENT_STATUSES = ['investigating', 'identified', 'monitoring', 'resolved'];
+const INCIDENT_IMPACTS = ['none', 'minor', 'major', 'critical'];
 const $ = (s,r=document) => r.querySelector(s);
 const $$ = (s,r=document) => Array.from(r.querySelectorAll(s));
 const fmtMs = ms => ms==null ? '—' : ms+' ms';
@@ -416,6 +420,22 @@ function updCard(id,data) {
     return;
   }
 
+  if (data.maintenance) {
+    pill.textContent = 'MAINTENANCE';
+    pill.className = 'pill maint';
+    k.textContent = fmtMs(data.ms);
+    h.textContent = 'Scheduled maintenance';
+    return;
+  }
+
+  if (data.pending) {
+    pill.textContent = 'PENDING';
+    pill.className = 'pill warn';
+    k.textContent = '—';
+    h.textContent = 'Waiting for first check';
+    return;
+  }
+
   if (data.degraded) {
     pill.textContent = 'DEGRADED';
   } else {
@@ -425,11 +445,11 @@ function updCard(id,data) {
   k.textContent = fmtMs(data.ms);
   h.textContent = data.status ? ('HTTP '+data.status) : 'no response';
   
-  // Update last check time
+  // Update last check time (results come from the scheduler, not this request)
   const lastCheckEl = $(`#last-check-${id.split('-').pop()}`);
   if (lastCheckEl) {
-    const now = new Date();
-    lastCheckEl.textContent = now.toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' });
+    const checked = data.checked_at ? new Date(data.checked_at) : new Date();
+    lastCheckEl.textContent = checked.toLocaleTimeString('en-US', { hour: '2-digit', minute: '2-digit' });
   }
 }
 
@@ -455,7 +475,7 @@ async function toggleMonitoring(card, enabled) {
 let chart;
 function renderChart(overall) {
   if(!window.Chart) return;
-  const labels = ['server','plex','overseerr'];
+  const labels = SERVICE_KEYS;
   const vals = labels.map(k => +(overall?.[k]??0).toFixed(1));
   const ctx = document.getElementById('uptimeChart').getContext('2d');
   const data = {labels, datasets:[{label:'Uptime %',data:vals,borderWidth:1}]};
@@ -477,6 +497,18 @@ function renderChart(overall) {
   });
 }
 
+function escapeHtml(s) {
+  return String(s ?? '').replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c]));
+}
+
+function fmtDuration(secs) {
+  if (secs < 60) return `${secs}s`;
+  if (secs < 3600) return `${Math.round(secs / 60)}m`;
+  const h = Math.floor(secs / 3600);
+  const m = Math.round((secs % 3600) / 60);
+  return m ? `${h}h ${m}m` : `${h}h`;
+}
+
 function renderIncidents(items) {
   const list = $('#incidents');
   if(!items?.length) {
@@ -485,13 +517,62 @@ function renderIncidents(items) {
   }
   
   list.innerHTML = items.map(i => {
-    const ts = new Date(i.taken_at).toLocaleString();
-    return `<li><span class="dot"></span><span>${ts}</span><span class="label"> — ${i.service_key} (${i.http_status||'n/a'})</span></li>`;
+    const ts = new Date(i.started_at).toLocaleString();
+    const state = i.resolved_at ? `resolved after ${fmtDuration(i.duration_seconds)}` : `ongoing for ${fmtDuration(i.duration_seconds)}`;
+    return `<li><span class="dot"></span><span>${ts}</span><span class="label"> — ${escapeHtml(i.title)} (${state})</span></li>`;
   }).join('');
 }
 
+const capitalize = s => s ? s.charAt(0).toUpperCase() + s.slice(1) : '';
+
+function renderIncidentUpdates(updates) {
+  return `<ul class="incident-updates">${(updates || []).map(u => `
+    <li>
+      <span class="incident-update-status status-${u.status}">${capitalize(u.status)}</span>
+      <span>${escapeHtml(u.message)}</span>
+      <span class="muted">${new Date(u.created_at).toLocaleString()}</span>
+    </li>`).join('')}</ul>`;
+}
+
+function incidentMeta(i) {
+  const services = (i.services || []).map(s => escapeHtml(s.label)).join(', ') || 'No specific service';
+  const duration = i.resolved_at ? `lasted ${fmtDuration(i.duration_seconds)}` : `ongoing for ${fmtDuration(i.duration_seconds)}`;
+  return `${services} · ${capitalize(i.impact)} impact · ${duration}`;
+}
+
+// Public incident history, grouped by the day each incident started
+function renderIncidentHistory(items) {
+  const container = $('#incidentHistory');
+  if (!container) return;
+  if (!items?.length) {
+    container.innerHTML = '<div class="label">No incidents in the last 90 days</div>';
+    return;
+  }
+
+  const days = new Map();
+  items.forEach(i => {
+    const day = new Date(i.started_at).toLocaleDateString(undefined, { year: 'numeric', month: 'long', day: 'numeric' });
+    if (!days.has(day)) days.set(day, []);
+    days.get(day).push(i);
+  });
+
+  container.innerHTML = Array.from(days, ([day, incidents]) => `
+    <div class="incident-day">
+      <div class="incident-day-title">${day}</div>
+      ${incidents.map(i => `
+        <div class="incident impact-${i.impact}">
+          <div class="incident-head">
+            <span class="incident-title">${escapeHtml(i.title)}</span>
+            <span class="incident-update-status status-${i.status}">${capitalize(i.status)}</span>
+          </div>
+          <div class="incident-meta muted">${incidentMeta(i)}</div>
+          ${renderIncidentUpdates(i.updates)}
+        </div>`).join('')}
+    </div>`).join('');
+}
+
 function updateServiceStats(metrics) {
-  const services = ['server', 'plex', 'overseerr'];
+  const services = SERVICE_KEYS;
   
   services.forEach(key => {
     const uptimeEl = $(`#uptime-24h-${key}`);
@@ -528,9 +609,24 @@ function updateServiceStats(metrics) {
   });
 }
 
+// Average HTTP latency breakdown from /api/metrics, e.g. "DNS 2ms · Connect 1ms · ..."
+function formatPhases(p) {
+  const parts = [
+    ['DNS', p.dns_ms],
+    ['Connect', p.connect_ms],
+    ['TLS', p.tls_ms],
+    ['Server', p.ttfb_ms],
+    ['Transfer', p.transfer_ms],
+  ];
+  return parts
+    .filter(([, ms]) => ms > 0)
+    .map(([name, ms]) => `${name} ${Math.round(ms)}ms`)
+    .join(' · ');
+}
+
 function renderUptimeBars(metrics, days) {
   const daysToShow = days || DAYS;
-  const services = ['server', 'plex', 'overseerr'];
+  const services = SERVICE_KEYS;
   const now = new Date();
   const daysAgo = now.getTime() - (daysToShow * 24 * 60 * 60 * 1000);
   
@@ -611,7 +707,11 @@ function renderUptimeBars(metrics, days) {
       });
       
       let tooltipText = '';
-      if (uptime === null || uptime === undefined) {
+      if ((uptime === null || uptime === undefined) && point.maintenance_samples) {
+        // The whole day was spent in scheduled maintenance
+        block.classList.add('maintenance');
+        tooltipText = `${formattedDate}\n🔧 Scheduled maintenance`;
+      } else if (uptime === null || uptime === undefined) {
         block.classList.add('unknown');
         tooltipText = `${formattedDate}\nNo data available`;
       } else if (uptime >= 100) {
@@ -628,6 +728,13 @@ function renderUptimeBars(metrics, days) {
         tooltipText = `${formattedDate}\n${uptime.toFixed(1)}% uptime\n✗ Major outage`;
       }
       
+      if (point.maintenance_samples && !block.classList.contains('maintenance')) {
+        tooltipText += '\n🔧 Includes scheduled maintenance';
+      }
+      if (point.phases) {
+        tooltipText += `\n${formatPhases(point.phases)}`;
+      }
+
       block.title = tooltipText;
       block.setAttribute('data-tooltip', tooltipText);
       
@@ -692,19 +799,78 @@ function showMobileTooltip(element, text, touch) {
   }, 2000);
 }
 
+// Build cards and uptime rows for services that aren't in the static markup,
+// using the Server card as a template, and hide ones that were removed.
+function syncServiceElements(order, status) {
+  if (!Array.isArray(order)) return;
+  SERVICE_KEYS = order;
+  order.forEach(key => { SERVICE_LABELS[key] = status[key]?.label || key; });
+
+  const cardTemplate = $('#card-server');
+  const uptimeTemplate = $('.service-uptime[data-key="server"]');
+  const incidentsCard = $('#incidents')?.closest('.card');
+  const uptimeFooter = $('.uptime-footer');
+  const scopeSelect = $('#bannerService');
+
+  order.forEach(key => {
+    const label = status[key]?.label || key;
+
+    if (!document.getElementById('card-' + key) && cardTemplate && incidentsCard) {
+      const card = cardTemplate.cloneNode(true);
+      card.id = 'card-' + key;
+      card.setAttribute('data-key', key);
+      $('.icon', card).alt = label;
+      $('strong', card).textContent = label;
+      $('.row-left .label', card).textContent = 'Service';
+      ['uptime-24h', 'avg-response', 'last-check'].forEach(prefix => {
+        const el = $(`#${prefix}-server`, card);
+        if (el) {
+          el.id = `${prefix}-${key}`;
+          el.textContent = '—';
+        }
+      });
+      $$('.service-alert', card).forEach(el => el.remove());
+      $('.checkNow', card).addEventListener('click', () => checkNowFor(card));
+      $('.monitorToggle', card).addEventListener('change', (e) => toggleMonitoring(card, e.target.checked));
+      incidentsCard.parentNode.insertBefore(card, incidentsCard);
+    }
+
+    if (!$(`.service-uptime[data-key="${key}"]`) && uptimeTemplate && uptimeFooter) {
+      const row = uptimeTemplate.cloneNode(true);
+      row.setAttribute('data-key', key);
+      $('.service-name', row).textContent = label;
+      $('.uptime-percent', row).id = 'uptime-' + key;
+      $('.uptime-bar', row).id = 'uptime-bar-' + key;
+      $('.uptime-bar', row).innerHTML = '';
+      uptimeFooter.parentNode.insertBefore(row, uptimeFooter);
+    }
+
+    if (scopeSelect && !scopeSelect.querySelector(`option[value="${key}"]`)) {
+      const opt = document.createElement('option');
+      opt.value = key;
+      opt.textContent = label;
+      scopeSelect.appendChild(opt);
+    }
+  });
+
+  $$('.card[data-key], .service-uptime[data-key]').forEach(el => {
+    el.classList.toggle('hidden', !order.includes(el.getAttribute('data-key')));
+  });
+}
+
 async function refresh() {
   try {
     const live = await j('/api/check');
     $('#updated').textContent = new Date(live.t).toLocaleString();
-    updCard('card-server', live.status.server || {});
-    updCard('card-plex', live.status.plex || {});
-    updCard('card-overseerr', live.status.overseerr || {});
+    syncServiceElements(live.order, live.status);
+    SERVICE_KEYS.forEach(key => updCard('card-' + key, live.status[key] || {}));
   } catch (e) {
     console.error('live check failed', e);
   }
 
   // Resources (Glances)
   refreshResources();
+  loadMaintenanceNotice();
 
   try {
     const metrics = await j(`/api/metrics?days=${DAYS}`);
@@ -716,7 +882,16 @@ async function refresh() {
       // Chart rendering failed - silent failure
     }
     
-    renderIncidents(metrics.downs || []);
+    try {
+      const inc = await j('/api/incidents?days=90');
+      const dayAgo = Date.now() - 86400000;
+      const recent = (inc.incidents || []).filter(i => !i.resolved_at || new Date(i.resolved_at) >= dayAgo);
+      renderIncidents(recent);
+      renderIncidentHistory(inc.incidents || []);
+    } catch (incErr) {
+      renderIncidents([]);
+      renderIncidentHistory([]);
+    }
     renderUptimeBars(metrics, DAYS);
     
     // Fetch 24h stats for the service cards
@@ -908,7 +1083,8 @@ async function saveAlertsConfig() {
     from_email: $('#alertFromEmail').value,
     alert_on_down: $('#alertOnDown').checked,
     alert_on_degraded: $('#alertOnDegraded').checked,
-    alert_on_up: $('#alertOnUp').checked
+    alert_on_up: $('#alertOnUp').checked,
+    reminder_minutes: parseInt($('#reminderMinutes').value) || 0
   };
   
   await handleButtonAction(
@@ -951,6 +1127,7 @@ async function sendTestEmail() {
     },
     'Test email sent'
   );
+  loadDeliveryLog();
 }
 
 async function loadAlertsConfig() {
@@ -967,6 +1144,7 @@ async function loadAlertsConfig() {
       $('#alertOnDown').checked = config.alert_on_down !== false;
       $('#alertOnDegraded').checked = config.alert_on_degraded !== false;
       $('#alertOnUp').checked = config.alert_on_up || false;
+      $('#reminderMinutes').value = config.reminder_minutes || '';
     }
   } catch (err) {
     // No alerts config available
@@ -1003,7 +1181,21 @@ async function checkNowFor(card) {
   );
 }
 
+async function loadBranding() {
+  try {
+    const b = await j('/api/branding');
+    if (b.title) {
+      document.title = b.title;
+      $('.brand h1').textContent = b.title;
+    }
+  } catch (e) {
+    // Keep the default branding
+  }
+}
+
 window.addEventListener('load', async () => {
+  loadBranding();
+
   // IMPORTANT: Load resources config FIRST before any refresh calls.
   // This prevents hidden tiles from briefly appearing due to race conditions.
   await loadResourcesConfig();
@@ -1104,6 +1296,13 @@ window.addEventListener('load', async () => {
         document.dispatchEvent(event);
       } else if (tabName === 'banners') {
         loadAdminBanners();
+      } else if (tabName === 'incidents') {
+        loadAdminIncidents();
+      } else if (tabName === 'maintenance') {
+        loadAdminMaintenance();
+      } else if (tabName === 'alerts') {
+        loadAdminChannels().then(loadAlertRules);
+        loadDeliveryLog();
       }
     });
   });
@@ -1169,10 +1368,690 @@ window.addEventListener('load', async () => {
     });
   }
   
+  // Incident management
+  fillIncidentSelect($('#incidentStatus'), INCIDENT_STATUSES, 'investigating');
+  fillIncidentSelect($('#incidentImpact'), INCIDENT_IMPACTS, 'minor');
+  const createIncidentBtn = $('#createIncident');
+  if (createIncidentBtn) {
+    createIncidentBtn.addEventListener('click', createIncident);
+  }
+
+  // Maintenance windows
+  const maintRepeat = $('#maintRepeat');
+  if (maintRepeat) {
+    maintRepeat.addEventListener('change', () => {
+      $('#maintRuleGroup').classList.toggle('hidden', !maintRepeat.value);
+    });
+  }
+  const createMaintenanceBtn = $('#createMaintenance');
+  if (createMaintenanceBtn) {
+    createMaintenanceBtn.addEventListener('click', createMaintenance);
+  }
+
+  // Notification channels
+  const channelType = $('#channelType');
+  if (channelType) {
+    channelType.addEventListener('change', showChannelFields);
+    showChannelFields();
+  }
+  const createChannelBtn = $('#createChannel');
+  if (createChannelBtn) {
+    createChannelBtn.addEventListener('click', createChannel);
+  }
+  const addRuleStepBtn = $('#addRuleStep');
+  if (addRuleStepBtn) {
+    addRuleStepBtn.addEventListener('click', () => addRuleStep());
+  }
+  const createRuleBtn = $('#createRule');
+  if (createRuleBtn) {
+    createRuleBtn.addEventListener('click', createAlertRule);
+  }
+  const refreshDeliveriesBtn = $('#refreshDeliveries');
+  if (refreshDeliveriesBtn) {
+    refreshDeliveriesBtn.addEventListener('click', loadDeliveryLog);
+  }
+
   // Load banners on page load
   loadBanners();
 });
 
+/* Notification Channel Functions */
+const CHANNEL_INPUTS = {
+  url: '#channelURL',
+  server: '#channelServer',
+  token: '#channelToken',
+  user: '#channelUser',
+  chat_id: '#channelChatID',
+  topic: '#channelTopic',
+  room_id: '#channelRoomID',
+  secret: '#channelSecret',
+  template: '#channelTemplate'
+};
+
+// parseHeaderLines reads "Name: value" lines into an object
+function parseHeaderLines(text) {
+  const headers = {};
+  text.split('\n').forEach(line => {
+    const i = line.indexOf(':');
+    if (i > 0) headers[line.slice(0, i).trim()] = line.slice(i + 1).trim();
+  });
+  return headers;
+}
+
+function showChannelFields() {
+  const type = $('#channelType').value;
+  $$('.channel-field').forEach(el => {
+    el.classList.toggle('hidden', !el.dataset.types.split(' ').includes(type));
+  });
+}
+
+// Notification channels as last loaded, for the rule editor
+let adminChannels = [];
+
+async function loadAdminChannels() {
+  const list = $('#channelList');
+  if (!list) return;
+  try {
+    const res = await j('/api/admin/notifications/channels', {
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    const channels = res.channels || [];
+    adminChannels = channels;
+    if (channels.length === 0) {
+      list.innerHTML = '<div class="muted">No notification channels</div>';
+      return;
+    }
+
+    list.innerHTML = '';
+    channels.forEach(ch => {
+      const target = ch.settings.topic || ch.settings.chat_id || ch.settings.room_id || ch.settings.server || ch.settings.url || '';
+      const div = document.createElement('div');
+      div.className = 'banner-item';
+      div.innerHTML = `
+        <span class="banner-item-level ${ch.enabled ? 'info' : 'warning'}">${escapeHtml(ch.type.toUpperCase())}</span>
+        <div class="banner-item-content">
+          <span class="banner-item-msg">${escapeHtml(ch.name)}</span>
+          <span class="banner-item-service">${ch.enabled ? 'Enabled' : 'Disabled'}${target ? ` · ${escapeHtml(target)}` : ''}</span>
+        </div>
+        <button class="btn ghost channel-toggle">${ch.enabled ? 'Disable' : 'Enable'}</button>
+        <button class="btn ghost channel-test">Test</button>
+        <button class="banner-delete">Delete</button>
+      `;
+      $('.channel-toggle', div).addEventListener('click', () => toggleChannel(ch));
+      $('.channel-test', div).addEventListener('click', e => testChannel(ch, e.currentTarget));
+      $('.banner-delete', div).addEventListener('click', () => deleteChannel(ch));
+      list.appendChild(div);
+    });
+  } catch (e) {
+    console.error('Failed to load notification channels', e);
+  }
+}
+
+async function loadDeliveryLog() {
+  const list = $('#deliveryList');
+  if (!list) return;
+  try {
+    const res = await j('/api/admin/notifications/attempts?limit=50', {
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    const attempts = res.attempts || [];
+    if (attempts.length === 0) {
+      list.innerHTML = '<div class="muted">No notifications sent yet</div>';
+      return;
+    }
+
+    list.innerHTML = attempts.map(a => {
+      const failed = a.status === 'failed';
+      const outcome = !failed ? '' : a.delivery === 'pending' ? ' · will retry' : a.delivery === 'failed' ? ' · gave up' : '';
+      return `
+        <div class="banner-item">
+          <span class="banner-item-level ${failed ? 'error' : 'info'}">${failed ? 'FAILED' : 'SENT'}</span>
+          <div class="banner-item-content">
+            <span class="banner-item-msg">${escapeHtml(a.channel)} · ${escapeHtml(a.event)} ${escapeHtml(a.service_key)}</span>
+            <span class="banner-item-service">${new Date(a.attempted_at).toLocaleString()} · attempt ${a.attempt}${outcome}${a.error ? ` · ${escapeHtml(a.error)}` : ''}</span>
+          </div>
+        </div>
+      `;
+    }).join('');
+  } catch (e) {
+    console.error('Failed to load delivery log', e);
+  }
+}
+
+/* Alert Rule Functions */
+function channelLabel(id) {
+  if (id === 0) return 'Email';
+  const ch = adminChannels.find(c => c.id === id);
+  return ch ? ch.name : `Channel ${id}`;
+}
+
+function addRuleStep(step = { after_minutes: 0, channels: [], recipients: [] }) {
+  const container = $('#ruleSteps');
+  const row = document.createElement('div');
+  row.className = 'rule-step';
+  const options = [{ id: 0 }, ...adminChannels].map(ch => `
+    <label><input type="checkbox" value="${ch.id}"${(step.channels || []).includes(ch.id) ? ' checked' : ''}> ${escapeHtml(channelLabel(ch.id))}</label>
+  `).join('');
+  row.innerHTML = `
+    <div class="form-group">
+      <label>After (minutes, 0 = on the change)</label>
+      <input type="number" class="rule-step-after" min="0" value="${step.after_minutes}" />
+    </div>
+    <div class="incident-services rule-step-channels">${options}</div>
+    <div class="form-group">
+      <label>Email recipients (optional, comma separated)</label>
+      <input type="text" class="rule-step-recipients" value="${escapeHtml((step.recipients || []).join(', '))}" placeholder="oncall@example.com" />
+    </div>
+    <button type="button" class="banner-delete">Remove Step</button>
+  `;
+  $('.banner-delete', row).addEventListener('click', () => row.remove());
+  container.appendChild(row);
+}
+
+function renderRuleServicePicker() {
+  const container = $('#ruleServices');
+  if (!container) return;
+  const checked = new Set($$('input:checked', container).map(el => el.value));
+  container.innerHTML = SERVICE_KEYS.map(key => `
+    <label><input type="checkbox" value="${escapeHtml(key)}"${checked.has(key) ? ' checked' : ''}> ${escapeHtml(SERVICE_LABELS[key] || key)}</label>
+  `).join('');
+}
+
+async function loadAlertRules() {
+  renderRuleServicePicker();
+  const steps = $('#ruleSteps');
+  if (steps && !steps.children.length) addRuleStep();
+  const list = $('#ruleList');
+  if (!list) return;
+  try {
+    const res = await j('/api/admin/alerts/rules', {
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    const rules = res.rules || [];
+    if (rules.length === 0) {
+      list.innerHTML = '<div class="muted">No routing rules; alerts go to email and every enabled channel</div>';
+      return;
+    }
+
+    list.innerHTML = '';
+    rules.forEach(rule => {
+      const services = rule.services.length ? rule.services.map(k => escapeHtml(SERVICE_LABELS[k] || k)).join(', ') : 'All services';
+      const severities = rule.severities.length ? rule.severities.join(', ') : 'all severities';
+      const steps = rule.steps.map(step => {
+        const to = [...(step.channels || []).map(channelLabel), ...(step.recipients || [])].map(escapeHtml).join(', ');
+        return `${step.after_minutes ? `after ${step.after_minutes}m` : 'immediately'} → ${to}`;
+      }).join('; ');
+      const div = document.createElement('div');
+      div.className = 'banner-item';
+      div.innerHTML = `
+        <span class="banner-item-level ${rule.enabled ? 'info' : 'warning'}">${rule.enabled ? 'RULE' : 'DISABLED'}</span>
+        <div class="banner-item-content">
+          <span class="banner-item-msg">${escapeHtml(rule.name)}</span>
+          <span class="banner-item-service">${services} · ${escapeHtml(severities)} · ${steps}</span>
+        </div>
+        <button class="btn ghost rule-toggle">${rule.enabled ? 'Disable' : 'Enable'}</button>
+        <button class="banner-delete">Delete</button>
+      `;
+      $('.rule-toggle', div).addEventListener('click', () => saveAlertRule({ ...rule, enabled: !rule.enabled }, 'PUT'));
+      $('.banner-delete', div).addEventListener('click', () => deleteAlertRule(rule));
+      list.appendChild(div);
+    });
+  } catch (e) {
+    console.error('Failed to load alert rules', e);
+  }
+}
+
+async function saveAlertRule(rule, method) {
+  try {
+    await j('/api/admin/alerts/rules', {
+      method,
+      headers: {
+        'Content-Type': 'application/json',
+        'X-CSRF-Token': getCsrf()
+      },
+      body: JSON.stringify(rule)
+    });
+    showToast(method === 'POST' ? 'Routing rule added' : 'Routing rule updated');
+    loadAlertRules();
+    return true;
+  } catch (e) {
+    console.error('Failed to save alert rule', e);
+    showToast(typeof e.body === 'string' && e.body.trim() ? e.body.trim() : 'Failed to save routing rule', 'error');
+    return false;
+  }
+}
+
+async function createAlertRule() {
+  const name = $('#ruleName').value.trim();
+  if (!name) {
+    alert('Please enter a name');
+    return;
+  }
+  const steps = $$('#ruleSteps .rule-step').map(row => ({
+    after_minutes: parseInt($('.rule-step-after', row).value, 10) || 0,
+    channels: $$('.rule-step-channels input:checked', row).map(el => parseInt(el.value, 10)),
+    recipients: $('.rule-step-recipients', row).value.split(',').map(r => r.trim()).filter(Boolean)
+  }));
+  const ok = await saveAlertRule({
+    name,
+    enabled: true,
+    services: $$('#ruleServices input:checked').map(el => el.value),
+    severities: $$('#ruleSeverities input:checked').map(el => el.value),
+    steps
+  }, 'POST');
+  if (ok) {
+    $('#ruleName').value = '';
+    $('#ruleSteps').innerHTML = '';
+    addRuleStep();
+  }
+}
+
+async function deleteAlertRule(rule) {
+  if (!confirm(`Delete the routing rule "${rule.name}"?`)) return;
+  try {
+    await j(`/api/admin/alerts/rules?id=${rule.id}`, {
+      method: 'DELETE',
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    showToast('Routing rule deleted');
+    loadAlertRules();
+  } catch (e) {
+    console.error('Failed to delete alert rule', e);
+    showToast('Failed to delete routing rule', 'error');
+  }
+}
+
+async function createChannel() {
+  const name = $('#channelName').value.trim();
+  if (!name) {
+    alert('Please enter a name');
+    return;
+  }
+  const type = $('#channelType').value;
+  const settings = {};
+  for (const [key, sel] of Object.entries(CHANNEL_INPUTS)) {
+    const input = $(sel);
+    if (!input.closest('.channel-field').classList.contains('hidden') && input.value.trim()) {
+      settings[key] = key === 'template' ? input.value : input.value.trim();
+    }
+  }
+  if (type === 'webhook') {
+    const headers = parseHeaderLines($('#channelHeaders').value);
+    if (Object.keys(headers).length) settings.headers = headers;
+  }
+
+  try {
+    await j('/api/admin/notifications/channels', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'X-CSRF-Token': getCsrf()
+      },
+      body: JSON.stringify({ name, type, enabled: true, settings })
+    });
+    $('#channelName').value = '';
+    Object.values(CHANNEL_INPUTS).forEach(sel => { $(sel).value = ''; });
+    $('#channelHeaders').value = '';
+    showToast('Notification channel added');
+    loadAdminChannels();
+  } catch (e) {
+    console.error('Failed to add notification channel', e);
+    showToast(typeof e.body === 'string' && e.body.trim() ? e.body.trim() : 'Failed to add notification channel', 'error');
+  }
+}
+
+async function toggleChannel(ch) {
+  try {
+    // Masked secrets are sent back as-is and keep their stored value
+    await j('/api/admin/notifications/channels', {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+        'X-CSRF-Token': getCsrf()
+      },
+      body: JSON.stringify({ ...ch, enabled: !ch.enabled })
+    });
+    showToast(ch.enabled ? 'Notification channel disabled' : 'Notification channel enabled');
+    loadAdminChannels();
+  } catch (e) {
+    console.error('Failed to update notification channel', e);
+    showToast('Failed to update notification channel', 'error');
+  }
+}
+
+async function testChannel(ch, btn) {
+  btn.disabled = true;
+  btn.classList.add('loading');
+  try {
+    const result = await j(`/api/admin/notifications/test?id=${ch.id}`, {
+      method: 'POST',
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    showToast(result.message || 'Test notification sent');
+  } catch (e) {
+    console.error('Failed to send test notification', e);
+    showToast((e.body && e.body.message) || 'Failed to send test notification', 'error');
+  } finally {
+    btn.disabled = false;
+    btn.classList.remove('loading');
+    loadDeliveryLog();
+  }
+}
+
+async function deleteChannel(ch) {
+  if (!confirm(`Delete the notification channel "${ch.name}"?`)) return;
+  try {
+    await j(`/api/admin/notifications/channels?id=${ch.id}`, {
+      method: 'DELETE',
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    showToast('Notification channel deleted');
+    loadAdminChannels();
+  } catch (e) {
+    console.error('Failed to delete notification channel', e);
+    showToast('Failed to delete notification channel', 'error');
+  }
+}
+
+/* Maintenance Functions */
+function fmtMaintenanceRange(m) {
+  const start = new Date(m.starts_at);
+  const end = new Date(m.ends_at);
+  const opts = { weekday: 'short', month: 'short', day: 'numeric', hour: '2-digit', minute: '2-digit' };
+  const sameDay = start.toDateString() === end.toDateString();
+  const endStr = sameDay ? end.toLocaleTimeString(undefined, { hour: '2-digit', minute: '2-digit' }) : end.toLocaleString(undefined, opts);
+  return `${start.toLocaleString(undefined, opts)} – ${endStr}`;
+}
+
+async function loadMaintenanceNotice() {
+  const container = $('#maintenanceNotice');
+  if (!container) return;
+  let items = [];
+  try {
+    const res = await j('/api/maintenance');
+    items = res.maintenance || [];
+  } catch (e) {
+    console.error('Failed to load maintenance', e);
+  }
+  container.innerHTML = items.map(m => {
+    const services = m.services.length ? m.services.map(s => escapeHtml(s.label)).join(', ') : 'All services';
+    const when = m.ongoing
+      ? `Until ${new Date(m.ends_at).toLocaleString(undefined, { weekday: 'short', hour: '2-digit', minute: '2-digit' })}`
+      : fmtMaintenanceRange(m);
+    return `
+      <div class="site-alert info maintenance-notice">
+        ${getAlertIcon('info')}
+        <div class="site-alert-content">
+          <span class="site-alert-message">${m.ongoing ? 'Maintenance in progress' : 'Scheduled maintenance'}: ${escapeHtml(m.title)}${m.message ? ` — ${escapeHtml(m.message)}` : ''}</span>
+          <span class="site-alert-service">${services}</span>
+          <span class="site-alert-time">${when}</span>
+        </div>
+      </div>`;
+  }).join('');
+}
+
+function renderMaintenanceServicePicker() {
+  const container = $('#maintServices');
+  if (!container) return;
+  const checked = new Set($$('input:checked', container).map(el => el.value));
+  container.innerHTML = SERVICE_KEYS.map(key => `
+    <label><input type="checkbox" value="${escapeHtml(key)}"${checked.has(key) ? ' checked' : ''}> ${escapeHtml(SERVICE_LABELS[key] || key)}</label>
+  `).join('');
+}
+
+async function loadAdminMaintenance() {
+  renderMaintenanceServicePicker();
+  const list = $('#maintenanceList');
+  if (!list) return;
+  try {
+    const res = await j('/api/admin/maintenance', {
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    const windows = res.windows || [];
+    if (windows.length === 0) {
+      list.innerHTML = '<div class="muted">No maintenance windows</div>';
+      return;
+    }
+
+    list.innerHTML = '';
+    windows.forEach(m => {
+      const services = m.services.length ? m.services.map(k => escapeHtml(SERVICE_LABELS[k] || k)).join(', ') : 'All services';
+      const repeat = m.rrule ? `RRULE ${escapeHtml(m.rrule)}` : m.cron ? `cron ${escapeHtml(m.cron)}` : 'once';
+      const div = document.createElement('div');
+      div.className = 'banner-item';
+      div.innerHTML = `
+        <span class="banner-item-level info">${m.rrule || m.cron ? 'RECURRING' : 'ONCE'}</span>
+        <div class="banner-item-content">
+          <span class="banner-item-msg">${escapeHtml(m.title)}</span>
+          <span class="banner-item-service">${services} · ${new Date(m.starts_at).toLocaleString()} · ${fmtDuration(m.duration_minutes * 60)} · repeats ${repeat}</span>
+        </div>
+        <button class="banner-delete">Delete</button>
+      `;
+      $('.banner-delete', div).addEventListener('click', () => deleteMaintenance(m.id));
+      list.appendChild(div);
+    });
+  } catch (e) {
+    console.error('Failed to load maintenance windows', e);
+  }
+}
+
+async function createMaintenance() {
+  const title = $('#maintTitle').value.trim();
+  const start = $('#maintStart').value;
+  const duration = parseInt($('#maintDuration').value, 10);
+  if (!title || !start || !duration) {
+    alert('Please enter a title, start and duration');
+    return;
+  }
+  const repeat = $('#maintRepeat').value;
+  const rule = $('#maintRule').value.trim();
+  if (repeat && !rule) {
+    alert('Please enter a recurrence rule');
+    return;
+  }
+
+  try {
+    await j('/api/admin/maintenance', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'X-CSRF-Token': getCsrf()
+      },
+      body: JSON.stringify({
+        title,
+        message: $('#maintMessage').value.trim(),
+        services: $$('#maintServices input:checked').map(el => el.value),
+        starts_at: new Date(start).toISOString(),
+        duration_minutes: duration,
+        rrule: repeat === 'rrule' ? rule : '',
+        cron: repeat === 'cron' ? rule : ''
+      })
+    });
+    $('#maintTitle').value = '';
+    $('#maintMessage').value = '';
+    $('#maintRule').value = '';
+    showToast('Maintenance scheduled');
+    loadAdminMaintenance();
+    refresh();
+  } catch (e) {
+    console.error('Failed to schedule maintenance', e);
+    showToast('Failed to schedule maintenance', 'error');
+  }
+}
+
+async function deleteMaintenance(id) {
+  if (!confirm('Delete this maintenance window?')) return;
+  try {
+    await j(`/api/admin/maintenance?id=${id}`, {
+      method: 'DELETE',
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    showToast('Maintenance window deleted');
+    loadAdminMaintenance();
+    refresh();
+  } catch (e) {
+    console.error('Failed to delete maintenance window', e);
+    showToast('Failed to delete maintenance window', 'error');
+  }
+}
+
+/* Incident Functions */
+function fillIncidentSelect(select, values, selected) {
+  if (!select) return;
+  select.innerHTML = values.map(v => `<option value="${v}"${v === selected ? ' selected' : ''}>${capitalize(v)}</option>`).join('');
+}
+
+function renderIncidentServicePicker() {
+  const container = $('#incidentServices');
+  if (!container) return;
+  const checked = new Set($$('input:checked', container).map(el => el.value));
+  container.innerHTML = SERVICE_KEYS.map(key => `
+    <label><input type="checkbox" value="${escapeHtml(key)}"${checked.has(key) ? ' checked' : ''}> ${escapeHtml(SERVICE_LABELS[key] || key)}</label>
+  `).join('');
+}
+
+async function loadAdminIncidents() {
+  renderIncidentServicePicker();
+  const list = $('#adminIncidentsList');
+  if (!list) return;
+  try {
+    const res = await j('/api/admin/incidents?days=90', {
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    const incidents = res.incidents || [];
+    if (incidents.length === 0) {
+      list.innerHTML = '<div class="muted">No incidents in the last 90 days</div>';
+      return;
+    }
+
+    list.innerHTML = '';
+    incidents.forEach(i => {
+      const div = document.createElement('div');
+      div.className = `incident impact-${i.impact}`;
+      div.innerHTML = `
+        <div class="incident-head">
+          <span class="incident-title">${escapeHtml(i.title)}${i.automatic ? ' <span class="muted">(automatic)</span>' : ''}</span>
+          <span class="incident-update-status status-${i.status}">${capitalize(i.status)}</span>
+        </div>
+        <div class="incident-meta muted">${incidentMeta(i)}</div>
+        ${i.root_error ? `<div class="incident-meta muted">Error: ${escapeHtml(i.root_error)}</div>` : ''}
+        ${i.acknowledged_at ? `<div class="incident-meta muted">Acknowledged by ${escapeHtml(i.acknowledged_by)} on ${new Date(i.acknowledged_at).toLocaleString()}</div>` : ''}
+        ${renderIncidentUpdates(i.updates)}
+        <div class="incident-post">
+          <select class="incident-post-status"></select>
+          <select class="incident-post-impact"></select>
+          <input type="text" class="incident-post-message" placeholder="Update message" />
+          <button class="btn mini incident-post-btn">Post update</button>
+          ${!i.resolved_at && !i.acknowledged_at ? '<button class="btn mini incident-ack-btn">Acknowledge</button>' : ''}
+          <button class="banner-delete">Delete</button>
+        </div>
+      `;
+      fillIncidentSelect($('.incident-post-status', div), INCIDENT_STATUSES, i.status);
+      fillIncidentSelect($('.incident-post-impact', div), INCIDENT_IMPACTS, i.impact);
+      $('.incident-post-btn', div).addEventListener('click', () => postIncidentUpdate(i.id, div));
+      $('.incident-ack-btn', div)?.addEventListener('click', () => acknowledgeIncident(i.id));
+      $('.banner-delete', div).addEventListener('click', () => deleteIncident(i.id));
+      list.appendChild(div);
+    });
+  } catch (e) {
+    console.error('Failed to load incidents', e);
+  }
+}
+
+async function createIncident() {
+  const title = $('#incidentTitle').value.trim();
+  const message = $('#incidentMessage').value.trim();
+  if (!title || !message) {
+    alert('Please enter a title and a message');
+    return;
+  }
+  const services = $$('#incidentServices input:checked').map(el => el.value);
+
+  try {
+    await j('/api/admin/incidents', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'X-CSRF-Token': getCsrf()
+      },
+      body: JSON.stringify({
+        title,
+        message,
+        services,
+        status: $('#incidentStatus').value,
+        impact: $('#incidentImpact').value
+      })
+    });
+    $('#incidentTitle').value = '';
+    $('#incidentMessage').value = '';
+    showToast('Incident posted');
+    loadAdminIncidents();
+    refresh();
+  } catch (e) {
+    console.error('Failed to create incident', e);
+    showToast('Failed to post incident', 'error');
+  }
+}
+
+async function postIncidentUpdate(id, el) {
+  const message = $('.incident-post-message', el).value.trim();
+  if (!message) {
+    alert('Please enter a message');
+    return;
+  }
+  try {
+    await j('/api/admin/incidents/updates', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'X-CSRF-Token': getCsrf()
+      },
+      body: JSON.stringify({
+        incident_id: id,
+        message,
+        status: $('.incident-post-status', el).value,
+        impact: $('.incident-post-impact', el).value
+      })
+    });
+    showToast('Update posted');
+    loadAdminIncidents();
+    refresh();
+  } catch (e) {
+    console.error('Failed to post incident update', e);
+    showToast(typeof e.body === 'string' && e.body.trim() ? e.body.trim() : 'Failed to post update', 'error');
+  }
+}
+
+async function acknowledgeIncident(id) {
+  try {
+    await j(`/api/admin/incidents/ack?id=${id}`, {
+      method: 'POST',
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    showToast('Incident acknowledged, reminders stopped');
+    loadAdminIncidents();
+  } catch (e) {
+    console.error('Failed to acknowledge incident', e);
+    showToast('Failed to acknowledge incident', 'error');
+  }
+}
+
+async function deleteIncident(id) {
+  if (!confirm('Delete this incident and its updates?')) return;
+  try {
+    await j(`/api/admin/incidents?id=${id}`, {
+      method: 'DELETE',
+      headers: { 'X-CSRF-Token': getCsrf() }
+    });
+    showToast('Incident deleted');
+    loadAdminIncidents();
+    refresh();
+  } catch (e) {
+    console.error('Failed to delete incident', e);
+    showToast('Failed to delete incident', 'error');
+  }
+}
+
 /* Banner Functions */
 async function loadBanners() {
   try {